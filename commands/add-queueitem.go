@@ -4,56 +4,42 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"os"
 	"strconv"
-	"strings"
+	"time"
 
-	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
 )
 
-const aqiEndPointURI string = "/odata/Queues/UiPathODataSvc.AddQueueItem"
-
 // CmdAddQueueItem represents the flags this command supports
 type CmdAddQueueItem struct {
-	APIEndpoint        string `short:"e" long:"api-endpoint" description:"API endpoint (e.g. https://api.example.com)"`
-	AccountLogicalName string `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
-	ServiceLogicalName string `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
-	QueueName          string `short:"q" long:"queue" required:"true" description:"The name of the queue to which we will add a item"`
-	Priority           string `short:"p" long:"priority" default:"Normal" choice:"Low" choice:"Normal" choice:"High" description:"The priority of the queue item."`
-	Reference          string `short:"r" long:"reference" required:"true" description:"The reference identfier to assign to the queue item. Note: Some queues are configured to use unique reference ids"`
-	SpecificContent    string `short:"c" long:"content" description:"Additional data for the queue item.  Must be provided as a single-quoted JSON string. (E.g. '{\"Attribue\":\"Value\"}')"`
-	DueDate            string `short:"d" long:"deadline" description:"The UTC date before which the queue item should be processed. Must be provided as a JSON datetime in the format YYYY-MM-DDTHH:MM:SS.sssZ."`
-	Postpone           string `long:"postpone" description:"The UTC date after which the queue item may be processed. Must be provided as a JSON datetime in the format YYYY-MM-DDTHH:MM:SS.sssZ."`
+	APIEndpoint        string   `short:"e" long:"api-endpoint" description:"API endpoint (e.g. https://api.example.com)"`
+	AccountLogicalName string   `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
+	ServiceLogicalName string   `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
+	QueueName          string   `short:"q" long:"queue" required:"true" description:"The name of the queue to which we will add a item"`
+	Priority           string   `short:"p" long:"priority" default:"Normal" choice:"Low" choice:"Normal" choice:"High" description:"The priority of the queue item."`
+	Reference          string   `short:"r" long:"reference" description:"The reference identfier to assign to the queue item. Note: Some queues are configured to use unique reference ids"`
+	SpecificContent    string   `short:"c" long:"content" description:"Additional data for the queue item as a JSON object (e.g. '{\"Attribute\":\"Value\"}'), or @file to read it from a file"`
+	Fields             []string `long:"field" description:"Add a value to the SpecificContent.  Use key=value for text or key:=json for numbers, booleans, arrays and objects.  A value of @file is read from the file.  Repeat for several"`
+	DueDate            string   `short:"d" long:"deadline" description:"The date before which the queue item should be processed.  RFC3339 (2006-01-02T15:04:05Z), a date (2006-01-02) or relative to now (e.g. +2h or +1d)"`
+	Postpone           string   `long:"postpone" description:"The date after which the queue item may be processed.  RFC3339, a date or relative to now (e.g. +30m)"`
+	FromFile           string   `short:"f" long:"from-file" description:"Add every item in a CSV or JSON Lines file, or - for stdin.  Every item needs a Reference that is unique in the file.  Reference, Priority, DueDate and DeferDate columns set those fields and the other columns become the SpecificContent"`
+	Format             string   `long:"format" choice:"csv" choice:"jsonl" description:"Format of --from-file.  Defaults to the file extension, or the content for stdin"`
+	CommitType         string   `long:"commit-type" default:"ProcessAllIndependently" choice:"ProcessAllIndependently" choice:"AllOrNothing" choice:"StopOnFirstFailure" description:"How failures in --from-file are handled.  AllOrNothing and StopOnFirstFailure apply to each chunk and stop at the first failed chunk"`
+	ChunkSize          int      `long:"chunk-size" default:"100" description:"Number of items sent in each request with --from-file"`
+	Rejects            string   `long:"rejects" description:"Write the items that were not added to this file as JSON Lines, with the line they came from and the error"`
 
 	Config Config
 }
 
-type requestBodyWrapper struct {
-	ItemData requestBody `json:"itemData"`
-}
-
-type requestBody struct {
-	Name            string `json:"Name"`
-	Priority        string `json:"Priority"`
-	SpecificContent string `json:"SpecificContent"`
-	DeferDate       string `json:"DeferDate,omitempty"`
-	DueDate         string `json:"DueDate,omitempty"`
-	Reference       string `json:"Reference"`
-}
-
-type responseBody struct {
-	ODataContext      string `json:"@odata.context"`
-	QueueDefinitionID int    `json:"QueueDefinitionId"`
-	Status            string `json:"Status"`
-	Reference         string `json:"Reference"`
-	CreationTime      string `json:"CreationTime"`
-	ID                int    `json:"Id"`
-}
-
-type addQIErrorResponse struct {
-	Message   string `json:"message"`
-	ErrorCode int    `json:"errorCode"`
+var queueItemColumns = []output.Column{
+	{Header: "ITEM ID", Field: "Id"},
+	{Header: "REFERENCE", Field: "Reference"},
+	{Header: "STATUS", Field: "Status"},
+	{Header: "CREATION TIME", Field: "CreationTime"},
+	{Header: "QUEUE ID", Field: "QueueDefinitionId", Wide: true},
 }
 
 // Setup is called during execution to configure our command processing
@@ -89,94 +75,371 @@ func (cmd *CmdAddQueueItem) Setup(conf Config) error {
 func (cmd *CmdAddQueueItem) Execute(args []string) error {
 
 	// Validate incoming flags
-	//err := cmd.validateFlags()
+	err := cmd.validateFlags()
 
-	//if err != nil {
-	//	return err
-	//}
+	if err != nil {
+		return err
+	}
+
+	if cmd.FromFile != "" {
+		return cmd.addFromFile()
+	}
+
+	// Build our queue item
+	itemData := orchestrator.QueueItemData{}
+	itemData.Name = cmd.QueueName
+	itemData.Reference = cmd.Reference
+	itemData.Priority = cmd.Priority
+	itemData.DueDate = cmd.DueDate
+	itemData.DeferDate = cmd.Postpone
+	itemData.SpecificContent, err = buildSpecificContent(cmd.SpecificContent, cmd.Fields)
+	if err != nil {
+		return err
+	}
+	err = normalizeQueueItemDates(&itemData, time.Now())
+	if err != nil {
+		return err
+	}
 
-	// Build our request body
-	reqBody := requestBody{}
-	reqBody.Name = cmd.QueueName
-	reqBody.Reference = cmd.Reference
-	reqBody.Priority = cmd.Priority
-	reqBody.DueDate = cmd.DueDate
-	reqBody.DeferDate = cmd.Postpone
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
 
-	reqBodyWrapper := requestBodyWrapper{}
-	reqBodyWrapper.ItemData = reqBody
+	queueItem, err := client.AddQueueItem(itemData)
+	if err != nil {
+		return err
+	}
 
-	requestBody, err := json.Marshal(&reqBodyWrapper)
+	renderer, err := newRenderer(cmd.Config)
 	if err != nil {
 		return err
 	}
 
-	//Add the JSON specific content to the Request Body string
-	requestBody = []byte(strings.Replace(string(requestBody), "\"SpecificContent\":\"\"", "\"SpecificContent\":"+cmd.SpecificContent, 1))
+	if renderer.IsHuman() {
+		fmt.Println("Queue item created successfully")
+		fmt.Println("")
+	}
 
-	var endpoint string
+	return renderer.RenderOne(queueItemColumns, queueItem)
 
-	if cmd.Config.GetEndpointType() == config.EndpointTypeHosted {
-		endpoint = cmd.APIEndpoint + "/" + cmd.Config.GetAccountLogicalName() + "/" + cmd.Config.GetServiceLogicalName() + aqiEndPointURI
-	} else if cmd.Config.GetEndpointType() == config.EndpointTypeOnPremise {
-		endpoint = cmd.APIEndpoint + aqiEndPointURI
-	} else {
-		return errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
+}
+
+func (cmd *CmdAddQueueItem) validateFlags() error {
+
+	if cmd.FromFile == "" {
+		if cmd.Reference == "" {
+			return errors.New("A reference (--reference) is required")
+		}
+		return nil
 	}
 
-	client := http.Client{}
-	req, err := http.NewRequest("POST", endpoint, strings.NewReader(string(requestBody)))
+	if cmd.Reference != "" || cmd.SpecificContent != "" {
+		return errors.New("--reference and --content are read from the file with --from-file")
+	}
+	if cmd.ChunkSize < 1 {
+		return errors.New("--chunk-size must be at least 1")
+	}
+
+	return nil
+}
+
+// queueItemReject is an item from --from-file that was not added.  Item holds the
+//  line or row as it was read
+type queueItemReject struct {
+	Line      int             `json:"Line"`
+	Reference string          `json:"Reference"`
+	Error     string          `json:"Error"`
+	Item      json.RawMessage `json:"Item"`
+}
+
+// queueItemsResult is the result reported for --from-file
+type queueItemsResult struct {
+	Queue       string            `json:"Queue"`
+	Items       int               `json:"Items"`
+	Added       int               `json:"Added"`
+	Rejected    int               `json:"Rejected"`
+	RejectsFile string            `json:"RejectsFile,omitempty"`
+	Rejects     []queueItemReject `json:"Rejects,omitempty"`
+}
+
+var queueItemsResultColumns = []output.Column{
+	{Header: "QUEUE", Field: "Queue"},
+	{Header: "ITEMS", Field: "Items"},
+	{Header: "ADDED", Field: "Added"},
+	{Header: "REJECTED", Field: "Rejected"},
+	{Header: "REJECTS FILE", Field: "RejectsFile"},
+}
+
+var queueItemRejectColumns = []output.Column{
+	{Header: "LINE", Field: "Line"},
+	{Header: "REFERENCE", Field: "Reference"},
+	{Header: "ERROR", Field: "Error"},
+}
+
+// addFromFile adds the items in --from-file in chunks through the bulk API
+func (cmd *CmdAddQueueItem) addFromFile() error {
+
+	// Relative times in the file and the flags are all taken from the same moment
+	now := time.Now()
+	defaults := orchestrator.QueueItemData{
+		Name:      cmd.QueueName,
+		Priority:  cmd.Priority,
+		DueDate:   cmd.DueDate,
+		DeferDate: cmd.Postpone,
+	}
+	err := normalizeQueueItemDates(&defaults, now)
+	if err != nil {
+		return err
+	}
+	// --field values are added to every item that does not set them itself
+	fields, err := buildSpecificContent("", cmd.Fields)
 	if err != nil {
 		return err
 	}
 
-	// Add our required request headers
-	req.Header.Add("X-UIPATH-TenantName", cmd.Config.GetServiceLogicalName())
-	req.Header.Add("X-UIPATH-OrganizationUnitId", strconv.Itoa(cmd.Config.GetFolderID()))
-	req.Header.Add("Authorization", "Bearer "+cmd.Config.GetAccessToken())
-	req.Header.Add("Content-Type", "application/json")
-
-	respBody, err := client.Do(req)
+	records, err := readQueueItemFile(cmd.FromFile, cmd.Format, defaults)
 	if err != nil {
 		return err
 	}
+	if len(records) == 0 {
+		return errors.New("No queue items were found in " + cmd.FromFile)
+	}
+	for i := range records {
+		if records[i].err != "" {
+			continue
+		}
+		records[i].item.SpecificContent = mergeSpecificContent(fields, records[i].item.SpecificContent)
+		err = normalizeQueueItemDates(&records[i].item, now)
+		if err != nil {
+			records[i].err = err.Error()
+		}
+	}
+	checkQueueItemReferences(records)
 
-	body, readErr := ioutil.ReadAll(respBody.Body)
-	if readErr != nil {
-		return readErr
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
 	}
-	defer respBody.Body.Close()
 
-	if respBody.StatusCode < 300 {
-		apiResp := responseBody{}
-		jsonErr := json.Unmarshal(body, &apiResp)
-		if jsonErr != nil {
-			return jsonErr
+	rejected, unmatched := sendQueueItems(client, cmd.QueueName, cmd.CommitType, cmd.ChunkSize, records)
+
+	result := queueItemsResult{Queue: cmd.QueueName, Items: len(records)}
+	for i := range records {
+		if rejected[i] == "" {
+			result.Added++
+			continue
 		}
+		result.Rejects = append(result.Rejects, queueItemReject{
+			Line:      records[i].line,
+			Reference: records[i].item.Reference,
+			Error:     rejected[i],
+			Item:      records[i].source,
+		})
+	}
+	// A failure that matches no item still means an item was not added
+	result.Added -= len(unmatched)
+	if result.Added < 0 {
+		result.Added = 0
+	}
+	result.Rejects = append(result.Rejects, unmatched...)
+	result.Rejected = len(result.Rejects)
 
-		cmd.Config.SetAPIVersion(respBody.Header.Get("Api-Supported-Versions"))
+	if cmd.Rejects != "" && result.Rejected > 0 {
+		err = writeQueueItemRejects(cmd.Rejects, result.Rejects)
+		if err != nil {
+			return err
+		}
+		result.RejectsFile = cmd.Rejects
+	}
 
-		fmt.Println("Queue item created successfully")
-		fmt.Println("")
-		fmt.Println("      Item ID: ", apiResp.ID)
-		fmt.Println("Creation Time: ", apiResp.CreationTime)
-		fmt.Println("       Status: ", apiResp.Status)
-		fmt.Println("")
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
 
+	if !renderer.IsHuman() {
+		err = renderer.RenderOne(queueItemsResultColumns, result)
 	} else {
-		apiResp := addQIErrorResponse{}
-		jsonErr := json.Unmarshal(body, &apiResp)
-		if jsonErr != nil {
-			return jsonErr
+		err = renderQueueItemsResult(renderer, result)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.Rejected > 0 {
+		return &ExitError{Code: ExitCodeError, Message: strconv.Itoa(result.Rejected) + " queue item(s) were not added"}
+	}
+
+	return nil
+}
+
+// sendQueueItems sends the items in chunks and returns why each rejected item,
+//  by index, was not added.  Items that could not be read are never sent.
+//  Failures that could not be matched to an item are returned as rejects without a line
+func sendQueueItems(client *orchestrator.Client, queueName string, commitType string, chunkSize int, records []queueItemRecord) (map[int]string, []queueItemReject) {
+
+	rejected := map[int]string{}
+	for i, record := range records {
+		if record.err != "" {
+			rejected[i] = record.err
+		}
+	}
+
+	// Only ProcessAllIndependently may add part of the file
+	independent := commitType == orchestrator.CommitProcessAllIndependently
+	if !independent && len(rejected) > 0 {
+		for i := range records {
+			if rejected[i] == "" {
+				rejected[i] = "Not sent: the file has items that could not be read"
+			}
 		}
+		return rejected, nil
+	}
 
-		fmt.Println("Error adding queue item")
-		fmt.Println("Message: " + apiResp.Message)
-		fmt.Println("Error Code: " + strconv.Itoa(apiResp.ErrorCode))
+	var indexes []int
+	for i := range records {
+		if rejected[i] == "" {
+			indexes = append(indexes, i)
+		}
+	}
+
+	var unmatched []queueItemReject
+	chunks := (len(indexes) + chunkSize - 1) / chunkSize
+	for chunk := 0; chunk < chunks; chunk++ {
+		start := chunk * chunkSize
+		end := start + chunkSize
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+		chunkIndexes := indexes[start:end]
+
+		items := make([]orchestrator.QueueItemData, len(chunkIndexes))
+		for j, index := range chunkIndexes {
+			items[j] = records[index].item
+		}
+
+		util.LogInfo("Sending items " + strconv.Itoa(start+1) + " to " + strconv.Itoa(end) + " of " + strconv.Itoa(len(indexes)))
+		failed, err := client.BulkAddQueueItems(queueName, commitType, items)
+		if err != nil {
+			for _, index := range chunkIndexes {
+				rejected[index] = err.Error()
+			}
+		} else {
+			for _, failure := range markFailedQueueItems(records, chunkIndexes, failed, commitType, rejected) {
+				unmatched = append(unmatched, queueItemReject{
+					Reference: failure.Reference,
+					Error:     queueItemFailureMessage(failure) + " (not matched to an item in the file)",
+				})
+			}
+		}
+
+		if !independent && (err != nil || len(failed) > 0) {
+			for _, index := range indexes[end:] {
+				rejected[index] = "Not sent: an earlier chunk failed"
+			}
+			break
+		}
+	}
+
+	return rejected, unmatched
+}
+
+// markFailedQueueItems records the items of a chunk that Orchestrator did not add
+//  Failures are matched to items by reference, which is unique in the file.  The
+//  failures that match no item are returned.  With AllOrNothing every item of the
+//  chunk is already rejected, so none are returned
+func markFailedQueueItems(records []queueItemRecord, chunkIndexes []int, failed []orchestrator.FailedQueueItem, commitType string, rejected map[int]string) []orchestrator.FailedQueueItem {
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var unmatched []orchestrator.FailedQueueItem
+	first := len(chunkIndexes)
+	for _, failure := range failed {
+		matched := false
+		for j, index := range chunkIndexes {
+			if rejected[index] == "" && failure.Reference != "" && records[index].item.Reference == failure.Reference {
+				rejected[index] = queueItemFailureMessage(failure)
+				if j < first {
+					first = j
+				}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, failure)
+		}
+	}
+
+	for j, index := range chunkIndexes {
+		if rejected[index] != "" {
+			continue
+		}
+		switch {
+		case commitType == orchestrator.CommitAllOrNothing:
+			rejected[index] = "Not added: another item in the chunk failed"
+		case commitType == orchestrator.CommitStopOnFirstFailure && j > first:
+			rejected[index] = "Not added: an earlier item in the chunk failed"
+		}
+	}
+
+	if commitType == orchestrator.CommitAllOrNothing {
+		return nil
+	}
+
+	return unmatched
+}
+
+func queueItemFailureMessage(failure orchestrator.FailedQueueItem) string {
+
+	if failure.ErrorMessage != "" {
+		return failure.ErrorMessage
+	}
+	if failure.Type != "" {
+		return failure.Type
+	}
+
+	return "Not added"
+}
+
+// writeQueueItemRejects writes the rejected items as JSON Lines
+func writeQueueItemRejects(path string, rejects []queueItemReject) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, reject := range rejects {
+		err = encoder.Encode(reject)
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	return file.Close()
+}
+
+// renderQueueItemsResult writes the rejected items followed by a summary
+func renderQueueItemsResult(renderer *output.Renderer, result queueItemsResult) error {
+
+	if len(result.Rejects) > 0 {
+		err := renderer.Render(queueItemRejectColumns, result.Rejects)
+		if err != nil {
+			return err
+		}
 		fmt.Println("")
+	}
 
+	fmt.Println(strconv.Itoa(result.Added) + " of " + strconv.Itoa(result.Items) + " item(s) added to " + result.Queue + ", " + strconv.Itoa(result.Rejected) + " rejected")
+	if result.RejectsFile != "" {
+		fmt.Println("Rejected items were written to " + result.RejectsFile)
 	}
 
 	return nil
-
 }
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// newTestConfig returns a signed in config for folder 1 of an Orchestrator served by handler
+func newTestConfig(t *testing.T, handler http.HandlerFunc) *config.Config {
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conf := &config.Config{}
+	conf.ConfigFile.APIEndpoint = server.URL
+	conf.ConfigFile.EndpointType = config.EndpointTypeOnPremise
+	conf.ConfigFile.AccessToken = "token"
+	conf.ConfigFile.AccessTokenExpiry = time.Now().Add(time.Hour)
+	conf.ConfigFile.TargetedFolder.ID = 1
+
+	return conf
+}
+
+// newTestClient returns a client for an Orchestrator served by handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *orchestrator.Client {
+
+	client, err := orchestrator.NewClient(newTestConfig(t, handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func queueRecords(references ...string) []queueItemRecord {
+
+	records := make([]queueItemRecord, len(references))
+	for i, reference := range references {
+		records[i] = queueItemRecord{line: i + 2, item: orchestrator.QueueItemData{Name: "Invoices", Reference: reference}}
+	}
+
+	return records
+}
+
+func TestSendQueueItems(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		records    []queueItemRecord
+		commitType string
+		chunkSize  int
+		// failures are extra references the fake reports as failed
+		failures  []string
+		chunks    []int
+		rejected  map[int]string
+		unmatched []string
+	}{
+		{
+			name:       "independent items are added around failures",
+			records:    queueRecords("a", "bad-b", "c", "d", "e"),
+			commitType: orchestrator.CommitProcessAllIndependently,
+			chunkSize:  2,
+			chunks:     []int{2, 2, 1},
+			rejected:   map[int]string{1: "Duplicate reference"},
+		},
+		{
+			name:       "all or nothing rejects the failed chunk and stops",
+			records:    queueRecords("a", "b", "c", "bad-d", "e"),
+			commitType: orchestrator.CommitAllOrNothing,
+			chunkSize:  2,
+			chunks:     []int{2, 2},
+			rejected: map[int]string{
+				2: "Not added: another item in the chunk failed",
+				3: "Duplicate reference",
+				4: "Not sent: an earlier chunk failed",
+			},
+		},
+		{
+			name:       "stop on first failure keeps the items before it",
+			records:    queueRecords("a", "bad-b", "c", "d"),
+			commitType: orchestrator.CommitStopOnFirstFailure,
+			chunkSize:  3,
+			chunks:     []int{3},
+			rejected: map[int]string{
+				1: "Duplicate reference",
+				2: "Not added: an earlier item in the chunk failed",
+				3: "Not sent: an earlier chunk failed",
+			},
+		},
+		{
+			name:       "failed requests reject the chunk",
+			records:    queueRecords("a", "down-b", "c"),
+			commitType: orchestrator.CommitProcessAllIndependently,
+			chunkSize:  2,
+			chunks:     []int{2, 1},
+			rejected: map[int]string{
+				0: "API Request Failed: 500 Internal Server Error - Queue unavailable (Error Code: 9)",
+				1: "API Request Failed: 500 Internal Server Error - Queue unavailable (Error Code: 9)",
+			},
+		},
+		{
+			name:       "unmatched failures are returned",
+			records:    queueRecords("a", "b"),
+			commitType: orchestrator.CommitProcessAllIndependently,
+			chunkSize:  5,
+			failures:   []string{"ghost", ""},
+			chunks:     []int{2},
+			rejected:   map[int]string{},
+			unmatched:  []string{"ghost", ""},
+		},
+		{
+			name:       "unmatched failures are covered by all or nothing",
+			records:    queueRecords("a", "b"),
+			commitType: orchestrator.CommitAllOrNothing,
+			chunkSize:  5,
+			failures:   []string{"ghost"},
+			chunks:     []int{2},
+			rejected: map[int]string{
+				0: "Not added: another item in the chunk failed",
+				1: "Not added: another item in the chunk failed",
+			},
+		},
+		{
+			name: "unreadable items stop all or nothing before sending",
+			records: append(queueRecords("a"), queueItemRecord{
+				line: 3,
+				err:  "A Reference is required",
+			}),
+			commitType: orchestrator.CommitAllOrNothing,
+			chunkSize:  5,
+			rejected: map[int]string{
+				0: "Not sent: the file has items that could not be read",
+				1: "A Reference is required",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var chunks []int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "BulkAddQueueItems") {
+					http.NotFound(w, r)
+					return
+				}
+				var req struct {
+					CommitType string                       `json:"commitType"`
+					QueueItems []orchestrator.QueueItemData `json:"queueItems"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				if req.CommitType != test.commitType {
+					t.Errorf("commitType = %q, want %q", req.CommitType, test.commitType)
+				}
+				chunks = append(chunks, len(req.QueueItems))
+
+				failed := []orchestrator.FailedQueueItem{}
+				for _, item := range req.QueueItems {
+					if strings.HasPrefix(item.Reference, "down") {
+						w.WriteHeader(http.StatusInternalServerError)
+						w.Write([]byte(`{"message":"Queue unavailable","errorCode":9}`))
+						return
+					}
+					if strings.HasPrefix(item.Reference, "bad") {
+						failed = append(failed, orchestrator.FailedQueueItem{Reference: item.Reference, Type: "DuplicateReference", ErrorMessage: "Duplicate reference"})
+					}
+				}
+				for _, reference := range test.failures {
+					failed = append(failed, orchestrator.FailedQueueItem{Reference: reference, ErrorMessage: "Lost"})
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"value": failed})
+			})
+
+			rejected, unmatched := sendQueueItems(client, "Invoices", test.commitType, test.chunkSize, test.records)
+
+			if !reflect.DeepEqual(chunks, test.chunks) {
+				t.Errorf("chunks sent = %v, want %v", chunks, test.chunks)
+			}
+			got := map[int]string{}
+			for index, message := range rejected {
+				if message != "" {
+					got[index] = message
+				}
+			}
+			if !reflect.DeepEqual(got, test.rejected) {
+				t.Errorf("rejected = %v, want %v", got, test.rejected)
+			}
+			var references []string
+			for _, reject := range unmatched {
+				if reject.Line != 0 || reject.Error != "Lost (not matched to an item in the file)" {
+					t.Errorf("unmatched reject = %+v", reject)
+				}
+				references = append(references, reject.Reference)
+			}
+			if !reflect.DeepEqual(references, test.unmatched) {
+				t.Errorf("unmatched = %q, want %q", references, test.unmatched)
+			}
+		})
+	}
+}
+
+func TestMarkFailedQueueItemsRepeatedReference(t *testing.T) {
+
+	// Each failure is blamed on one item, even if references repeat
+	records := queueRecords("a", "a", "b")
+	rejected := map[int]string{}
+	failed := []orchestrator.FailedQueueItem{{Reference: "a", ErrorMessage: "Duplicate reference"}}
+
+	unmatched := markFailedQueueItems(records, []int{0, 1, 2}, failed, orchestrator.CommitProcessAllIndependently, rejected)
+
+	if len(unmatched) != 0 || rejected[0] != "Duplicate reference" || rejected[1] != "" || rejected[2] != "" {
+		t.Errorf("rejected = %v, unmatched = %v", rejected, unmatched)
+	}
+}
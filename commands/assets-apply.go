@@ -0,0 +1,547 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"gopkg.in/yaml.v2"
+)
+
+// Actions in an asset plan
+const (
+	assetCreate    string = "Create"
+	assetUpdate    string = "Update"
+	assetDelete    string = "Delete"
+	assetUnchanged string = "Unchanged"
+)
+
+// CmdAssetsApply represents the flags supported by the assets apply command
+type CmdAssetsApply struct {
+	File          string `short:"f" long:"file" required:"yes" description:"YAML or JSON manifest of the assets the folder should hold.  Use - to read stdin"`
+	DryRun        bool   `long:"dry-run" description:"Show the plan without changing anything"`
+	Prune         bool   `long:"prune" description:"Delete assets in the folder that are not in the manifest"`
+	UpdateSecrets bool   `long:"update-secrets" description:"Send credential passwords for existing assets.  Orchestrator never returns passwords, so they cannot be compared"`
+
+	Config Config
+}
+
+// assetManifest is the file read by assets apply.  Secrets are never written in
+//  the manifest.  valueEnv and passwordEnv name environment variables that hold them
+//
+//	assets:
+//	  - name: ApiUrl
+//	    type: Text
+//	    value: https://api.example.com
+//	  - name: ServiceLogin
+//	    type: Credential
+//	    username: svc-robot
+//	    passwordEnv: SERVICE_LOGIN_PASSWORD
+//	  - name: Mailbox
+//	    type: Text
+//	    robots:
+//	      - robot: Robot1
+//	        value: robot1@example.com
+type assetManifest struct {
+	Assets []assetSpec `yaml:"assets"`
+}
+
+type assetSpec struct {
+	Name           string `yaml:"name"`
+	Type           string `yaml:"type"`
+	Description    string `yaml:"description"`
+	assetValueSpec `yaml:",inline"`
+	Robots         []assetRobotSpec `yaml:"robots"`
+}
+
+type assetRobotSpec struct {
+	Robot          string `yaml:"robot"`
+	assetValueSpec `yaml:",inline"`
+}
+
+type assetValueSpec struct {
+	Value       *string `yaml:"value"`
+	ValueEnv    string  `yaml:"valueEnv"`
+	Username    string  `yaml:"username"`
+	PasswordEnv string  `yaml:"passwordEnv"`
+}
+
+// assetChange is one entry in the plan.  Changes names what differs but never
+//  shows values, which may be secret
+type assetChange struct {
+	Action  string `json:"Action"`
+	Name    string `json:"Name"`
+	Type    string `json:"Type"`
+	Changes string `json:"Changes,omitempty"`
+	Result  string `json:"Result,omitempty"`
+	Error   string `json:"Error,omitempty"`
+
+	current *orchestrator.Asset
+	desired *orchestrator.Asset
+}
+
+var assetPlanColumns = []output.Column{
+	{Header: "ACTION", Field: "Action"},
+	{Header: "ASSET", Field: "Name"},
+	{Header: "TYPE", Field: "Type"},
+	{Header: "CHANGES", Field: "Changes"},
+}
+
+var assetApplyColumns = []output.Column{
+	{Header: "ACTION", Field: "Action"},
+	{Header: "ASSET", Field: "Name"},
+	{Header: "RESULT", Field: "Result"},
+	{Header: "ERROR", Field: "Error"},
+	{Header: "TYPE", Field: "Type", Wide: true},
+	{Header: "CHANGES", Field: "Changes", Wide: true},
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsApply) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsApply) Execute(args []string) error {
+
+	manifest, err := readAssetManifest(cmd.File)
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	current, err := client.ListAssets(orchestrator.ODataQuery{Expand: "RobotValues"}, orchestrator.Paging{All: true})
+	if err != nil {
+		return err
+	}
+	robots, err := client.ListRobots(orchestrator.ODataQuery{}, orchestrator.Paging{All: true})
+	if err != nil {
+		return err
+	}
+
+	// Every problem in the manifest is reported before anything is changed
+	desired, problems := desiredAssets(manifest, robots)
+	plan, planProblems := planAssets(current, desired, cmd.Prune, cmd.UpdateSecrets)
+	problems = append(problems, planProblems...)
+	if len(problems) > 0 {
+		return errors.New("The manifest has " + strconv.Itoa(len(problems)) + " problem(s):\n  " + strings.Join(problems, "\n  "))
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, change := range plan {
+		counts[change.Action]++
+	}
+	pending := len(plan) - counts[assetUnchanged]
+
+	if cmd.DryRun || pending == 0 {
+		err = renderer.Render(assetPlanColumns, plan)
+		if err != nil {
+			return err
+		}
+		if renderer.IsHuman() {
+			fmt.Println("")
+			fmt.Println(assetPlanSummary(counts))
+			if cmd.DryRun {
+				fmt.Println("Dry run: no changes were made")
+			}
+		}
+		return nil
+	}
+
+	if renderer.IsHuman() {
+		err = renderer.Render(assetPlanColumns, plan)
+		if err != nil {
+			return err
+		}
+		fmt.Println("")
+		fmt.Println(assetPlanSummary(counts))
+		fmt.Println("")
+	}
+
+	failed := 0
+	for i := range plan {
+		applyAssetChange(client, &plan[i])
+		if plan[i].Error != "" {
+			failed++
+		}
+	}
+
+	err = renderer.Render(assetApplyColumns, plan)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return &ExitError{Code: ExitCodeError, Message: strconv.Itoa(failed) + " asset change(s) failed"}
+	}
+
+	return nil
+}
+
+// readAssetManifest reads the manifest file.  JSON is read as YAML, which it is
+//  a subset of
+func readAssetManifest(path string) (*assetManifest, error) {
+
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := assetManifest{}
+	err = yaml.UnmarshalStrict(raw, &manifest)
+	if err != nil {
+		return nil, errors.New("Unable to read the manifest " + path + ": " + err.Error())
+	}
+
+	return &manifest, nil
+}
+
+// desiredAssets turns the manifest into the assets the folder should hold,
+//  reading secrets from the environment and looking up robots by name
+func desiredAssets(manifest *assetManifest, robots []orchestrator.Robot) ([]orchestrator.Asset, []string) {
+
+	robotIDs := map[string]int{}
+	for _, robot := range robots {
+		robotIDs[robot.Name] = robot.ID
+	}
+
+	var assets []orchestrator.Asset
+	var problems []string
+	seen := map[string]bool{}
+	for i, spec := range manifest.Assets {
+		fail := func(message string) {
+			problems = append(problems, spec.Name+": "+message)
+		}
+
+		if spec.Name == "" {
+			problems = append(problems, "Asset "+strconv.Itoa(i+1)+" has no name")
+			continue
+		}
+		if seen[strings.ToLower(spec.Name)] {
+			fail("appears more than once")
+			continue
+		}
+		seen[strings.ToLower(spec.Name)] = true
+
+		switch spec.Type {
+		case orchestrator.AssetText, orchestrator.AssetInteger, orchestrator.AssetBool, orchestrator.AssetCredential:
+		default:
+			fail("type must be Text, Integer, Bool or Credential")
+			continue
+		}
+
+		asset := orchestrator.Asset{
+			Name:        spec.Name,
+			ValueScope:  orchestrator.AssetGlobal,
+			Description: spec.Description,
+			AssetValue:  orchestrator.AssetValue{ValueType: spec.Type},
+			RobotValues: []orchestrator.AssetRobotValue{},
+		}
+
+		hasValue, err := spec.assetValueSpec.apply(&asset.AssetValue)
+		asset.HasDefaultValue = hasValue
+
+		switch {
+		case err != nil:
+			fail(err.Error())
+		case len(spec.Robots) > 0:
+			asset.ValueScope = orchestrator.AssetPerRobot
+		case !hasValue:
+			fail("needs a value, or values for robots")
+		}
+
+		for _, robotSpec := range spec.Robots {
+			robotID, found := robotIDs[robotSpec.Robot]
+			if !found {
+				fail("no robot named '" + robotSpec.Robot + "' was found in the folder")
+				continue
+			}
+			robotValue := orchestrator.AssetRobotValue{RobotID: robotID, RobotName: robotSpec.Robot, AssetValue: orchestrator.AssetValue{ValueType: spec.Type}}
+			hasValue, err := robotSpec.assetValueSpec.apply(&robotValue.AssetValue)
+			if err != nil {
+				fail("robot " + robotSpec.Robot + ": " + err.Error())
+			} else if !hasValue {
+				fail("robot " + robotSpec.Robot + " has no value")
+			}
+			asset.RobotValues = append(asset.RobotValues, robotValue)
+		}
+
+		assets = append(assets, asset)
+	}
+
+	return assets, problems
+}
+
+// apply sets the value in the spec, if there is one, on value
+func (spec assetValueSpec) apply(value *orchestrator.AssetValue) (bool, error) {
+
+	if value.ValueType == orchestrator.AssetCredential {
+		if spec.Value != nil || spec.ValueEnv != "" {
+			return false, errors.New("credentials take username and passwordEnv, not a value")
+		}
+		if spec.Username == "" && spec.PasswordEnv == "" {
+			return false, nil
+		}
+		if spec.Username == "" || spec.PasswordEnv == "" {
+			return false, errors.New("a credential needs both username and passwordEnv")
+		}
+		password, err := secretFromEnv(spec.PasswordEnv)
+		if err != nil {
+			return false, err
+		}
+		return true, setAssetValue(value, password, spec.Username)
+	}
+
+	if spec.Username != "" || spec.PasswordEnv != "" {
+		return false, errors.New("username and passwordEnv are only used with Credential assets")
+	}
+	if spec.Value != nil && spec.ValueEnv != "" {
+		return false, errors.New("use either value or valueEnv, not both")
+	}
+
+	switch {
+	case spec.Value != nil:
+		return true, setAssetValue(value, *spec.Value, "")
+	case spec.ValueEnv != "":
+		text, err := secretFromEnv(spec.ValueEnv)
+		if err != nil {
+			return false, err
+		}
+		return true, setAssetValue(value, text, "")
+	}
+
+	return false, nil
+}
+
+func secretFromEnv(name string) (string, error) {
+
+	value, found := os.LookupEnv(name)
+	if !found || value == "" {
+		return "", errors.New("environment variable " + name + " is not set")
+	}
+
+	return value, nil
+}
+
+// planAssets compares the folder's assets with the manifest.  Unchanged assets
+//  are part of the plan so that it shows every asset the manifest covers
+func planAssets(current []orchestrator.Asset, desired []orchestrator.Asset, prune bool, updateSecrets bool) ([]assetChange, []string) {
+
+	byName := map[string]*orchestrator.Asset{}
+	for i := range current {
+		byName[strings.ToLower(current[i].Name)] = &current[i]
+	}
+
+	var plan []assetChange
+	var problems []string
+	for i := range desired {
+		want := &desired[i]
+		have, found := byName[strings.ToLower(want.Name)]
+		if !found {
+			problems = append(problems, missingPasswords(nil, want)...)
+			plan = append(plan, assetChange{Action: assetCreate, Name: want.Name, Type: want.ValueType, desired: want})
+			continue
+		}
+		delete(byName, strings.ToLower(want.Name))
+
+		if have.ValueType != want.ValueType {
+			problems = append(problems, want.Name+": is a "+have.ValueType+" asset in the folder.  Delete it to change its type to "+want.ValueType)
+			continue
+		}
+
+		// The password can only be compared by sending it again
+		if !updateSecrets {
+			blankStoredPasswords(have, want)
+		}
+		problems = append(problems, missingPasswords(have, want)...)
+
+		change := assetChange{Action: assetUnchanged, Name: want.Name, Type: want.ValueType, current: have, desired: want}
+		differences := assetDifferences(have, want)
+		if len(differences) > 0 {
+			change.Action = assetUpdate
+			change.Changes = strings.Join(differences, ", ")
+		}
+		plan = append(plan, change)
+	}
+
+	if prune {
+		for i := range current {
+			if _, found := byName[strings.ToLower(current[i].Name)]; found {
+				plan = append(plan, assetChange{Action: assetDelete, Name: current[i].Name, Type: current[i].ValueType, current: &current[i]})
+			}
+		}
+	}
+
+	return plan, problems
+}
+
+// blankStoredPasswords leaves out the passwords of credential values that are
+//  already in the folder so that they are kept.  New values keep their password
+func blankStoredPasswords(have *orchestrator.Asset, want *orchestrator.Asset) {
+
+	if have.HasDefaultValue {
+		want.CredentialPassword = ""
+	}
+
+	haveRobots := robotValueIDs(have)
+	for j := range want.RobotValues {
+		if haveRobots[want.RobotValues[j].RobotID] {
+			want.RobotValues[j].CredentialPassword = ""
+		}
+	}
+}
+
+// missingPasswords lists the credential values that would be added without a
+//  password.  have is nil for an asset that is not in the folder yet
+func missingPasswords(have *orchestrator.Asset, want *orchestrator.Asset) []string {
+
+	if want.ValueType != orchestrator.AssetCredential {
+		return nil
+	}
+
+	var problems []string
+	if want.HasDefaultValue && want.CredentialPassword == "" && (have == nil || !have.HasDefaultValue) {
+		problems = append(problems, want.Name+": the value needs a password")
+	}
+
+	haveRobots := robotValueIDs(have)
+	for _, robotValue := range want.RobotValues {
+		if robotValue.CredentialPassword == "" && !haveRobots[robotValue.RobotID] {
+			problems = append(problems, want.Name+": the value for robot "+robotValue.RobotName+" needs a password")
+		}
+	}
+
+	return problems
+}
+
+// robotValueIDs returns the robots that have a value for the asset
+func robotValueIDs(asset *orchestrator.Asset) map[int]bool {
+
+	robots := map[int]bool{}
+	if asset != nil {
+		for _, robotValue := range asset.RobotValues {
+			robots[robotValue.RobotID] = true
+		}
+	}
+
+	return robots
+}
+
+// assetDifferences lists what differs between the folder's asset and the manifest
+func assetDifferences(have *orchestrator.Asset, want *orchestrator.Asset) []string {
+
+	var differences []string
+	if have.ValueScope != want.ValueScope {
+		differences = append(differences, "scope")
+	}
+	if have.HasDefaultValue != want.HasDefaultValue {
+		differences = append(differences, "value")
+	} else if want.HasDefaultValue {
+		differences = append(differences, valueDifference("", have.AssetValue, want.AssetValue)...)
+	}
+	if have.Description != want.Description {
+		differences = append(differences, "description")
+	}
+
+	haveRobots := map[int]orchestrator.AssetRobotValue{}
+	for _, robotValue := range have.RobotValues {
+		haveRobots[robotValue.RobotID] = robotValue
+	}
+	for _, robotValue := range want.RobotValues {
+		current, found := haveRobots[robotValue.RobotID]
+		delete(haveRobots, robotValue.RobotID)
+		if !found {
+			differences = append(differences, "robot "+robotValue.RobotName+" added")
+		} else {
+			differences = append(differences, valueDifference("robot "+robotValue.RobotName+" ", current.AssetValue, robotValue.AssetValue)...)
+		}
+	}
+	for _, robotValue := range have.RobotValues {
+		if _, found := haveRobots[robotValue.RobotID]; found {
+			differences = append(differences, "robot "+robotValue.RobotName+" removed")
+		}
+	}
+
+	return differences
+}
+
+// valueDifference names what differs between two values of the same type.  A
+//  password in want is always a change, since Orchestrator does not return the
+//  stored one
+func valueDifference(prefix string, have orchestrator.AssetValue, want orchestrator.AssetValue) []string {
+
+	same := false
+	switch want.ValueType {
+	case orchestrator.AssetText:
+		same = have.StringValue == want.StringValue
+	case orchestrator.AssetInteger:
+		same = have.IntValue == want.IntValue
+	case orchestrator.AssetBool:
+		same = have.BoolValue == want.BoolValue
+	case orchestrator.AssetCredential:
+		if have.CredentialUsername != want.CredentialUsername {
+			return []string{prefix + "username"}
+		}
+		if want.CredentialPassword != "" {
+			return []string{prefix + "password"}
+		}
+		same = true
+	}
+
+	if same {
+		return nil
+	}
+
+	return []string{prefix + "value"}
+}
+
+// applyAssetChange makes one change from the plan and records its result
+func applyAssetChange(client *orchestrator.Client, change *assetChange) {
+
+	var err error
+	switch change.Action {
+	case assetCreate:
+		_, err = client.CreateAsset(*change.desired)
+		change.Result = "Created"
+	case assetUpdate:
+		change.desired.ID = change.current.ID
+		err = client.UpdateAsset(*change.desired)
+		change.Result = "Updated"
+	case assetDelete:
+		err = client.DeleteAsset(change.current.ID)
+		change.Result = "Deleted"
+	default:
+		change.Result = assetUnchanged
+	}
+
+	if err != nil {
+		change.Result = "Failed"
+		change.Error = err.Error()
+	}
+}
+
+func assetPlanSummary(counts map[string]int) string {
+	return strconv.Itoa(counts[assetCreate]) + " to create, " + strconv.Itoa(counts[assetUpdate]) + " to update, " +
+		strconv.Itoa(counts[assetDelete]) + " to delete, " + strconv.Itoa(counts[assetUnchanged]) + " unchanged"
+}
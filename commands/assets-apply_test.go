@@ -0,0 +1,283 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func textAsset(name string, value string) orchestrator.Asset {
+	return orchestrator.Asset{
+		Name:            name,
+		ValueScope:      orchestrator.AssetGlobal,
+		HasDefaultValue: true,
+		AssetValue:      orchestrator.AssetValue{ValueType: orchestrator.AssetText, StringValue: value},
+		RobotValues:     []orchestrator.AssetRobotValue{},
+	}
+}
+
+func credentialValue(username string, password string) orchestrator.AssetValue {
+	return orchestrator.AssetValue{ValueType: orchestrator.AssetCredential, CredentialUsername: username, CredentialPassword: password}
+}
+
+func credentialAsset(name string, value *orchestrator.AssetValue, robots ...orchestrator.AssetRobotValue) orchestrator.Asset {
+
+	asset := orchestrator.Asset{
+		Name:        name,
+		ValueScope:  orchestrator.AssetGlobal,
+		AssetValue:  orchestrator.AssetValue{ValueType: orchestrator.AssetCredential},
+		RobotValues: robots,
+	}
+	if value != nil {
+		asset.HasDefaultValue = true
+		asset.AssetValue = *value
+	}
+	if len(robots) > 0 {
+		asset.ValueScope = orchestrator.AssetPerRobot
+	}
+
+	return asset
+}
+
+func robotCredential(robotID int, name string, username string, password string) orchestrator.AssetRobotValue {
+	return orchestrator.AssetRobotValue{RobotID: robotID, RobotName: name, AssetValue: credentialValue(username, password)}
+}
+
+func TestPlanAssets(t *testing.T) {
+
+	storedDefault := credentialValue("svc", "")
+	newDefault := credentialValue("svc", "pw")
+
+	tests := []struct {
+		name          string
+		current       []orchestrator.Asset
+		desired       []orchestrator.Asset
+		prune         bool
+		updateSecrets bool
+		actions       []string
+		changes       []string
+		problems      []string
+	}{
+		{
+			name:    "new asset is created",
+			desired: []orchestrator.Asset{textAsset("Url", "https://a")},
+			actions: []string{assetCreate},
+			changes: []string{""},
+		},
+		{
+			name:    "same text value is unchanged",
+			current: []orchestrator.Asset{textAsset("Url", "https://a")},
+			desired: []orchestrator.Asset{textAsset("url", "https://a")},
+			actions: []string{assetUnchanged},
+			changes: []string{""},
+		},
+		{
+			name:    "changed text value is updated",
+			current: []orchestrator.Asset{textAsset("Url", "https://a")},
+			desired: []orchestrator.Asset{textAsset("Url", "https://b")},
+			actions: []string{assetUpdate},
+			changes: []string{"value"},
+		},
+		{
+			name:     "type change is a problem",
+			current:  []orchestrator.Asset{textAsset("Login", "x")},
+			desired:  []orchestrator.Asset{credentialAsset("Login", &newDefault)},
+			problems: []string{"Login: is a Text asset in the folder.  Delete it to change its type to Credential"},
+		},
+		{
+			name:    "stored password is not compared",
+			current: []orchestrator.Asset{credentialAsset("Login", &storedDefault)},
+			desired: []orchestrator.Asset{credentialAsset("Login", &newDefault)},
+			actions: []string{assetUnchanged},
+			changes: []string{""},
+		},
+		{
+			name:          "stored password is sent with --update-secrets",
+			current:       []orchestrator.Asset{credentialAsset("Login", &storedDefault)},
+			desired:       []orchestrator.Asset{credentialAsset("Login", &newDefault)},
+			updateSecrets: true,
+			actions:       []string{assetUpdate},
+			changes:       []string{"password"},
+		},
+		{
+			name:    "new default credential keeps its password",
+			current: []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", ""))},
+			desired: []orchestrator.Asset{credentialAsset("Login", &newDefault, robotCredential(1, "Bot1", "a", "pw"))},
+			actions: []string{assetUpdate},
+			changes: []string{"value"},
+		},
+		{
+			name:    "added robot credential keeps its password",
+			current: []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", ""))},
+			desired: []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", "pw1"), robotCredential(2, "Bot2", "b", "pw2"))},
+			actions: []string{assetUpdate},
+			changes: []string{"robot Bot2 added"},
+		},
+		{
+			name:     "new robot credential without a password is a problem",
+			current:  []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", ""))},
+			desired:  []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", "pw1"), robotCredential(2, "Bot2", "b", ""))},
+			actions:  []string{assetUpdate},
+			changes:  []string{"robot Bot2 added"},
+			problems: []string{"Login: the value for robot Bot2 needs a password"},
+		},
+		{
+			name:     "new credential asset without a password is a problem",
+			desired:  []orchestrator.Asset{credentialAsset("Login", &storedDefault)},
+			actions:  []string{assetCreate},
+			changes:  []string{""},
+			problems: []string{"Login: the value needs a password"},
+		},
+		{
+			name:    "removed robot is reported",
+			current: []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", ""), robotCredential(2, "Bot2", "b", ""))},
+			desired: []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", "pw1"))},
+			actions: []string{assetUpdate},
+			changes: []string{"robot Bot2 removed"},
+		},
+		{
+			name:    "assets left out of the manifest are kept",
+			current: []orchestrator.Asset{textAsset("Url", "https://a"), textAsset("Old", "x")},
+			desired: []orchestrator.Asset{textAsset("Url", "https://a")},
+			actions: []string{assetUnchanged},
+			changes: []string{""},
+		},
+		{
+			name:    "assets left out of the manifest are deleted with --prune",
+			current: []orchestrator.Asset{textAsset("Url", "https://a"), textAsset("Old", "x")},
+			desired: []orchestrator.Asset{textAsset("Url", "https://a")},
+			prune:   true,
+			actions: []string{assetUnchanged, assetDelete},
+			changes: []string{"", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			plan, problems := planAssets(test.current, test.desired, test.prune, test.updateSecrets)
+
+			var actions, changes []string
+			for _, change := range plan {
+				actions = append(actions, change.Action)
+				changes = append(changes, change.Changes)
+			}
+			if !reflect.DeepEqual(actions, test.actions) {
+				t.Errorf("actions = %q, want %q", actions, test.actions)
+			}
+			if !reflect.DeepEqual(changes, test.changes) {
+				t.Errorf("changes = %q, want %q", changes, test.changes)
+			}
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("problems = %q, want %q", problems, test.problems)
+			}
+		})
+	}
+}
+
+func TestPlanAssetsPasswords(t *testing.T) {
+
+	current := []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", ""))}
+	desired := []orchestrator.Asset{credentialAsset("Login", nil, robotCredential(1, "Bot1", "a", "pw1"), robotCredential(2, "Bot2", "b", "pw2"))}
+
+	plan, _ := planAssets(current, desired, false, false)
+	if len(plan) != 1 {
+		t.Fatalf("got %d changes, want 1", len(plan))
+	}
+
+	passwords := map[string]string{}
+	for _, robotValue := range plan[0].desired.RobotValues {
+		passwords[robotValue.RobotName] = robotValue.CredentialPassword
+	}
+	want := map[string]string{"Bot1": "", "Bot2": "pw2"}
+	if !reflect.DeepEqual(passwords, want) {
+		t.Errorf("passwords sent = %v, want %v", passwords, want)
+	}
+}
+
+func TestValueDifference(t *testing.T) {
+
+	tests := []struct {
+		name string
+		have orchestrator.AssetValue
+		want orchestrator.AssetValue
+		diff string
+	}{
+		{"same integer", orchestrator.AssetValue{ValueType: orchestrator.AssetInteger, IntValue: 3}, orchestrator.AssetValue{ValueType: orchestrator.AssetInteger, IntValue: 3}, ""},
+		{"changed integer", orchestrator.AssetValue{ValueType: orchestrator.AssetInteger, IntValue: 3}, orchestrator.AssetValue{ValueType: orchestrator.AssetInteger, IntValue: 4}, "robot value"},
+		{"changed bool", orchestrator.AssetValue{ValueType: orchestrator.AssetBool}, orchestrator.AssetValue{ValueType: orchestrator.AssetBool, BoolValue: true}, "robot value"},
+		{"changed username", credentialValue("a", ""), credentialValue("b", "pw"), "robot username"},
+		{"password given", credentialValue("a", ""), credentialValue("a", "pw"), "robot password"},
+		{"no password given", credentialValue("a", ""), credentialValue("a", ""), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := strings.Join(valueDifference("robot ", test.have, test.want), ", ")
+			if diff != test.diff {
+				t.Errorf("valueDifference = %q, want %q", diff, test.diff)
+			}
+		})
+	}
+}
+
+func TestAssetsApplyReadsEveryPage(t *testing.T) {
+
+	// The folder already matches the manifest, spread over two pages of assets
+	var changes []string
+	conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			changes = append(changes, r.Method+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if skip := r.URL.Query().Get("$skip"); skip != "" && skip != "0" {
+			w.Write([]byte(`{"value":[]}`))
+			return
+		}
+		switch {
+		case r.URL.Path == orchestrator.RobotsURI:
+			w.Write([]byte(`{"value":[{"Id":1,"Name":"Bot1"},{"Id":2,"Name":"Bot2"},{"Id":3,"Name":"Bot3"}]}`))
+		case r.URL.Query().Get("page") == "2":
+			w.Write([]byte(`{"value":[{"Id":20,"Name":"Region","ValueScope":"PerRobot","ValueType":"Text","RobotValues":[` +
+				`{"RobotId":3,"RobotName":"Bot3","ValueType":"Text","StringValue":"eu"}]}]}`))
+		default:
+			w.Write([]byte(`{"@odata.nextLink":"Assets?page=2","value":[{"Id":10,"Name":"Mailbox","ValueScope":"PerRobot","ValueType":"Text","RobotValues":[` +
+				`{"RobotId":1,"RobotName":"Bot1","ValueType":"Text","StringValue":"bot1@example.com"},` +
+				`{"RobotId":2,"RobotName":"Bot2","ValueType":"Text","StringValue":"bot2@example.com"}]}]}`))
+		}
+	})
+
+	manifest := filepath.Join(t.TempDir(), "assets.yaml")
+	err := ioutil.WriteFile(manifest, []byte(`assets:
+  - name: Mailbox
+    type: Text
+    robots:
+      - robot: Bot1
+        value: bot1@example.com
+      - robot: Bot2
+        value: bot2@example.com
+  - name: Region
+    type: Text
+    robots:
+      - robot: Bot3
+        value: eu
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := CmdAssetsApply{File: manifest, Prune: true, Config: conf}
+	if err = cmd.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("changes sent = %q, want none", changes)
+	}
+}
@@ -0,0 +1,479 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
+)
+
+// CmdAssets groups the commands used to manage assets in the folder saved with
+//  "folders -d".  Robots read their configuration from assets at run time
+type CmdAssets struct {
+	List   CmdAssetsList   `command:"list" description:"List assets in the current folder"`
+	Get    CmdAssetsGet    `command:"get" description:"Show an asset and its per robot values"`
+	Create CmdAssetsCreate `command:"create" description:"Create an asset"`
+	Update CmdAssetsUpdate `command:"update" description:"Change an asset's value, a robot's value or its description"`
+	Delete CmdAssetsDelete `command:"delete" description:"Delete an asset"`
+	Apply  CmdAssetsApply  `command:"apply" description:"Create, update and optionally delete assets to match a YAML or JSON manifest"`
+}
+
+// AssetValueOptions set the value of an asset.  Secret values are read from
+//  stdin or a file so that they never appear on the command line
+type AssetValueOptions struct {
+	Value       string `long:"value" description:"Value of a Text, Integer or Bool asset"`
+	Username    string `long:"username" description:"User name of a Credential asset"`
+	SecretStdin bool   `long:"secret-stdin" description:"Read the credential password, or a secret Text value, from stdin"`
+	SecretFile  string `long:"secret-file" description:"Read the credential password, or a secret Text value, from a file"`
+	Robot       string `long:"robot" description:"Set the value for this robot only.  The asset holds a value per robot"`
+}
+
+type assetNameArg struct {
+	Name string `positional-arg-name:"name" required:"yes" description:"Asset name"`
+}
+
+var assetColumns = []output.Column{
+	{Header: "ASSET ID", Field: "Id"},
+	{Header: "NAME", Field: "Name"},
+	{Header: "TYPE", Field: "ValueType"},
+	{Header: "SCOPE", Field: "ValueScope"},
+	{Header: "VALUE", Field: "Value"},
+	{Header: "DESCRIPTION", Field: "Description", Wide: true},
+}
+
+var assetDetailColumns = []output.Column{
+	{Header: "Asset ID", Field: "Id"},
+	{Header: "Name", Field: "Name"},
+	{Header: "Type", Field: "ValueType"},
+	{Header: "Scope", Field: "ValueScope"},
+	{Header: "Value", Field: "Value"},
+	{Header: "Has Default", Field: "HasDefaultValue"},
+	{Header: "Description", Field: "Description"},
+}
+
+var assetRobotValueColumns = []output.Column{
+	{Header: "ROBOT ID", Field: "RobotId"},
+	{Header: "ROBOT", Field: "RobotName"},
+	{Header: "VALUE", Field: "Value"},
+}
+
+// CmdAssetsList represents the flags supported by the assets list command
+type CmdAssetsList struct {
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewODataPager(orchestrator.AssetsURI, query, paging)
+
+	return renderPages(cmd.Config, assetColumns, pager, &[]orchestrator.Asset{}, "No Assets returned")
+}
+
+// CmdAssetsGet represents the flags supported by the assets get command
+type CmdAssetsGet struct {
+	Args assetNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsGet) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsGet) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	asset, err := client.FindAsset(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	return renderAsset(cmd.Config, "", asset)
+}
+
+// CmdAssetsCreate represents the flags supported by the assets create command
+type CmdAssetsCreate struct {
+	Type        string            `short:"t" long:"type" required:"yes" choice:"Text" choice:"Integer" choice:"Bool" choice:"Credential" description:"Asset value type"`
+	Description string            `short:"d" long:"description" description:"Asset description"`
+	Value       AssetValueOptions `group:"Value Options"`
+	Args        assetNameArg      `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsCreate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsCreate) Execute(args []string) error {
+
+	value := orchestrator.AssetValue{ValueType: cmd.Type}
+	err := cmd.Value.apply(&value, true)
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	newAsset := orchestrator.Asset{
+		Name:        cmd.Args.Name,
+		ValueScope:  orchestrator.AssetGlobal,
+		Description: cmd.Description,
+		RobotValues: []orchestrator.AssetRobotValue{},
+	}
+
+	// An asset created for one robot holds values per robot and has no default
+	if cmd.Value.Robot != "" {
+		robot, err := client.FindRobot(cmd.Value.Robot)
+		if err != nil {
+			return err
+		}
+		newAsset.ValueScope = orchestrator.AssetPerRobot
+		newAsset.ValueType = cmd.Type
+		newAsset.RobotValues = []orchestrator.AssetRobotValue{{RobotID: robot.ID, RobotName: robot.Name, AssetValue: value}}
+	} else {
+		newAsset.AssetValue = value
+		newAsset.HasDefaultValue = true
+	}
+
+	asset, err := client.CreateAsset(newAsset)
+	if err != nil {
+		return err
+	}
+
+	return renderAsset(cmd.Config, "Asset "+asset.Name+" created", asset)
+}
+
+// CmdAssetsUpdate represents the flags supported by the assets update command
+type CmdAssetsUpdate struct {
+	Description string            `short:"d" long:"description" description:"New asset description"`
+	RemoveRobot string            `long:"remove-robot" description:"Remove the value held for this robot"`
+	Value       AssetValueOptions `group:"Value Options"`
+	Args        assetNameArg      `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsUpdate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsUpdate) Execute(args []string) error {
+
+	if !cmd.Value.isSet() && cmd.Description == "" && cmd.RemoveRobot == "" {
+		return errors.New("Nothing to update.  Give a new value, --description or --remove-robot")
+	}
+	if cmd.Value.Robot != "" && cmd.Value.Robot == cmd.RemoveRobot {
+		return errors.New("Use either --robot or --remove-robot for a robot, not both")
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	asset, err := client.FindAsset(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+	if (cmd.Value.Robot != "" || cmd.RemoveRobot != "") && asset.ValueScope != orchestrator.AssetPerRobot {
+		return errors.New("Asset " + asset.Name + " holds one value for every robot.  Create it with --robot to hold values per robot")
+	}
+
+	if cmd.Description != "" {
+		asset.Description = cmd.Description
+	}
+
+	if cmd.RemoveRobot != "" {
+		robot, err := client.FindRobot(cmd.RemoveRobot)
+		if err != nil {
+			return err
+		}
+		index := robotValueIndex(asset.RobotValues, robot.ID)
+		if index < 0 {
+			return errors.New("Asset " + asset.Name + " has no value for robot " + robot.Name)
+		}
+		asset.RobotValues = append(asset.RobotValues[:index], asset.RobotValues[index+1:]...)
+	}
+
+	switch {
+	case cmd.Value.Robot != "":
+		robot, err := client.FindRobot(cmd.Value.Robot)
+		if err != nil {
+			return err
+		}
+		index := robotValueIndex(asset.RobotValues, robot.ID)
+		isNew := index < 0
+		if isNew {
+			asset.RobotValues = append(asset.RobotValues, orchestrator.AssetRobotValue{RobotID: robot.ID, RobotName: robot.Name, AssetValue: orchestrator.AssetValue{ValueType: asset.ValueType}})
+			index = len(asset.RobotValues) - 1
+		}
+		// A new robot value needs all of its fields, an existing one keeps those not given
+		err = cmd.Value.apply(&asset.RobotValues[index].AssetValue, isNew)
+		if err != nil {
+			return err
+		}
+	case cmd.Value.isSet():
+		err = cmd.Value.apply(&asset.AssetValue, !asset.HasDefaultValue)
+		if err != nil {
+			return err
+		}
+		asset.HasDefaultValue = true
+	}
+
+	err = client.UpdateAsset(*asset)
+	if err != nil {
+		return err
+	}
+
+	asset, err = client.GetAsset(asset.ID)
+	if err != nil {
+		return err
+	}
+
+	return renderAsset(cmd.Config, "Asset "+asset.Name+" updated", asset)
+}
+
+// CmdAssetsDelete represents the flags supported by the assets delete command
+type CmdAssetsDelete struct {
+	Args assetNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdAssetsDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdAssetsDelete) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	asset, err := client.FindAsset(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteAsset(asset.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Asset " + asset.Name + " deleted")
+
+	return nil
+}
+
+// isSet reports whether any value option was given
+func (opts AssetValueOptions) isSet() bool {
+	return opts.Value != "" || opts.Username != "" || opts.SecretStdin || opts.SecretFile != ""
+}
+
+// apply sets the options on value, checking them against its type.  When required
+//  is true the value must be complete, e.g. a credential needs both its user name
+//  and password
+func (opts AssetValueOptions) apply(value *orchestrator.AssetValue, required bool) error {
+
+	if opts.SecretStdin && opts.SecretFile != "" {
+		return errors.New("Use either --secret-stdin or --secret-file, not both")
+	}
+	secretSet := opts.SecretStdin || opts.SecretFile != ""
+	if opts.Robot != "" && !opts.isSet() {
+		return errors.New("--robot needs a value to set for the robot")
+	}
+
+	if value.ValueType == orchestrator.AssetCredential {
+		if opts.Value != "" {
+			return errors.New("Credential assets take --username and a password from --secret-stdin or --secret-file, not --value")
+		}
+		if required && (opts.Username == "" || !secretSet) {
+			return errors.New("A credential needs --username and a password from --secret-stdin or --secret-file")
+		}
+	} else {
+		if opts.Username != "" {
+			return errors.New("--username is only used with Credential assets")
+		}
+		if opts.Value != "" && secretSet {
+			return errors.New("Use either --value or a secret value, not both")
+		}
+		if required && opts.Value == "" && !secretSet {
+			return errors.New("A " + value.ValueType + " asset needs --value")
+		}
+		if secretSet && value.ValueType != orchestrator.AssetText {
+			return errors.New("Secret values are only read for Text and Credential assets")
+		}
+	}
+
+	text := opts.Value
+	if secretSet {
+		secret, err := readSecret(opts.SecretStdin, opts.SecretFile)
+		if err != nil {
+			return err
+		}
+		text = secret
+	}
+
+	return setAssetValue(value, text, opts.Username)
+}
+
+// setAssetValue parses text as a value of the asset's type.  For credentials text
+//  is the password.  Empty text or username leave the current value in place
+func setAssetValue(value *orchestrator.AssetValue, text string, username string) error {
+
+	// Orchestrator recomputes the display value from the typed fields
+	value.Value = ""
+	switch value.ValueType {
+	case orchestrator.AssetText:
+		if text != "" {
+			value.StringValue = text
+		}
+	case orchestrator.AssetInteger:
+		if text != "" {
+			number, err := strconv.Atoi(text)
+			if err != nil {
+				return errors.New("'" + text + "' is not a valid Integer value")
+			}
+			value.IntValue = number
+		}
+	case orchestrator.AssetBool:
+		if text != "" {
+			flag, err := strconv.ParseBool(text)
+			if err != nil {
+				return errors.New("'" + text + "' is not a valid Bool value.  Use true or false")
+			}
+			value.BoolValue = flag
+		}
+	case orchestrator.AssetCredential:
+		if username != "" {
+			value.CredentialUsername = username
+		}
+		value.CredentialPassword = text
+	default:
+		return errors.New("Unsupported asset type " + value.ValueType)
+	}
+
+	return nil
+}
+
+// readSecret reads a secret from stdin or a file.  One trailing line break is
+//  removed, as files and echo usually end with one
+func readSecret(stdin bool, file string) (string, error) {
+
+	var raw []byte
+	var err error
+	switch {
+	case stdin && util.IsTerminal(os.Stdin):
+		fmt.Fprint(os.Stderr, "Secret value: ")
+		var line string
+		line, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		raw = []byte(line)
+	case stdin:
+		raw, err = ioutil.ReadAll(os.Stdin)
+	default:
+		raw, err = ioutil.ReadFile(file)
+	}
+	if err != nil && len(raw) == 0 {
+		return "", errors.New("Unable to read the secret value: " + err.Error())
+	}
+
+	secret := strings.TrimSuffix(strings.TrimSuffix(string(raw), "\n"), "\r")
+	if secret == "" {
+		return "", errors.New("The secret value is empty")
+	}
+
+	return secret, nil
+}
+
+func robotValueIndex(values []orchestrator.AssetRobotValue, robotID int) int {
+
+	for i, value := range values {
+		if value.RobotID == robotID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// renderAsset writes an asset followed by its per robot values, preceded by a
+//  status message in the human readable formats when one is given
+func renderAsset(conf Config, message string, asset *orchestrator.Asset) error {
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	if !renderer.IsHuman() {
+		return renderer.RenderOne(assetColumns, asset)
+	}
+
+	if message != "" {
+		fmt.Println(message)
+		fmt.Println("")
+	}
+	err = renderer.RenderDetails(assetDetailColumns, asset)
+	if err != nil || asset.ValueScope != orchestrator.AssetPerRobot {
+		return err
+	}
+
+	fmt.Println("")
+	if len(asset.RobotValues) == 0 {
+		fmt.Println("No robot values")
+		return nil
+	}
+
+	return renderer.Render(assetRobotValueColumns, asset.RobotValues)
+}
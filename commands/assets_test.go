@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func TestAssetsCreate(t *testing.T) {
+
+	secretFile := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(secretFile, []byte("p@ss word\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	typedAsset := func(value orchestrator.AssetValue) *orchestrator.Asset {
+		return &orchestrator.Asset{Name: "Setting", ValueScope: orchestrator.AssetGlobal, HasDefaultValue: true, AssetValue: value, RobotValues: []orchestrator.AssetRobotValue{}}
+	}
+	secretText := textAsset("Setting", "p@ss word")
+	password := credentialValue("svc", "p@ss word")
+	sharedCredential := credentialAsset("Setting", &password)
+	sharedCredential.RobotValues = []orchestrator.AssetRobotValue{}
+	robotOnly := credentialAsset("Setting", nil, robotCredential(3, "Bot3", "svc", "p@ss word"))
+
+	tests := []struct {
+		name  string
+		cmd   CmdAssetsCreate
+		asset *orchestrator.Asset
+		err   string
+	}{
+		{"text", CmdAssetsCreate{Type: "Text", Value: AssetValueOptions{Value: "hello"}}, typedAsset(orchestrator.AssetValue{ValueType: "Text", StringValue: "hello"}), ""},
+		{"secret text", CmdAssetsCreate{Type: "Text", Value: AssetValueOptions{SecretFile: secretFile}}, &secretText, ""},
+		{"integer", CmdAssetsCreate{Type: "Integer", Value: AssetValueOptions{Value: "42"}}, typedAsset(orchestrator.AssetValue{ValueType: "Integer", IntValue: 42}), ""},
+		{"bool", CmdAssetsCreate{Type: "Bool", Value: AssetValueOptions{Value: "true"}}, typedAsset(orchestrator.AssetValue{ValueType: "Bool", BoolValue: true}), ""},
+		{"credential", CmdAssetsCreate{Type: "Credential", Value: AssetValueOptions{Username: "svc", SecretFile: secretFile}}, &sharedCredential, ""},
+		{"credential for one robot", CmdAssetsCreate{Type: "Credential", Value: AssetValueOptions{Username: "svc", SecretFile: secretFile, Robot: "Bot3"}}, &robotOnly, ""},
+		{"invalid integer", CmdAssetsCreate{Type: "Integer", Value: AssetValueOptions{Value: "4x"}}, nil, "'4x' is not a valid Integer value"},
+		{"invalid bool", CmdAssetsCreate{Type: "Bool", Value: AssetValueOptions{Value: "maybe"}}, nil, "'maybe' is not a valid Bool value.  Use true or false"},
+		{"missing value", CmdAssetsCreate{Type: "Integer"}, nil, "A Integer asset needs --value"},
+		{"secret integer", CmdAssetsCreate{Type: "Integer", Value: AssetValueOptions{SecretFile: secretFile}}, nil, "Secret values are only read for Text and Credential assets"},
+		{"password on the command line", CmdAssetsCreate{Type: "Credential", Value: AssetValueOptions{Username: "svc", Value: "p@ss"}}, nil, "Credential assets take --username and a password from --secret-stdin or --secret-file, not --value"},
+		{"credential without a password", CmdAssetsCreate{Type: "Credential", Value: AssetValueOptions{Username: "svc"}}, nil, "A credential needs --username and a password from --secret-stdin or --secret-file"},
+		{"username for a text asset", CmdAssetsCreate{Type: "Text", Value: AssetValueOptions{Value: "x", Username: "svc"}}, nil, "--username is only used with Credential assets"},
+		{"missing secret file", CmdAssetsCreate{Type: "Text", Value: AssetValueOptions{SecretFile: secretFile + ".missing"}}, nil, "Unable to read the secret value: "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var created *orchestrator.Asset
+			conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method + " " + r.URL.Path {
+				case "GET /odata/Robots":
+					if r.URL.Query().Get("$filter") != "Name eq 'Bot3'" {
+						t.Errorf("robot filter = %q", r.URL.Query().Get("$filter"))
+					}
+					fmt.Fprint(w, `{"value":[{"Id":3,"Name":"Bot3"}]}`)
+				case "POST /odata/Assets":
+					created = &orchestrator.Asset{}
+					body, _ := ioutil.ReadAll(r.Body)
+					if err := json.Unmarshal(body, created); err != nil {
+						t.Fatal(err)
+					}
+					w.Write(body)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+			conf.GlobalFlgs.OutputFormat = "json"
+			test.cmd.Config = conf
+			test.cmd.Args.Name = "Setting"
+
+			err := test.cmd.Execute(nil)
+			if (err == nil) != (test.err == "") || (err != nil && !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if !reflect.DeepEqual(created, test.asset) {
+				t.Errorf("created %+v, want %+v", created, test.asset)
+			}
+		})
+	}
+}
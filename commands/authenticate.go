@@ -1,28 +1,30 @@
 package commands
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
-	"time"
 
 	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/orchestrator"
 	"github.com/bcsimms/uipo/util"
 )
 
 // CmdAuthenticate is the struct that represents the command line options for the "authenticate" command
 //   UserID and RefreshToken are mutually exclusive and used to determine the authentication mode
-//   Two modes supported:
+//   Four modes supported:
 //		1)  On-Premise - using basic authentication to retrieve a bearer token
 //		2)  UiPath Platform (SaaS) - using a refresh token to retrieve a bearer token
-//   Either mode results in a Bearer Token used for subsequent calls to the UiPath Orchestrator
+//		3)  External Application - using the OAuth client credentials grant against the identity server
+//		4)  Interactive - browser sign in using the authorization code grant with PKCE
+//   Any mode results in a Bearer Token used for subsequent calls to the UiPath Orchestrator
 // Some data needed for authentication will be cahced in the .uipo/config.json file for streamlined authentication requests
 //   This includes:
 //     - Authentication endpoint
 //     - Refresh token
+//     - Access token expiry, so later commands can renew the token before it lapses
 //     - [add more here as we go]
 type CmdAuthenticate struct {
 	AuthorizationEndpoint string `short:"e" long:"endpoint" description:"API endpoint (e.g. https://api.example.com)"`
@@ -31,42 +33,17 @@ type CmdAuthenticate struct {
 	Password              string `short:"p" long:"password" description:"Password - Used for on-premise installations"`
 	RefreshToken          string `short:"r" long:"refreh-token" description:"Refresh Token - Used for UiPath Platform Installations"`
 	ClientID              string `short:"c" long:"client-id" default:"5v7PmPJL6FOGu6RB8I1Y4adLBhIwovQN" descritpion:"Client ID - Used for UiPath Platform Installations.  Should not need to be overridden"`
+	IdentityURL           string `short:"i" long:"identity-url" description:"Identity server base URL (e.g. https://cloud.uipath.com/identity_) - Used for external applications"`
+	AppID                 string `long:"app-id" description:"App ID - Used for external applications, with --app-secret for confidential applications or with --interactive for non-confidential ones"`
+	AppSecret             string `long:"app-secret" description:"App Secret - Used for confidential external applications.  May also be set with UIPO_APP_SECRET"`
+	Scopes                string `long:"scope" description:"Space separated application scopes - Used for external applications (e.g. \"OR.Jobs OR.Queues\")"`
+	Interactive           bool   `long:"interactive" description:"Sign in through the browser using a non-confidential external application (requires --app-id)"`
+	NoBrowser             bool   `long:"no-browser" description:"Only print the sign in URL for interactive authentication instead of opening a browser"`
+	RedirectPort          int    `long:"redirect-port" default:"12700" description:"Loopback port receiving the interactive sign in redirect at http://127.0.0.1:<port>.  Must match the redirect URL registered on the application.  0 uses any free port"`
 
 	Config Config
 }
 
-// Used when making authentication calls into a UiPath hosted Orchestrator
-type requestBodyHosted struct {
-	GrantType    string `json:"grant_type"`
-	ClientID     string `json:"client_id"`
-	RefreshToken string `json:"refresh_token"`
-}
-
-// Used when making authentication calls into a Orchestrator hosted on-premise
-type requestBodyOnPrem struct {
-	TenantName string `json:"tenancyName"`
-	UserID     string `json:"usernameOrEmailAddress"`
-	Password   string `json:"password"`
-}
-
-// Return structure for a hosted authentication call
-type responseHosted struct {
-	AccessToken string `json:"access_token"`
-	IDToken     string `json:"id_token"`
-	Scope       string `json:"scope"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
-}
-
-type responseOnPrem struct {
-	AccessToken         string `json:"result"`
-	TargetURL           string `json:"targetUrl"`
-	Success             bool   `json:"success"`
-	Error               string `json:"error"`
-	UnAuthorizedRequest bool   `json:"unAuthorizedRequest"`
-	ABP                 bool   `json:"__abp"`
-}
-
 // The ahtentication type, determined by the arugments provided
 type authType int
 
@@ -74,6 +51,8 @@ const (
 	authInvalid authType = iota
 	authOnPrem
 	authHosted
+	authClientCredentials
+	authInteractive
 )
 
 // Setup is the override of the ExtendedCommander
@@ -82,6 +61,11 @@ func (cmd *CmdAuthenticate) Setup(config Config) error {
 	util.LogDebug("Starting Authenticate Setup")
 	cmd.Config = config
 
+	err := cmd.Config.UnlockSecrets()
+	if err != nil {
+		return err
+	}
+
 	//Setup cached values from config file
 	if cmd.Config.GetAuthorizationEndpoint() != "" {
 		cmd.AuthorizationEndpoint = cmd.Config.GetAuthorizationEndpoint()
@@ -89,6 +73,12 @@ func (cmd *CmdAuthenticate) Setup(config Config) error {
 	if cmd.Config.GetRefreshToken() != "" {
 		cmd.RefreshToken = cmd.Config.GetRefreshToken()
 	}
+	if cmd.IdentityURL == "" {
+		cmd.IdentityURL = cmd.Config.GetIdentityEndpoint()
+	}
+	if cmd.Scopes == "" {
+		cmd.Scopes = cmd.Config.GetScopes()
+	}
 
 	return nil
 }
@@ -103,113 +93,141 @@ func (cmd *CmdAuthenticate) Execute(args []string) error {
 		return err
 	}
 
+	httpClient := &http.Client{}
+
 	if authMode == authHosted {
 
 		util.LogDebug("Authentication Mode Hosted")
 
 		cmd.Config.SetEndpointType(config.EndpointTypeHosted)
+		cmd.Config.SetAuthType("")
 
-		reqBody := requestBodyHosted{}
-		reqBody.GrantType = "refresh_token"
-		reqBody.ClientID = cmd.ClientID
-		reqBody.RefreshToken = cmd.RefreshToken
-
-		requestBody, err := json.Marshal(&reqBody)
+		token, err := orchestrator.RefreshTokenGrant(httpClient, cmd.AuthorizationEndpoint, cmd.ClientID, cmd.RefreshToken)
 		if err != nil {
 			return err
 		}
 
-		// Send the authentication request
-		util.LogDebug("Sending authentication request")
-		resp, err := http.Post(cmd.AuthorizationEndpoint, "application/json", strings.NewReader(string(requestBody)))
-
-		if err != nil {
-			return err
+		// Save our Access Token (Bearer token) to cache in the config file
+		cmd.Config.SetAccessToken(token.AccessToken)
+		cmd.Config.SetAccessTokenExpiry(token.Expiry)
+		if token.RefreshToken != "" {
+			cmd.Config.SetRefreshToken(token.RefreshToken)
 		}
 
-		util.LogDebug("Reading response body")
-		body, readErr := ioutil.ReadAll(resp.Body)
-		util.LogDebug("Response was: \n" + string(body))
-		if readErr != nil {
-			return readErr
+		if cmd.Config.GetAuthorizationEndpoint() == "" {
+			cmd.Config.SetAuthorizationEndpoint(cmd.AuthorizationEndpoint)
 		}
-		defer resp.Body.Close()
 
-		oauthResp := responseHosted{}
+		fmt.Println("Authentication successful.  Bearer token cached for future requests.")
+		fmt.Println("Expires on: ", token.Expiry)
+
+	} else if authMode == authOnPrem {
+
+		util.LogDebug("Authentication Mode On-Premise")
 
-		util.LogDebug("Reading JSon response data")
-		jsonErr := json.Unmarshal(body, &oauthResp)
-		if jsonErr != nil {
-			return jsonErr
+		cmd.Config.SetEndpointType(config.EndpointTypeOnPremise)
+		cmd.Config.SetAuthType("")
+
+		token, err := orchestrator.OnPremAuthenticate(httpClient, cmd.AuthorizationEndpoint, cmd.Tenant, cmd.UserID, cmd.Password)
+		if err != nil {
+			return err
 		}
 
 		// Save our Access Token (Bearer token) to cache in the config file
-		cmd.Config.SetAccessToken(oauthResp.AccessToken)
+		cmd.Config.SetAccessToken(token.AccessToken)
+		cmd.Config.SetAccessTokenExpiry(token.Expiry)
+		cmd.Config.SetTenantName(cmd.Tenant)
 
+		if cmd.Config.GetRefreshToken() == "" {
+			cmd.Config.SetRefreshToken(cmd.RefreshToken)
+		}
 
 		if cmd.Config.GetAuthorizationEndpoint() == "" {
 			cmd.Config.SetAuthorizationEndpoint(cmd.AuthorizationEndpoint)
 		}
 
 		fmt.Println("Authentication successful.  Bearer token cached for future requests.")
+		fmt.Println("Expires on: ", token.Expiry)
+		if cmd.Config.GetUIPOUsername() == "" || cmd.Config.GetUIPOPassword() == "" {
+			fmt.Println("Set UIPO_USERNAME and UIPO_PASSWORD to allow the token to be renewed automatically.")
+		}
 
-		expiryDateTime := time.Now().Add(time.Second * time.Duration(oauthResp.ExpiresIn))
-
-		fmt.Println("Expires on: ", expiryDateTime)
-
-	} else if authMode == authOnPrem {
-
-		util.LogDebug("Authentication Mode On-Premise")
-
-		cmd.Config.SetEndpointType(config.EndpointTypeOnPremise)
+	} else if authMode == authClientCredentials {
 
-		reqBody := requestBodyOnPrem{}
-		reqBody.TenantName = cmd.Tenant
-		reqBody.UserID = cmd.UserID
-		reqBody.Password = cmd.Password
+		util.LogDebug("Authentication Mode Client Credentials")
 
-		requestBody, err := json.Marshal(&reqBody)
-		if err != nil {
-			return err
+		// External applications exist for both hosted and on-premise identity servers,
+		//   so only default the endpoint type when one has not been cached yet
+		if cmd.Config.GetEndpointType() == "" {
+			cmd.Config.SetEndpointType(config.EndpointTypeHosted)
 		}
 
-		util.LogDebug("Sending authentication request")
-		// Send the authentication request
-		resp, err := http.Post(cmd.AuthorizationEndpoint, "application/json", strings.NewReader(string(requestBody)))
-
+		token, err := orchestrator.ClientCredentialsGrant(httpClient, orchestrator.TokenEndpoint(cmd.IdentityURL), cmd.AppID, cmd.AppSecret, cmd.Scopes)
 		if err != nil {
 			return err
 		}
 
-		util.LogDebug("Reading response body")
-		body, readErr := ioutil.ReadAll(resp.Body)
-		util.LogDebug("Response was: \n" + string(body))
-		if readErr != nil {
-			return readErr
+		// Save everything needed to re-acquire the token non-interactively
+		cmd.Config.SetAuthType(config.AuthTypeClientCredentials)
+		cmd.Config.SetAccessToken(token.AccessToken)
+		cmd.Config.SetAccessTokenExpiry(token.Expiry)
+		cmd.Config.SetIdentityEndpoint(cmd.IdentityURL)
+		cmd.Config.SetAppID(cmd.AppID)
+		if token.Scope != "" {
+			cmd.Config.SetScopes(token.Scope)
+		} else {
+			cmd.Config.SetScopes(cmd.Scopes)
+		}
+		// A secret supplied through UIPO_APP_SECRET stays in the environment rather than the config file
+		if cmd.Config.GetAppSecret() != cmd.AppSecret {
+			cmd.Config.SetAppSecret(cmd.AppSecret)
 		}
-		defer resp.Body.Close()
 
-		//fmt.Println(string(body))
-		oauthResp := responseOnPrem{}
+		fmt.Println("Authentication successful.  Bearer token cached for future requests.")
+		fmt.Println("Expires on: ", token.Expiry)
+		fmt.Println("     Scope: ", cmd.Config.GetScopes())
+
+	} else if authMode == authInteractive {
 
-		util.LogDebug("Reading JSon response data")
-		jsonErr := json.Unmarshal(body, &oauthResp)
-		if jsonErr != nil {
-			return jsonErr
+		util.LogDebug("Authentication Mode Interactive")
+
+		if cmd.Config.GetEndpointType() == "" {
+			cmd.Config.SetEndpointType(config.EndpointTypeHosted)
 		}
 
-		// Save our Access Token (Bearer token) to cache in the config file
-		cmd.Config.SetAccessToken(oauthResp.AccessToken)
+		// Without offline_access the identity server does not issue a refresh token
+		scopes := cmd.Scopes
+		if !strings.Contains(" "+scopes+" ", " offline_access ") {
+			scopes = strings.TrimSpace(scopes + " offline_access")
+		}
 
-		if cmd.Config.GetRefreshToken() == "" {
-			cmd.Config.SetRefreshToken(cmd.RefreshToken)
+		login := orchestrator.InteractiveLogin{
+			IdentityURL: cmd.IdentityURL,
+			AppID:       cmd.AppID,
+			Scope:       scopes,
+			Port:        cmd.RedirectPort,
+			Out:         os.Stdout,
+			HTTPClient:  httpClient,
+		}
+		if !cmd.NoBrowser {
+			login.OpenBrowser = util.OpenBrowser
 		}
 
-		if cmd.Config.GetAuthorizationEndpoint() == "" {
-			cmd.Config.SetAuthorizationEndpoint(cmd.AuthorizationEndpoint)
+		token, err := login.Login()
+		if err != nil {
+			return err
 		}
 
+		cmd.Config.SetAuthType(config.AuthTypeAuthorizationCode)
+		cmd.Config.SetAccessToken(token.AccessToken)
+		cmd.Config.SetAccessTokenExpiry(token.Expiry)
+		cmd.Config.SetRefreshToken(token.RefreshToken)
+		cmd.Config.SetIdentityEndpoint(cmd.IdentityURL)
+		cmd.Config.SetAppID(cmd.AppID)
+		cmd.Config.SetScopes(scopes)
+
 		fmt.Println("Authentication successful.  Bearer token cached for future requests.")
+		fmt.Println("Expires on: ", token.Expiry)
 
 	}
 
@@ -231,6 +249,31 @@ func (cmd *CmdAuthenticate) validateFlags() (authType, error) {
 		return authOnPrem, nil
 	}
 
+	// Interactive sign in uses a non-confidential application, so no secret is needed
+	if cmd.Interactive {
+
+		if cmd.AppID == "" {
+			cmd.AppID = cmd.Config.GetAppID()
+		}
+		if cmd.AppID == "" || cmd.IdentityURL == "" {
+			return authInvalid, errors.New("When using interactive authentication, an app id and identity url are required arguments")
+		}
+		return authInteractive, nil
+	}
+
+	// An App ID selects the external application (client credentials) flow
+	//  The secret may come from the flag, UIPO_APP_SECRET or the cached config
+	if cmd.AppID != "" {
+
+		if cmd.AppSecret == "" {
+			cmd.AppSecret = cmd.Config.GetAppSecret()
+		}
+		if cmd.AppSecret == "" || cmd.IdentityURL == "" {
+			return authInvalid, errors.New("When using external application authentication, an app secret and identity url are required arguments")
+		}
+		return authClientCredentials, nil
+	}
+
 	// If we are using a hosted Orchestrator, make sure we have the flags we need
 	if cmd.RefreshToken != "" {
 
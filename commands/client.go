@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// newFolderClient creates a client for commands that work on folder resources
+// such as jobs and processes.  It fails early when no folder has been selected
+func newFolderClient(conf Config) (*orchestrator.Client, error) {
+
+	if conf.GetFolderID() == 0 {
+		return nil, noFolderError(conf)
+	}
+
+	return orchestrator.NewClient(conf)
+}
+
+func noFolderError(conf Config) error {
+	return errors.New("No folder selected.  Use '" + conf.GetBinaryName() + " folders -f <name> -d' to set the default folder")
+}
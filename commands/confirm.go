@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bcsimms/uipo/util"
+)
+
+// confirmDelete asks on the terminal before something is deleted.  --yes skips
+//  the question, and when there is no terminal to ask on --yes is required
+func confirmDelete(description string, yes bool) error {
+
+	if yes {
+		return nil
+	}
+	if !util.IsTerminal(os.Stdin) {
+		return errors.New("Deleting " + description + " cannot be undone.  Give --yes to delete without a prompt, or --dry-run to see what would be deleted")
+	}
+
+	fmt.Fprint(os.Stderr, "Delete "+description+"? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+
+	return errors.New("Nothing was deleted")
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"strconv"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+)
+
+// Results of updating a process to a newly uploaded package version
+const (
+	deployUpdated  string = "Updated"
+	deployUpToDate string = "Up to date"
+	deployFailed   string = "Failed"
+)
+
+// deployment reports the update of one process in one folder
+type deployment struct {
+	Package     string `json:"Package"`
+	Folder      string `json:"Folder"`
+	FolderID    int    `json:"FolderId"`
+	Process     string `json:"Process"`
+	FromVersion string `json:"FromVersion"`
+	ToVersion   string `json:"ToVersion"`
+	Result      string `json:"Result"`
+	Error       string `json:"Error,omitempty"`
+}
+
+var deploymentColumns = []output.Column{
+	{Header: "PACKAGE", Field: "Package"},
+	{Header: "FOLDER", Field: "Folder"},
+	{Header: "PROCESS", Field: "Process"},
+	{Header: "FROM", Field: "FromVersion"},
+	{Header: "TO", Field: "ToVersion"},
+	{Header: "RESULT", Field: "Result"},
+	{Header: "ERROR", Field: "Error"},
+	{Header: "FOLDER ID", Field: "FolderId", Wide: true},
+}
+
+// resolveDeployFolders returns the folders named with --deploy-folder, every folder
+//  the user can see with allFolders, or otherwise the default folder
+func resolveDeployFolders(conf Config, client *orchestrator.Client, names []string, allFolders bool) ([]orchestrator.Folder, error) {
+
+	if allFolders {
+		return client.ListAllFolders()
+	}
+
+	if len(names) == 0 {
+		if conf.GetFolderID() == 0 {
+			return nil, noFolderError(conf)
+		}
+		return []orchestrator.Folder{{ID: conf.GetFolderID(), FullyQualifiedName: conf.GetFolderFQN()}}, nil
+	}
+
+	var folders []orchestrator.Folder
+	for _, name := range names {
+		folder, err := client.FindFolder(name)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, *folder)
+	}
+
+	return folders, nil
+}
+
+// deployPackage updates every process that runs the package in the given folders
+//  to the new version.  A failure in one folder does not stop the others
+func deployPackage(client *orchestrator.Client, packageID string, version string, folders []orchestrator.Folder) []deployment {
+
+	deployments := []deployment{}
+	for _, folder := range folders {
+
+		folderName := folder.FullyQualifiedName
+		if folderName == "" {
+			folderName = strconv.Itoa(folder.ID)
+		}
+		folderClient := client.InFolder(folder.ID)
+
+		query := orchestrator.ODataQuery{Filter: "ProcessKey eq " + orchestrator.ODataString(packageID)}
+		releases, err := folderClient.ListReleases(query, orchestrator.Paging{All: true})
+		if err != nil {
+			deployments = append(deployments, deployment{
+				Package:   packageID,
+				Folder:    folderName,
+				FolderID:  folder.ID,
+				ToVersion: version,
+				Result:    deployFailed,
+				Error:     err.Error(),
+			})
+			continue
+		}
+
+		for _, release := range releases {
+			result := deployment{
+				Package:     packageID,
+				Folder:      folderName,
+				FolderID:    folder.ID,
+				Process:     release.Name,
+				FromVersion: release.ProcessVersion,
+				ToVersion:   version,
+				Result:      deployUpToDate,
+			}
+			if release.ProcessVersion != version {
+				err = folderClient.UpdateReleaseVersion(release.ID, version)
+				if err != nil {
+					result.Result = deployFailed
+					result.Error = err.Error()
+				} else {
+					result.Result = deployUpdated
+				}
+			}
+			deployments = append(deployments, result)
+		}
+	}
+
+	return deployments
+}
@@ -0,0 +1,21 @@
+package commands
+
+// Process exit codes returned by uipo so that scripts and CI steps can tell
+//  failures apart
+const (
+	ExitCodeSuccess    int = 0
+	ExitCodeError      int = 1
+	ExitCodeJobFaulted int = 2
+	ExitCodeJobStopped int = 3
+	ExitCodeTimeout    int = 4
+)
+
+// ExitError is returned by commands that need a specific process exit code
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (err *ExitError) Error() string {
+	return err.Message
+}
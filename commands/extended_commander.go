@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"time"
+
 	"github.com/jessevdk/go-flags"
 )
 
@@ -22,6 +24,34 @@ type Config interface {
 	GetEndpointType() string
 	SetEndpointType(string)
 	SetAccessToken(string)
+	GetAccessTokenExpiry() time.Time
+	SetAccessTokenExpiry(time.Time)
+	GetTenantName() string
+	SetTenantName(string)
+	GetAuthType() string
+	SetAuthType(string)
+	GetIdentityEndpoint() string
+	SetIdentityEndpoint(string)
+	GetAppID() string
+	SetAppID(string)
+	GetAppSecret() string
+	SetAppSecret(string)
+	GetScopes() string
+	SetScopes(string)
+	GetProfileName() string
+	SelectProfile(string) error
+	GetDefaultProfile() string
+	ProfileNames() []string
+	UseProfile(string) error
+	CreateProfile(name string, copyFrom string) error
+	DeleteProfile(string) error
+	RenameProfile(oldName string, newName string) error
+	GetSecretStore() string
+	SetSecretStore(string) error
+	UnlockSecrets() error
+	SetSecretKeyFile(string)
+	GetOutputFormat() string
+	GetOutputQuery() string
 	GetUIPOPassword() string
 	GetUIPOUsername() string
 	GetAccountLogicalName() string
@@ -40,6 +70,8 @@ type Config interface {
 	SetFolderDescription(string)
 	GetFolderParentID() int
 	SetFolderParentID(int)
+	GetAsyncTimeout() int
+	SetAsyncTimeout(int)
 }
 
 // ExtendedCommander is a type used for add a Setup function to commands
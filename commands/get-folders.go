@@ -1,13 +1,10 @@
 package commands
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"net/http"
 
-	"github.com/bcsimms/uipo/config"
-	"github.com/bcsimms/uipo/util"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
 )
 
 // CmdGetFolders represetns the flags this command supports
@@ -15,56 +12,45 @@ type CmdGetFolders struct {
 	APIEndpoint        string `short:"e" long:"api-endpoint" description:"API endpoint (e.g. https://api.example.com)"`
 	AccountLogicalName string `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
 	ServiceLogicalName string `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
-	FilteredFolders    string `short:"f" long:"filter" description:"Run operation as the filtered version using the String as the filter criterial"`
-	Skip               string `short:"x" long:"skip" default:"0" description:"For use when using the filtered version.  Will skip some number of entries"`
-	Take               string `short:"y" long:"take" default:"10" description:"For use when using the filtered version.  Determines how many results to return"`
+	FilteredFolders    string `short:"f" long:"search" description:"Search the current user's folders using the String as the search criteria.  Only --top and --skip apply to a search"`
 	SetDefaultFolder   bool   `short:"d" long:"set-default" description:"Setting this flag to true will persist the fisrt folder result as the default"`
 
-	Config Config
-}
-
-type filteredFoldersResp struct {
-	FolderEntries []filteredFolderEntry `json:"PageItems"`
-	Count         int                   `json:"Count"`
-}
+	OData ODataOptions `group:"OData Query Options"`
 
-type filteredFolderEntry struct {
-	DisplayName        string `json:"DisplayName"`
-	FullyQualifiedName string `json:"FullyQualifiedName"`
-	Description        string `json:"Description"`
-	ProvisionType      string `json:"ProvisionType"`
-	PermissionModel    string `json:"PermissionModel"`
-	ParentID           int    `json:"ParentId"`
-	ID                 int    `json:"Id"`
+	Config Config
 }
 
-type allFolderEntry struct {
-	IsSelectable       bool   `json:"IsSelectable"`
-	HasChildren        bool   `json:"HasChildren"`
-	Level              int    `json:"Level"`
-	DisplayName        string `json:"DisplayName"`
-	FullyQualifiedName string `json:"FullyQualifiedName"`
-	Description        string `json:"Description"`
-	ProvisionType      string `json:"ProvisionType"`
-	PermissionModel    string `json:"PermissionModel"`
-	ParentID           int    `json:"ParentId"`
-	ID                 int    `json:"Id"`
+var folderColumns = []output.Column{
+	{Header: "FOLDER ID", Field: "Id"},
+	{Header: "DISPLAY NAME", Field: "DisplayName"},
+	{Header: "FULLY QUALIFIED NAME", Field: "FullyQualifiedName"},
+	{Header: "PARENT ID", Field: "ParentId"},
+	{Header: "HAS CHILDREN", Field: "HasChildren", Wide: true},
+	{Header: "DESCRIPTION", Field: "Description", Wide: true},
+	{Header: "PROVISION TYPE", Field: "ProvisionType", Wide: true},
+	{Header: "PERMISSION MODEL", Field: "PermissionModel", Wide: true},
 }
 
 // Setup is the standard setup function - override of the go-flags interface function
-func (cmd *CmdGetFolders) Setup(config Config) error {
+func (cmd *CmdGetFolders) Setup(conf Config) error {
 
-	cmd.Config = config
+	cmd.Config = conf
 
 	// If we have input flag overrides, use those instead of cached values
 	if cmd.APIEndpoint == "" {
 		cmd.APIEndpoint = cmd.Config.GetAPIEndpoint()
+	} else {
+		cmd.Config.SetAPIEndpoint(cmd.APIEndpoint)
 	}
 	if cmd.AccountLogicalName == "" {
 		cmd.AccountLogicalName = cmd.Config.GetAccountLogicalName()
+	} else {
+		cmd.Config.SetAccountLogicalName(cmd.AccountLogicalName)
 	}
 	if cmd.ServiceLogicalName == "" {
 		cmd.ServiceLogicalName = cmd.Config.GetServiceLogicalName()
+	} else {
+		cmd.Config.SetServiceLogicalName(cmd.ServiceLogicalName)
 	}
 
 	return nil
@@ -73,143 +59,83 @@ func (cmd *CmdGetFolders) Setup(config Config) error {
 // Execute is the main entry poing for the command - override of the go-flags interface function
 func (cmd *CmdGetFolders) Execute(args []string) error {
 
-	var err error
-	if cmd.FilteredFolders != "" {
-		err = cmd.getFilteredFolders()
-	} else {
-		err = cmd.getAllFolders()
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
 	}
 
-	// If we have a successful execution, do some housekeeping
-	if err == nil {
-		// Save arguments to config
-		if cmd.APIEndpoint != "" {
-			cmd.Config.SetAPIEndpoint(cmd.APIEndpoint)
-		}
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
 	}
-	return err
-}
-
-// Usage is the override for the go-flags interface function
-func (cmd *CmdGetFolders) Usage() string {
-	usageStr := "[-e API Endpoint URL] [-f Filter Condition] [-x Skip entries] [-y How many entries to return] [-d Sets first returned entry, if any, as default]"
-	return usageStr
-}
 
-func (cmd *CmdGetFolders) getFilteredFolders() error {
-
-	var foldersEndpoint string
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
 
-	if cmd.Config.GetEndpointType() == config.EndpointTypeHosted {
-		foldersEndpoint = cmd.APIEndpoint + "/" + cmd.AccountLogicalName + "/" + cmd.ServiceLogicalName + "/api/FoldersNavigation/GetFoldersForCurrentUser"
-	} else if cmd.Config.GetEndpointType() == config.EndpointTypeOnPremise {
-		foldersEndpoint = cmd.APIEndpoint + "/api/FoldersNavigation/GetFoldersForCurrentUser"
+	// A search goes through the FoldersNavigation API, which only supports paging.
+	//   Query options list the tenant's folders through OData, and with neither we
+	//   list every folder the current user can see
+	var folders []orchestrator.Folder
+	if cmd.FilteredFolders != "" {
+		if query.Filter != "" || query.Select != "" || query.OrderBy != "" || query.Expand != "" || paging.All {
+			return errors.New("--search only supports --top and --skip.  Use --filter without --search to query folders with OData")
+		}
+		take := query.Top
+		if take == 0 {
+			take = 10
+		}
+		folders, err = client.SearchFolders(cmd.FilteredFolders, query.Skip, take)
+	} else if len(query.Values()) > 0 || paging.All {
+		// Pages are written as they arrive, so only the first folder is read for -d
+		if cmd.SetDefaultFolder {
+			first := query
+			first.Select = ""
+			first.Expand = ""
+			first.Top = 1
+			folders, err = client.ListFolders(first, orchestrator.Paging{})
+			if err != nil {
+				return err
+			}
+			cmd.setDefaultFolder(folders)
+		}
+		return renderPages(cmd.Config, folderColumns, client.NewFoldersPager(query, paging), &[]orchestrator.Folder{}, "No Folders returned")
 	} else {
-		return errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
+		folders, err = client.ListAllFolders()
 	}
-
-	client := http.Client{}
-	req, _ := http.NewRequest("GET", foldersEndpoint, nil)
-
-	// Add our required request headers
-	req.Header.Add("X-UIPATH-TenantName", cmd.ServiceLogicalName)
-	req.Header.Add("Authorization", "Bearer "+cmd.Config.GetAccessToken())
-
-	// Add our query string bits and pieces
-	query := req.URL.Query()
-	query.Add("searchText", cmd.FilteredFolders)
-	query.Add("skip", cmd.Skip)
-	query.Add("take", cmd.Take)
-	req.URL.RawQuery = query.Encode()
-
-	// Use the HTTPHelper to make our API call
-	body, err := util.HTTPHelper(client, req)
-
 	if err != nil {
 		return err
 	}
 
-	// Process oour response
-	apiResp := filteredFoldersResp{}
-	jsonErr := json.Unmarshal(body, &apiResp)
-	if jsonErr != nil {
-		return jsonErr
-	}
-
-	// If we get a "-d" save the folder details we found
 	if cmd.SetDefaultFolder {
-		cmd.Config.SetFolderID(apiResp.FolderEntries[0].ID)
-		cmd.Config.SetFolderFQN(apiResp.FolderEntries[0].FullyQualifiedName)
-		cmd.Config.SetFolderDescription(apiResp.FolderEntries[0].Description)
-		cmd.Config.SetFolderName(apiResp.FolderEntries[0].DisplayName)
-		cmd.Config.SetFolderParentID(apiResp.FolderEntries[0].ParentID)
-	}
-
-	//fmt.Println(string(body))
-	for _, element := range apiResp.FolderEntries {
-		fmt.Println("           Folder ID: ", element.ID)
-		fmt.Println("        Display Name: ", element.DisplayName)
-		fmt.Println("Fully Qualified Name: ", element.FullyQualifiedName)
-		fmt.Println("         Description: ", element.Description)
-		fmt.Println("           Parent ID: ", element.ParentID)
-		fmt.Println("")
+		cmd.setDefaultFolder(folders)
 	}
 
-	return nil
-}
-
-func (cmd *CmdGetFolders) getAllFolders() error {
-
-	var foldersEndpoint string
-
-	if cmd.Config.GetEndpointType() == config.EndpointTypeHosted {
-		foldersEndpoint = cmd.APIEndpoint + "/" + cmd.AccountLogicalName + "/" + cmd.ServiceLogicalName + "/api/FoldersNavigation/GetAllFoldersForCurrentUser"
-	} else if cmd.Config.GetEndpointType() == config.EndpointTypeOnPremise {
-		foldersEndpoint = cmd.APIEndpoint + "/api/FoldersNavigation/GetAllFoldersForCurrentUser"
-	} else {
-		return errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
-	}
-
-	client := http.Client{}
-	req, _ := http.NewRequest("GET", foldersEndpoint, nil)
-
-	// Add our required request headers
-	req.Header.Add("X-UIPATH-TenantName", cmd.ServiceLogicalName)
-	req.Header.Add("Authorization", "Bearer "+cmd.Config.GetAccessToken())
-
-	// Use the HTTPHelper to make our API call
-	body, err := util.HTTPHelper(client, req)
-
+	renderer, err := newRenderer(cmd.Config)
 	if err != nil {
 		return err
 	}
 
-	// Process our response
-	var apiResp []allFolderEntry
-	jsonErr := json.Unmarshal(body, &apiResp)
-	if jsonErr != nil {
-		return jsonErr
-	}
+	return renderer.Render(folderColumns, folders)
+}
 
-	// If we get a "-d" save the folder details we found
-	if cmd.SetDefaultFolder {
-		cmd.Config.SetFolderID(apiResp[0].ID)
-		cmd.Config.SetFolderFQN(apiResp[0].FullyQualifiedName)
-		cmd.Config.SetFolderDescription(apiResp[0].Description)
-		cmd.Config.SetFolderName(apiResp[0].DisplayName)
-		cmd.Config.SetFolderParentID(apiResp[0].ParentID)
-	}
+// setDefaultFolder saves the first folder, if any, as the default for "-d"
+func (cmd *CmdGetFolders) setDefaultFolder(folders []orchestrator.Folder) {
 
-	//fmt.Println(string(body))
-	for _, element := range apiResp {
-		fmt.Println("           Folder ID: ", element.ID)
-		fmt.Println("        Display Name: ", element.DisplayName)
-		fmt.Println("Fully Qualified Name: ", element.FullyQualifiedName)
-		fmt.Println("         Description: ", element.Description)
-		fmt.Println("        Has Children: ", element.HasChildren)
-		fmt.Println("           Parent ID: ", element.ParentID)
-		fmt.Println("")
+	if len(folders) == 0 {
+		return
 	}
 
-	return nil
+	cmd.Config.SetFolderID(folders[0].ID)
+	cmd.Config.SetFolderFQN(folders[0].FullyQualifiedName)
+	cmd.Config.SetFolderDescription(folders[0].Description)
+	cmd.Config.SetFolderName(folders[0].DisplayName)
+	cmd.Config.SetFolderParentID(folders[0].ParentID)
+}
+
+// Usage is the override for the go-flags interface function
+func (cmd *CmdGetFolders) Usage() string {
+	usageStr := "[-e API Endpoint URL] [-f Search text] [--filter OData filter] [--skip Skip entries] [--top How many entries to return] [-d Sets first returned entry, if any, as default]"
+	return usageStr
 }
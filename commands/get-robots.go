@@ -1,14 +1,8 @@
 package commands
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"strconv"
-
-	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
 )
 
 // CmdRobots represents the flags supported by this command
@@ -17,30 +11,43 @@ type CmdRobots struct {
 	AccountLogicalName string `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
 	ServiceLogicalName string `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
 
+	OData ODataOptions `group:"OData Query Options"`
+
 	Config Config
 }
 
-type odataResp struct {
-	ODataContext string   `json:"@odata.context"`
-	ODataCount   int      `json:"@odata.count"`
-	Robots       []robots `json:"value"`
-}
-type robots struct {
-	LicenseKey  string `json:"LicenseKey"`
-	MachineName string `json:"MachineName"`
-	MachineID   int    `json:"MachineId"`
-	Name        string `json:"Name"`
-	Version     string `json:"Version"`
+var robotColumns = []output.Column{
+	{Header: "ROBOT NAME", Field: "Name"},
+	{Header: "MACHINE ID", Field: "MachineId"},
+	{Header: "MACHINE NAME", Field: "MachineName"},
+	{Header: "VERSION", Field: "Version"},
+	{Header: "ID", Field: "Id", Wide: true},
+	{Header: "LICENSE KEY", Field: "LicenseKey", Wide: true},
 }
 
 // Setup is the standard setup function
-func (cmd *CmdRobots) Setup(config Config) error {
+func (cmd *CmdRobots) Setup(conf Config) error {
 
-	cmd.Config = config
+	cmd.Config = conf
 
-	if cmd.Config.GetAPIEndpoint() != "" {
+	// If we have flags passed in with the command, use those and store them as
+	//   the new cached values in our config
+	if cmd.APIEndpoint == "" {
 		cmd.APIEndpoint = cmd.Config.GetAPIEndpoint()
+	} else {
+		cmd.Config.SetAPIEndpoint(cmd.APIEndpoint)
+	}
+	if cmd.AccountLogicalName == "" {
+		cmd.AccountLogicalName = cmd.Config.GetAccountLogicalName()
+	} else {
+		cmd.Config.SetAccountLogicalName(cmd.AccountLogicalName)
+	}
+	if cmd.ServiceLogicalName == "" {
+		cmd.ServiceLogicalName = cmd.Config.GetServiceLogicalName()
+	} else {
+		cmd.Config.SetServiceLogicalName(cmd.ServiceLogicalName)
 	}
+
 	return nil
 
 }
@@ -54,65 +61,24 @@ func (cmd *CmdRobots) Execute(args []string) error {
 		return err
 	}
 
-	var robotsEndpoint string
-	if cmd.Config.GetEndpointType() == config.EndpointTypeHosted {
-		robotsEndpoint = cmd.APIEndpoint + "/" + cmd.Config.GetAccountLogicalName() + "/" + cmd.Config.GetServiceLogicalName() + "/odata/Robots"
-	} else if cmd.Config.GetEndpointType() == config.EndpointTypeOnPremise {
-		robotsEndpoint = cmd.APIEndpoint + "/odata/Robots"
-	} else {
-		errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
-	}
-
-	client := http.Client{}
-	req, err := http.NewRequest("GET", robotsEndpoint, nil)
-	req.Header.Add("X-UIPATH-TenantName", cmd.Config.GetServiceLogicalName())
-	req.Header.Add("X-UIPATH-OrganizationUnitId", strconv.Itoa(cmd.Config.GetFolderID()))
-	req.Header.Add("Authorization", "Bearer "+cmd.Config.GetAccessToken())
-
-	resp, err := client.Do(req)
-
+	client, err := orchestrator.NewClient(cmd.Config)
 	if err != nil {
-		fmt.Println("Error with Client Request")
 		return err
 	}
 
-	//fmt.Println(resp.Header)
-	cmd.Config.SetAPIVersion(resp.Header.Get("Api-Supported-Versions"))
-
-	body, readErr := ioutil.ReadAll(resp.Body)
-	if readErr != nil {
-		return readErr
-	}
-	defer resp.Body.Close()
-
-	//fmt.Println(string(body))
-
-	odataResp := odataResp{}
-
-	jsonErr := json.Unmarshal(body, &odataResp)
-	if jsonErr != nil {
-		return jsonErr
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
 	}
 
-	if odataResp.ODataCount == 0 {
-		fmt.Println("No Robots returned")
-		fmt.Println("")
-	} else {
-		for _, element := range odataResp.Robots {
-			fmt.Println("       Robot Name: ", element.Name)
-			fmt.Println("       Machine ID: ", element.MachineID)
-			fmt.Println("     Machine Name: ", element.MachineName)
-			fmt.Println("  Machine Version: ", element.Version)
-			fmt.Println("      License Key: ", element.LicenseKey)
-			fmt.Println("")
-		}
-
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
 	}
 
-	//fmt.Println(string(body))
-	//fmt.Println(odataResp.Robots[0].Name)
+	pager := client.NewODataPager(orchestrator.RobotsURI, query, paging)
 
-	return nil
+	return renderPages(cmd.Config, robotColumns, pager, &[]orchestrator.Robot{}, "No Robots returned")
 }
 
 func (cmd *CmdRobots) validateFlags() error {
@@ -0,0 +1,544 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
+)
+
+// CmdJobs groups the commands used to run and manage jobs
+//  Jobs are started in, and listed from, the folder saved with "folders -d"
+type CmdJobs struct {
+	Start CmdJobsStart `command:"start" description:"Start jobs for a process"`
+	List  CmdJobsList  `command:"list" description:"List jobs in the current folder"`
+	Get   CmdJobsGet   `command:"get" description:"Show a job, including its input and output arguments"`
+	Stop  CmdJobsStop  `command:"stop" description:"Ask jobs to stop at their next stop checkpoint"`
+	Kill  CmdJobsKill  `command:"kill" description:"Terminate running jobs immediately"`
+	Wait  CmdJobsWait  `command:"wait" description:"Wait for jobs to finish and show their output arguments"`
+}
+
+// defaultAsyncTimeout is used for --wait when neither --timeout nor the
+//  AsyncTimeout setting is given
+const defaultAsyncTimeout int = 1800
+
+type jobIDArg struct {
+	ID int `positional-arg-name:"id" required:"yes" description:"Job ID"`
+}
+
+type jobIDsArg struct {
+	IDs []int `positional-arg-name:"id" required:"1" description:"Job IDs"`
+}
+
+var jobColumns = []output.Column{
+	{Header: "JOB ID", Field: "Id"},
+	{Header: "PROCESS", Field: "ReleaseName"},
+	{Header: "STATE", Field: "State"},
+	{Header: "HOST", Field: "HostMachineName"},
+	{Header: "START TIME", Field: "StartTime"},
+	{Header: "END TIME", Field: "EndTime"},
+	{Header: "KEY", Field: "Key", Wide: true},
+	{Header: "SOURCE", Field: "Source", Wide: true},
+	{Header: "INFO", Field: "Info", Wide: true},
+}
+
+var jobDetailColumns = []output.Column{
+	{Header: "Job ID", Field: "Id"},
+	{Header: "Key", Field: "Key"},
+	{Header: "Process", Field: "ReleaseName"},
+	{Header: "State", Field: "State"},
+	{Header: "Robot", Field: "Robot.Name"},
+	{Header: "Host", Field: "HostMachineName"},
+	{Header: "Source", Field: "Source"},
+	{Header: "Creation Time", Field: "CreationTime"},
+	{Header: "Start Time", Field: "StartTime"},
+	{Header: "End Time", Field: "EndTime"},
+	{Header: "Info", Field: "Info"},
+	{Header: "Input Arguments", Field: "InputArguments"},
+	{Header: "Output Arguments", Field: "OutputArguments"},
+}
+
+var jobResultColumns = []output.Column{
+	{Header: "JOB ID", Field: "Id"},
+	{Header: "PROCESS", Field: "ReleaseName"},
+	{Header: "STATE", Field: "State"},
+	{Header: "END TIME", Field: "EndTime"},
+	{Header: "OUTPUT ARGUMENTS", Field: "OutputArguments"},
+	{Header: "INFO", Field: "Info", Wide: true},
+}
+
+var jobStates = []string{
+	orchestrator.JobStatePending,
+	orchestrator.JobStateRunning,
+	orchestrator.JobStateStopping,
+	orchestrator.JobStateTerminating,
+	orchestrator.JobStateFaulted,
+	orchestrator.JobStateSuccessful,
+	orchestrator.JobStateStopped,
+	orchestrator.JobStateSuspended,
+	orchestrator.JobStateResumed,
+}
+
+// CmdJobsStart represents the flags supported by the jobs start command
+type CmdJobsStart struct {
+	ReleaseKey string `short:"k" long:"release-key" description:"Key of the process (release) to run"`
+	Process    string `short:"p" long:"process" description:"Name of the process to run.  Used to look up the release key in the current folder"`
+	Strategy   string `long:"strategy" choice:"All" choice:"Specific" choice:"JobsCount" choice:"ModernJobsCount" description:"How robots are chosen.  Defaults to Specific with --robot-id, otherwise ModernJobsCount"`
+	RobotIDs   []int  `short:"r" long:"robot-id" description:"Robot to run the job on.  Repeat for several robots"`
+	JobsCount  int    `short:"n" long:"jobs-count" default:"1" description:"Number of jobs to start with the JobsCount strategies"`
+	Input      string `short:"i" long:"input" description:"Input arguments as a JSON object, or @file to read them from a file"`
+	Wait       bool   `short:"w" long:"wait" description:"Wait for the jobs to finish.  The exit code reflects the job results"`
+	Timeout    int    `short:"t" long:"timeout" description:"Seconds to wait with --wait.  Defaults to the AsyncTimeout setting"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsStart) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsStart) Execute(args []string) error {
+
+	err := cmd.validateFlags()
+	if err != nil {
+		return err
+	}
+
+	inputArguments, err := readJSONObject(cmd.Input, "--input")
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if cmd.ReleaseKey == "" {
+		release, err := client.FindRelease(cmd.Process)
+		if err != nil {
+			return err
+		}
+		cmd.ReleaseKey = release.Key
+	}
+
+	startInfo := orchestrator.StartInfo{
+		ReleaseKey:     cmd.ReleaseKey,
+		Strategy:       cmd.Strategy,
+		RobotIds:       cmd.RobotIDs,
+		InputArguments: inputArguments,
+	}
+	if startInfo.Strategy == "" {
+		startInfo.Strategy = orchestrator.StartStrategyModernJobsCount
+		if len(cmd.RobotIDs) > 0 {
+			startInfo.Strategy = orchestrator.StartStrategySpecific
+		}
+	}
+	if startInfo.Strategy == orchestrator.StartStrategyJobsCount || startInfo.Strategy == orchestrator.StartStrategyModernJobsCount {
+		startInfo.JobsCount = cmd.JobsCount
+	}
+
+	jobs, err := client.StartJobs(startInfo)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if renderer.IsHuman() {
+		fmt.Println("Started " + strconv.Itoa(len(jobs)) + " job(s)")
+		fmt.Println("")
+	}
+
+	if !cmd.Wait {
+		return renderer.Render(jobColumns, jobs)
+	}
+
+	// Only the final results are written in machine readable formats
+	if renderer.IsHuman() {
+		err = renderer.Render(jobColumns, jobs)
+		if err != nil {
+			return err
+		}
+		fmt.Println("")
+	}
+
+	jobIDs := make([]int, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = job.ID
+	}
+
+	return waitForJobs(cmd.Config, client, jobIDs, cmd.Timeout)
+}
+
+func (cmd *CmdJobsStart) validateFlags() error {
+
+	if cmd.ReleaseKey == "" && cmd.Process == "" {
+		return errors.New("A process name (-p) or release key (-k) is required")
+	}
+	if cmd.ReleaseKey != "" && cmd.Process != "" {
+		return errors.New("Use either a process name (-p) or a release key (-k), not both")
+	}
+	if cmd.Strategy == orchestrator.StartStrategySpecific && len(cmd.RobotIDs) == 0 {
+		return errors.New("The Specific strategy needs at least one --robot-id")
+	}
+	if cmd.JobsCount < 1 {
+		return errors.New("--jobs-count must be at least 1")
+	}
+	if cmd.Timeout < 0 {
+		return errors.New("--timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// CmdJobsList represents the flags supported by the jobs list command
+type CmdJobsList struct {
+	States  []string `long:"state" description:"Only list jobs in this state (e.g. Running).  Repeat or comma separate for several states"`
+	Process string   `short:"p" long:"process" description:"Only list jobs for the named process"`
+	Since   string   `long:"since" description:"Only list jobs created after this time.  RFC3339, a date or a duration ago such as 2h or 7d"`
+	Until   string   `long:"until" description:"Only list jobs created before this time.  RFC3339, a date or a duration ago such as 2h or 7d"`
+
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	stateFilter, err := jobStateFilter(cmd.States)
+	if err != nil {
+		return err
+	}
+	query.And(stateFilter)
+
+	if cmd.Process != "" {
+		query.And("ReleaseName eq " + orchestrator.ODataString(cmd.Process))
+	}
+	if cmd.Since != "" {
+		since, err := parseTimeAgo(cmd.Since)
+		if err != nil {
+			return err
+		}
+		query.And("CreationTime ge " + odataTime(since))
+	}
+	if cmd.Until != "" {
+		until, err := parseTimeAgo(cmd.Until)
+		if err != nil {
+			return err
+		}
+		query.And("CreationTime lt " + odataTime(until))
+	}
+
+	// Most recent jobs first unless asked otherwise
+	if query.OrderBy == "" {
+		query.OrderBy = "CreationTime desc"
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewODataPager(orchestrator.JobsURI, query, paging)
+
+	return renderPages(cmd.Config, jobColumns, pager, &[]orchestrator.Job{}, "No Jobs returned")
+}
+
+// jobStateFilter builds the $filter condition for the requested job states
+func jobStateFilter(states []string) (string, error) {
+	return choiceFilter("State", states, jobStates, "job state")
+}
+
+// choiceFilter builds a $filter condition matching any of the requested values
+//  of field.  Values may be repeated or comma separated and must be one of allowed
+func choiceFilter(field string, values []string, allowed []string, kind string) (string, error) {
+
+	var conditions []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			valid := false
+			for _, choice := range allowed {
+				if strings.EqualFold(name, choice) {
+					name = choice
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return "", errors.New("Unknown " + kind + " '" + name + "'.  Use one of " + strings.Join(allowed, ", "))
+			}
+
+			conditions = append(conditions, field+" eq "+orchestrator.ODataString(name))
+		}
+	}
+
+	return strings.Join(conditions, " or "), nil
+}
+
+// CmdJobsGet represents the flags supported by the jobs get command
+type CmdJobsGet struct {
+	Args jobIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsGet) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsGet) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	job, err := client.GetJob(cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	return renderer.RenderDetails(jobDetailColumns, job)
+}
+
+// CmdJobsStop represents the flags supported by the jobs stop command
+type CmdJobsStop struct {
+	Args jobIDsArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsStop) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsStop) Execute(args []string) error {
+	return stopJobs(cmd.Config, cmd.Args.IDs, orchestrator.StopStrategySoftStop)
+}
+
+// CmdJobsKill represents the flags supported by the jobs kill command
+type CmdJobsKill struct {
+	Args jobIDsArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsKill) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsKill) Execute(args []string) error {
+	return stopJobs(cmd.Config, cmd.Args.IDs, orchestrator.StopStrategyKill)
+}
+
+// CmdJobsWait represents the flags supported by the jobs wait command
+type CmdJobsWait struct {
+	Timeout int       `short:"t" long:"timeout" description:"Seconds to wait.  Defaults to the AsyncTimeout setting"`
+	Args    jobIDsArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdJobsWait) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdJobsWait) Execute(args []string) error {
+
+	if cmd.Timeout < 0 {
+		return errors.New("--timeout cannot be negative")
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	return waitForJobs(cmd.Config, client, cmd.Args.IDs, cmd.Timeout)
+}
+
+// waitForJobs polls the jobs until they finish, showing each state change, then
+//  writes the results.  The returned *ExitError carries the exit code for faulted
+//  or stopped jobs and for a timeout
+func waitForJobs(conf Config, client *orchestrator.Client, jobIDs []int, timeout int) error {
+
+	if timeout == 0 {
+		timeout = conf.GetAsyncTimeout()
+	}
+	if timeout == 0 {
+		timeout = defaultAsyncTimeout
+	}
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	// State changes go to stderr when stdout is being parsed
+	progress := os.Stdout
+	if !renderer.IsHuman() {
+		progress = os.Stderr
+	}
+
+	jobs, waitErr := client.WaitForJobs(jobIDs, time.Duration(timeout)*time.Second, func(job orchestrator.Job) {
+		fmt.Fprintln(progress, time.Now().Format("15:04:05")+"  Job "+strconv.Itoa(job.ID)+" ("+job.ReleaseName+"): "+job.State)
+	})
+	if waitErr != nil && waitErr != orchestrator.ErrWaitTimeout {
+		return waitErr
+	}
+
+	if renderer.IsHuman() {
+		fmt.Println("")
+	}
+	err = renderer.Render(jobResultColumns, jobs)
+	if err != nil {
+		return err
+	}
+
+	if waitErr == orchestrator.ErrWaitTimeout {
+		return &ExitError{Code: ExitCodeTimeout, Message: "Timed out after " + strconv.Itoa(timeout) + "s waiting for jobs to finish"}
+	}
+
+	faulted, stopped := 0, 0
+	for _, job := range jobs {
+		switch job.State {
+		case orchestrator.JobStateFaulted:
+			faulted++
+		case orchestrator.JobStateStopped:
+			stopped++
+		}
+	}
+	if faulted > 0 {
+		return &ExitError{Code: ExitCodeJobFaulted, Message: strconv.Itoa(faulted) + " job(s) faulted"}
+	}
+	if stopped > 0 {
+		return &ExitError{Code: ExitCodeJobStopped, Message: strconv.Itoa(stopped) + " job(s) were stopped"}
+	}
+
+	return nil
+}
+
+func stopJobs(conf Config, jobIDs []int, strategy string) error {
+
+	client, err := newFolderClient(conf)
+	if err != nil {
+		return err
+	}
+
+	err = client.StopJobs(jobIDs, strategy)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+	if renderer.IsHuman() {
+		fmt.Println(strategy + " requested for " + strconv.Itoa(len(jobIDs)) + " job(s)")
+	}
+
+	return nil
+}
+
+// readJSONObject reads a JSON object given inline or, with a leading "@", from a
+// file and returns it compacted.  An empty value returns an empty string
+func readJSONObject(value string, flagName string) (string, error) {
+
+	if value == "" {
+		return "", nil
+	}
+
+	rawValue := []byte(value)
+	if strings.HasPrefix(value, "@") {
+		var err error
+		rawValue, err = ioutil.ReadFile(value[1:])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var object map[string]interface{}
+	err := json.Unmarshal(rawValue, &object)
+	if err != nil || object == nil {
+		return "", errors.New(flagName + " must be a JSON object (e.g. {\"Name\": \"value\"})")
+	}
+
+	var compacted bytes.Buffer
+	err = json.Compact(&compacted, rawValue)
+	if err != nil {
+		return "", err
+	}
+
+	return compacted.String(), nil
+}
+
+// parseTimeAgo parses a time flag where a bare duration such as "2h" means that
+// long ago
+func parseTimeAgo(value string) (time.Time, error) {
+
+	if _, err := util.ParseDuration(value); err == nil {
+		value = "-" + value
+	}
+
+	return util.ParseTime(value, time.Now())
+}
+
+// odataTime formats a time as an OData DateTimeOffset literal
+func odataTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func TestChoiceFilter(t *testing.T) {
+
+	allowed := []string{"Pending", "Running", "Successful"}
+
+	tests := []struct {
+		name   string
+		values []string
+		filter string
+		err    string
+	}{
+		{"no values", nil, "", ""},
+		{"one value", []string{"Running"}, "State eq 'Running'", ""},
+		{"case is corrected", []string{"running"}, "State eq 'Running'", ""},
+		{"repeated flags", []string{"Pending", "Running"}, "State eq 'Pending' or State eq 'Running'", ""},
+		{"comma separated", []string{"pending, SUCCESSFUL,"}, "State eq 'Pending' or State eq 'Successful'", ""},
+		{"unknown value", []string{"Running,Stopped"}, "", "Unknown job state 'Stopped'.  Use one of Pending, Running, Successful"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := choiceFilter("State", test.values, allowed, "job state")
+			if filter != test.filter {
+				t.Errorf("filter = %q, want %q", filter, test.filter)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestJobsWaitExitCodes(t *testing.T) {
+
+	interval := orchestrator.JobPollInterval
+	orchestrator.JobPollInterval = time.Millisecond
+	t.Cleanup(func() { orchestrator.JobPollInterval = interval })
+
+	tests := []struct {
+		name   string
+		states []string
+		code   int
+	}{
+		{"successful", []string{"Successful", "Successful"}, ExitCodeSuccess},
+		{"faulted", []string{"Successful", "Faulted"}, ExitCodeJobFaulted},
+		{"stopped", []string{"Stopped", "Successful"}, ExitCodeJobStopped},
+		{"faulted and stopped", []string{"Stopped", "Faulted"}, ExitCodeJobFaulted},
+		{"timeout", []string{"Successful", "Running"}, ExitCodeTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("$filter") != "Id eq 1 or Id eq 2" {
+					t.Errorf("filter = %q", r.URL.Query().Get("$filter"))
+				}
+				fmt.Fprintf(w, `{"value":[{"Id":1,"State":%q},{"Id":2,"State":%q}]}`, test.states[0], test.states[1])
+			})
+			conf.GlobalFlgs.OutputFormat = "json"
+
+			cmd := CmdJobsWait{Timeout: 1, Config: conf}
+			cmd.Args.IDs = []int{1, 2}
+			err := cmd.Execute(nil)
+
+			code := ExitCodeSuccess
+			var exitErr *ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.Code
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != test.code {
+				t.Errorf("exit code = %d (%v), want %d", code, err, test.code)
+			}
+		})
+	}
+}
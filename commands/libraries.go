@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// CmdLibraries groups the commands that work with the tenant library feed, which
+//  holds the shared activity libraries used by processes
+type CmdLibraries struct {
+	Push     CmdLibrariesPush     `command:"push" description:"Upload library packages to the library feed"`
+	List     CmdLibrariesList     `command:"list" description:"List the libraries in the feed with their latest version"`
+	Versions CmdLibrariesVersions `command:"versions" description:"List every version of a library"`
+	Download CmdLibrariesDownload `command:"download" description:"Download a library version to a local file"`
+	Delete   CmdLibrariesDelete   `command:"delete" description:"Delete a library, or one version of it, from the feed"`
+}
+
+// CmdLibrariesPush represents the flags supported by the libraries push command
+type CmdLibrariesPush struct {
+	PackagePaths   []string `short:"p" long:"package" description:"NuGet package file, directory of packages or glob pattern.  Repeat for several, or pass them as arguments"`
+	Parallel       int      `short:"j" long:"parallel" default:"4" description:"Number of packages to upload at the same time"`
+	NoProgress     bool     `long:"no-progress" description:"Do not show the upload progress bar"`
+	SkipValidation bool     `long:"skip-validation" description:"Upload without checking the packages first"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdLibrariesPush) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdLibrariesPush) Execute(args []string) error {
+
+	if len(cmd.PackagePaths) == 0 && len(args) == 0 {
+		return errors.New("A package file is required")
+	}
+	if cmd.Parallel < 1 {
+		return errors.New("--parallel must be at least 1")
+	}
+
+	packagePaths, err := expandPackagePaths(append(cmd.PackagePaths, args...))
+	if err != nil {
+		return err
+	}
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	rejected := map[string]string{}
+	if !cmd.SkipValidation {
+		rejected = validatePackages(client, packagePaths, true)
+	}
+
+	uploads, cancelled := runUploads(packagePaths, rejected, cmd.Parallel, !cmd.NoProgress, client.UploadLibrary)
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+	err = renderer.Render(uploadColumns, uploads)
+	if err != nil {
+		return err
+	}
+
+	counts := uploadCounts(uploads)
+	if renderer.IsHuman() {
+		fmt.Println("")
+		fmt.Println(uploadSummary(counts))
+	}
+
+	if counts[uploadFailed] > 0 {
+		message := strconv.Itoa(counts[uploadFailed]) + " package(s) failed"
+		if cancelled {
+			message = "Upload cancelled.  " + message
+		}
+		return &ExitError{Code: ExitCodeError, Message: message}
+	}
+	if cancelled {
+		return &ExitError{Code: ExitCodeError, Message: "Upload cancelled"}
+	}
+
+	return nil
+}
+
+// CmdLibrariesList represents the flags supported by the libraries list command
+type CmdLibrariesList struct {
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdLibrariesList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdLibrariesList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewLibrariesPager(query, paging)
+
+	return renderPages(cmd.Config, packageColumns, pager, &[]orchestrator.Package{}, "No Libraries returned")
+}
+
+// CmdLibrariesVersions represents the flags supported by the libraries versions command
+type CmdLibrariesVersions struct {
+	Args packageIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdLibrariesVersions) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdLibrariesVersions) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	versions, err := client.LibraryVersions(cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+	if renderer.IsHuman() && len(versions) == 0 {
+		fmt.Println("Library " + cmd.Args.ID + " was not found in the feed")
+		return nil
+	}
+
+	return renderer.Render(packageVersionColumns, versions)
+}
+
+// CmdLibrariesDownload represents the flags supported by the libraries download command
+type CmdLibrariesDownload struct {
+	File string             `short:"f" long:"file" description:"File or directory to save the library to.  Defaults to <id>.<version>.nupkg in the current directory"`
+	Args packageVersionArgs `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdLibrariesDownload) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdLibrariesDownload) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	path := downloadPath(cmd.File, cmd.Args.ID, cmd.Args.Version)
+	size, err := downloadPackage(path, cmd.Args.ID, cmd.Args.Version, func(w io.Writer) (int64, error) {
+		return client.DownloadLibrary(cmd.Args.ID, cmd.Args.Version, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	return renderDownload(cmd.Config, packageDownload{ID: cmd.Args.ID, Version: cmd.Args.Version, File: path, Size: size})
+}
+
+// CmdLibrariesDelete represents the flags supported by the libraries delete command
+type CmdLibrariesDelete struct {
+	Version string       `long:"version" description:"Delete only this version.  Without it every version of the library is deleted"`
+	Yes     bool         `short:"y" long:"yes" description:"Delete without asking for confirmation"`
+	DryRun  bool         `long:"dry-run" description:"Show the versions that would be deleted without deleting them"`
+	Args    packageIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdLibrariesDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdLibrariesDelete) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	versions, err := client.LibraryVersions(cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	return deletePackageVersions(cmd.Config, "Library", cmd.Args.ID, cmd.Version, versions, cmd.Yes, cmd.DryRun, func() error {
+		return client.DeleteLibrary(cmd.Args.ID, cmd.Version)
+	})
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func TestLibrariesDelete(t *testing.T) {
+
+	var deleted []string
+	conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-UIPATH-OrganizationUnitId") != "" {
+			t.Errorf("%s %s was sent to folder %s, not the tenant", r.Method, r.URL, r.Header.Get("X-UIPATH-OrganizationUnitId"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/odata/Libraries/UiPath.Server.Configuration.OData.GetVersions(packageId='Common')":
+			if r.URL.Query().Get("$skip") != "" {
+				fmt.Fprint(w, `{"value":[]}`)
+				return
+			}
+			fmt.Fprint(w, `{"value":[{"Id":"Common","Version":"1.0.0"},{"Id":"Common","Version":"1.1.0"}]}`)
+		case r.Method == "DELETE":
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/odata/Libraries"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	dryRun := CmdLibrariesDelete{DryRun: true, Version: "1.1.0", Config: conf}
+	dryRun.Args.ID = "Common"
+	if err := dryRun.Execute(nil); err != nil || len(deleted) > 0 {
+		t.Fatalf("dry run deleted %v, err %v", deleted, err)
+	}
+
+	missing := CmdLibrariesDelete{Yes: true, Version: "2.0.0", Config: conf}
+	missing.Args.ID = "Common"
+	if err := missing.Execute(nil); err == nil || err.Error() != "Library Common 2.0.0 was not found in the feed" {
+		t.Errorf("err = %v", err)
+	}
+
+	remove := CmdLibrariesDelete{Yes: true, Version: "1.1.0", Config: conf}
+	remove.Args.ID = "Common"
+	if err := remove.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(deleted) != "[('Common:1.1.0')]" {
+		t.Errorf("deleted %v", deleted)
+	}
+}
+
+func TestLibrariesPush(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		packages       []string
+		skipValidation bool
+		sent           []string
+		err            string
+	}{
+		{"uploaded and skipped", []string{"Common.1.0.0.nupkg", "Common.1.1.0.nupkg", "Logging.2.0.0.nupkg"}, true, []string{"Common.1.0.0.nupkg", "Common.1.1.0.nupkg", "Logging.2.0.0.nupkg"}, ""},
+		{"refused by the feed", []string{"Common.1.1.0.nupkg", "Broken.1.0.1.nupkg"}, true, []string{"Broken.1.0.1.nupkg", "Common.1.1.0.nupkg"}, "1 package(s) failed"},
+		{"rejected by validation", []string{"Corrupt.1.0.1.nupkg"}, false, nil, "1 package(s) failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &uploadServer{t: t, uri: orchestrator.LibraryUploadURI}
+			conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-UIPATH-OrganizationUnitId") != "" {
+					t.Errorf("the upload was sent to folder %s, not the tenant", r.Header.Get("X-UIPATH-OrganizationUnitId"))
+				}
+				server.ServeHTTP(w, r)
+			})
+			conf.GlobalFlgs.OutputFormat = "json"
+
+			paths := writePackages(t, t.TempDir(), test.packages...)
+			cmd := CmdLibrariesPush{Parallel: 2, NoProgress: true, SkipValidation: test.skipValidation, Config: conf}
+			err := cmd.Execute(paths)
+
+			var exitErr *ExitError
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("err = %v", err)
+			case test.err != "" && (!errors.As(err, &exitErr) || exitErr.Code != ExitCodeError || exitErr.Message != test.err):
+				t.Errorf("err = %v, want exit code 1 with %q", err, test.err)
+			}
+
+			sort.Strings(server.files)
+			if fmt.Sprint(server.files) != fmt.Sprint(test.sent) {
+				t.Errorf("sent %v, want %v", server.files, test.sent)
+			}
+		})
+	}
+}
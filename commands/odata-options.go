@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+)
+
+// ODataOptions are the query flags shared by every command that lists an OData collection
+// Embed it in a command with `group:"OData Query Options"` to add the flags
+type ODataOptions struct {
+	Filter  string `long:"filter" description:"OData $filter expression (e.g. \"Name eq 'Robot1'\")"`
+	Select  string `long:"select" description:"OData $select list of fields to return (e.g. \"Id,Name\")"`
+	OrderBy string `long:"orderby" description:"OData $orderby expression (e.g. \"Name desc\")"`
+	Expand  string `long:"expand" description:"OData $expand list of related entities to include"`
+	Top     int    `long:"top" description:"Maximum number of entries to return"`
+	Skip    int    `long:"skip" description:"Number of entries to skip"`
+
+	All      bool `long:"all" description:"Follow every page of results instead of returning only the first page"`
+	Limit    int  `long:"limit" description:"Stop after this many entries.  Pages through results like --all"`
+	PageSize int  `long:"page-size" default:"100" description:"Number of entries requested per page with --all or --limit (max 1000)"`
+}
+
+// Paging converts the paging flags into the settings used to read the collection
+func (opts ODataOptions) Paging() (orchestrator.Paging, error) {
+
+	if opts.Limit < 0 {
+		return orchestrator.Paging{}, errors.New("--limit cannot be negative")
+	}
+	if opts.PageSize < 1 || opts.PageSize > orchestrator.MaxPageSize {
+		return orchestrator.Paging{}, errors.New("--page-size must be between 1 and " + strconv.Itoa(orchestrator.MaxPageSize))
+	}
+
+	paging := orchestrator.Paging{
+		All:      opts.All || opts.Limit > 0,
+		PageSize: opts.PageSize,
+		Limit:    opts.Limit,
+	}
+	if paging.All && opts.Top > 0 {
+		return orchestrator.Paging{}, errors.New("--top cannot be combined with --all or --limit.  Use --limit to cap the number of entries")
+	}
+
+	return paging, nil
+}
+
+// Query converts the flags into the query sent to Orchestrator
+func (opts ODataOptions) Query() (orchestrator.ODataQuery, error) {
+
+	if opts.Top < 0 {
+		return orchestrator.ODataQuery{}, errors.New("--top cannot be negative")
+	}
+	if opts.Skip < 0 {
+		return orchestrator.ODataQuery{}, errors.New("--skip cannot be negative")
+	}
+
+	return orchestrator.ODataQuery{
+		Filter:  opts.Filter,
+		Select:  opts.Select,
+		OrderBy: opts.OrderBy,
+		Expand:  opts.Expand,
+		Top:     opts.Top,
+		Skip:    opts.Skip,
+	}, nil
+}
+
+// renderPages streams every page read by the pager to the selected output format
+// page is a pointer to a slice of the collection's entity type.  Each page is
+//  decoded into a new slice of that type
+// emptyMessage is printed instead of an empty table when nothing is returned
+func renderPages(conf Config, columns []output.Column, pager *orchestrator.ODataPager, page interface{}, emptyMessage string) error {
+
+	// Entity types drop the fields pulled in with $expand and show the fields left
+	//  out by $select as empty, so render the entries as Orchestrator returned them
+	query := pager.Query()
+	if query.Select != "" || query.Expand != "" {
+		page = &[]json.RawMessage{}
+		columns = selectedColumns(columns, query.Select)
+	}
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	more, err := pager.Next(page)
+	if err != nil {
+		return err
+	}
+	if !more && renderer.IsHuman() {
+		fmt.Println(emptyMessage)
+		fmt.Println("")
+		return nil
+	}
+
+	err = renderer.Start(columns)
+	if err != nil {
+		return err
+	}
+	for more {
+		err = renderer.Items(reflect.ValueOf(page).Elem().Interface())
+		if err != nil {
+			return err
+		}
+		// Write each page as it arrives so that memory use does not grow with the collection
+		err = renderer.Flush()
+		if err != nil {
+			return err
+		}
+
+		page = reflect.New(reflect.TypeOf(page).Elem()).Interface()
+		more, err = pager.Next(page)
+		if err != nil {
+			return err
+		}
+	}
+	err = renderer.Finish()
+	if err != nil {
+		return err
+	}
+
+	if renderer.IsHuman() && pager.Truncated() {
+		fmt.Fprintf(os.Stderr, "\nShowing %d of %d entries.  Use --all or --limit to page through more\n", pager.Fetched(), pager.Count)
+	}
+
+	return nil
+}
+
+// selectedColumns returns a column for each field in a $select list, keeping the
+//  command's own column for a field when it has one.  Without a $select list the
+//  command's columns are returned unchanged
+func selectedColumns(columns []output.Column, selectList string) []output.Column {
+
+	if strings.TrimSpace(selectList) == "" {
+		return columns
+	}
+
+	var selected []output.Column
+	for _, field := range strings.Split(selectList, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		column := output.Column{Header: strings.ToUpper(field), Field: field}
+		for _, candidate := range columns {
+			if strings.EqualFold(candidate.Field, field) {
+				column = candidate
+				column.Wide = false
+				break
+			}
+		}
+		selected = append(selected, column)
+	}
+
+	return selected
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bcsimms/uipo/output"
+)
+
+func TestSelectedColumns(t *testing.T) {
+
+	columns := []output.Column{
+		{Header: "ROBOT ID", Field: "Id"},
+		{Header: "ROBOT NAME", Field: "Name"},
+		{Header: "VERSION", Field: "Version", Wide: true},
+	}
+
+	tests := []struct {
+		selectList string
+		want       []output.Column
+	}{
+		{"", columns},
+		{"name", []output.Column{{Header: "ROBOT NAME", Field: "Name"}}},
+		{"Version, MachineName", []output.Column{
+			{Header: "VERSION", Field: "Version"},
+			{Header: "MACHINENAME", Field: "MachineName"},
+		}},
+		{"Id,,Name", []output.Column{columns[0], columns[1]}},
+	}
+
+	for _, test := range tests {
+		got := selectedColumns(columns, test.selectList)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("selectedColumns(%q) = %+v, want %+v", test.selectList, got, test.want)
+		}
+	}
+}
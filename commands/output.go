@@ -0,0 +1,12 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/bcsimms/uipo/output"
+)
+
+// newRenderer creates the renderer selected with the global --output and --query flags
+func newRenderer(conf Config) (*output.Renderer, error) {
+	return output.New(os.Stdout, conf.GetOutputFormat(), conf.GetOutputQuery())
+}
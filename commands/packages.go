@@ -0,0 +1,552 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/nupkg"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
+)
+
+// CmdPackages groups the commands that work with NuGet package files and the
+//  Orchestrator feeds
+type CmdPackages struct {
+	List     CmdPackagesList     `command:"list" description:"List the packages in a feed with their latest version"`
+	Versions CmdPackagesVersions `command:"versions" description:"List every version of a package in a feed"`
+	Download CmdPackagesDownload `command:"download" description:"Download a package version from a feed to a local file"`
+	Delete   CmdPackagesDelete   `command:"delete" description:"Delete a package, or one version of it, from a feed"`
+	Promote  CmdPackagesPromote  `command:"promote" description:"Copy a package version from one Orchestrator profile to another"`
+	Inspect  CmdPackagesInspect  `command:"inspect" description:"Show the contents of a local .nupkg file and check it for problems"`
+}
+
+// FeedOptions selects the package feed a command works with.  Without either
+//  option the tenant feed is used
+type FeedOptions struct {
+	FolderFeed bool   `long:"folder-feed" description:"Use the package feed of the current folder"`
+	FeedFolder string `long:"feed-folder" description:"Use the package feed of this folder (fully qualified name or ID)"`
+}
+
+// Feed looks up the feed selected by the options
+func (opts FeedOptions) Feed(conf Config, client *orchestrator.Client) (orchestrator.Feed, error) {
+
+	if opts.FolderFeed && opts.FeedFolder != "" {
+		return orchestrator.Feed{}, errors.New("Use either --folder-feed or --feed-folder, not both")
+	}
+
+	folderID := 0
+	switch {
+	case opts.FeedFolder != "":
+		folder, err := client.FindFolder(opts.FeedFolder)
+		if err != nil {
+			return orchestrator.Feed{}, err
+		}
+		folderID = folder.ID
+	case opts.FolderFeed:
+		if conf.GetFolderID() == 0 {
+			return orchestrator.Feed{}, noFolderError(conf)
+		}
+		folderID = conf.GetFolderID()
+	default:
+		return orchestrator.Feed{}, nil
+	}
+
+	return client.FolderFeed(folderID)
+}
+
+type packageIDArg struct {
+	ID string `positional-arg-name:"id" required:"yes" description:"Package ID"`
+}
+
+type packageVersionArgs struct {
+	ID      string `positional-arg-name:"id" required:"yes" description:"Package ID"`
+	Version string `positional-arg-name:"version" required:"yes" description:"Package version"`
+}
+
+var packageColumns = []output.Column{
+	{Header: "PACKAGE", Field: "Id"},
+	{Header: "LATEST VERSION", Field: "Version"},
+	{Header: "TITLE", Field: "Title"},
+	{Header: "PUBLISHED", Field: "Published"},
+	{Header: "AUTHORS", Field: "Authors", Wide: true},
+	{Header: "DESCRIPTION", Field: "Description", Wide: true},
+}
+
+var packageVersionColumns = []output.Column{
+	{Header: "VERSION", Field: "Version"},
+	{Header: "LATEST", Field: "IsLatestVersion"},
+	{Header: "PUBLISHED", Field: "Published"},
+	{Header: "KEY", Field: "Key", Wide: true},
+	{Header: "AUTHORS", Field: "Authors", Wide: true},
+}
+
+// CmdPackagesList represents the flags supported by the packages list command
+type CmdPackagesList struct {
+	Feed  FeedOptions  `group:"Feed Options"`
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+	feed, err := cmd.Feed.Feed(cmd.Config, client)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewPackagesPager(feed, query, paging)
+
+	return renderPages(cmd.Config, packageColumns, pager, &[]orchestrator.Package{}, "No Packages returned")
+}
+
+// CmdPackagesVersions represents the flags supported by the packages versions command
+type CmdPackagesVersions struct {
+	Feed FeedOptions  `group:"Feed Options"`
+	Args packageIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesVersions) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesVersions) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+	feed, err := cmd.Feed.Feed(cmd.Config, client)
+	if err != nil {
+		return err
+	}
+
+	versions, err := client.PackageVersions(feed, cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+	if renderer.IsHuman() && len(versions) == 0 {
+		fmt.Println("Package " + cmd.Args.ID + " was not found in the feed")
+		return nil
+	}
+
+	return renderer.Render(packageVersionColumns, versions)
+}
+
+// CmdPackagesDownload represents the flags supported by the packages download command
+type CmdPackagesDownload struct {
+	File string             `short:"f" long:"file" description:"File or directory to save the package to.  Defaults to <id>.<version>.nupkg in the current directory"`
+	Feed FeedOptions        `group:"Feed Options"`
+	Args packageVersionArgs `positional-args:"yes"`
+
+	Config Config
+}
+
+// packageDownload is the result reported for a downloaded package
+type packageDownload struct {
+	ID      string `json:"Id"`
+	Version string `json:"Version"`
+	File    string `json:"File"`
+	Size    int64  `json:"Size"`
+}
+
+var packageDownloadColumns = []output.Column{
+	{Header: "PACKAGE", Field: "Id"},
+	{Header: "VERSION", Field: "Version"},
+	{Header: "FILE", Field: "File"},
+	{Header: "SIZE", Field: "Size"},
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesDownload) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesDownload) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+	feed, err := cmd.Feed.Feed(cmd.Config, client)
+	if err != nil {
+		return err
+	}
+
+	path := downloadPath(cmd.File, cmd.Args.ID, cmd.Args.Version)
+	size, err := downloadPackage(path, cmd.Args.ID, cmd.Args.Version, func(w io.Writer) (int64, error) {
+		return client.DownloadPackage(feed, cmd.Args.ID, cmd.Args.Version, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	return renderDownload(cmd.Config, packageDownload{ID: cmd.Args.ID, Version: cmd.Args.Version, File: path, Size: size})
+}
+
+// downloadPath returns where a downloaded package is saved.  file may be empty,
+//  for the current directory, or a file or directory path
+func downloadPath(file string, packageID string, version string) string {
+
+	fileName := packageID + "." + version + ".nupkg"
+	if file == "" {
+		return fileName
+	}
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		return filepath.Join(file, fileName)
+	}
+
+	return file
+}
+
+// renderDownload reports a downloaded package
+func renderDownload(conf Config, download packageDownload) error {
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+	if renderer.IsHuman() {
+		fmt.Println("Downloaded " + download.ID + " " + download.Version + " to " + download.File + " (" + util.FormatBytes(download.Size) + ")")
+		return nil
+	}
+
+	return renderer.RenderOne(packageDownloadColumns, download)
+}
+
+// downloadPackage saves a package to path using the download function given.  The
+//  download goes to a temporary file first so that a failure never leaves a
+//  partial package behind
+func downloadPackage(path string, packageID string, version string, download func(w io.Writer) (int64, error)) (int64, error) {
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return 0, err
+	}
+	tempFileName := tempFile.Name()
+
+	size, err := download(tempFile)
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	// Temporary files are only readable by their owner
+	if err == nil {
+		err = os.Chmod(tempFileName, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempFileName, path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFileName)
+		if orchestrator.IsStatus(err, http.StatusNotFound) {
+			return 0, errors.New("Package " + packageID + " " + version + " was not found in the feed")
+		}
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// CmdPackagesDelete represents the flags supported by the packages delete command
+type CmdPackagesDelete struct {
+	Version string       `long:"version" description:"Delete only this version.  Without it every version of the package is deleted"`
+	Yes     bool         `short:"y" long:"yes" description:"Delete without asking for confirmation"`
+	DryRun  bool         `long:"dry-run" description:"Show the versions that would be deleted without deleting them"`
+	Feed    FeedOptions  `group:"Feed Options"`
+	Args    packageIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesDelete) Execute(args []string) error {
+
+	client, err := orchestrator.NewClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+	feed, err := cmd.Feed.Feed(cmd.Config, client)
+	if err != nil {
+		return err
+	}
+
+	versions, err := client.PackageVersions(feed, cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	return deletePackageVersions(cmd.Config, "Package", cmd.Args.ID, cmd.Version, versions, cmd.Yes, cmd.DryRun, func() error {
+		return client.DeletePackage(feed, cmd.Args.ID, cmd.Version)
+	})
+}
+
+// deletePackageVersions deletes a package, or one version of it, once the versions
+//  that go with it have been confirmed.  With dryRun they are only shown.  kind
+//  names what is deleted in messages, such as Package or Library
+func deletePackageVersions(conf Config, kind string, packageID string, version string, versions []orchestrator.Package, yes bool, dryRun bool, remove func() error) error {
+
+	name := packageID
+	if version != "" {
+		name = packageID + " " + version
+	}
+
+	var selected []orchestrator.Package
+	for _, found := range versions {
+		if version == "" || found.Version == version {
+			selected = append(selected, found)
+		}
+	}
+	if len(selected) == 0 {
+		return errors.New(kind + " " + name + " was not found in the feed")
+	}
+
+	if dryRun {
+		renderer, err := newRenderer(conf)
+		if err != nil {
+			return err
+		}
+		if renderer.IsHuman() {
+			fmt.Println("Would delete " + strconv.Itoa(len(selected)) + " version(s) of " + strings.ToLower(kind) + " " + packageID + ":")
+			fmt.Println("")
+		}
+		return renderer.Render(packageVersionColumns, selected)
+	}
+
+	description := strings.ToLower(kind) + " " + name
+	if version == "" {
+		description = description + " and all " + strconv.Itoa(len(selected)) + " of its versions"
+	}
+	err := confirmDelete(description, yes)
+	if err != nil {
+		return err
+	}
+
+	err = remove()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(kind + " " + name + " deleted")
+
+	return nil
+}
+
+type packageFileArg struct {
+	File string `positional-arg-name:"file" required:"yes" description:"Path to the .nupkg file"`
+}
+
+// packageInspection is a package read from disk along with the problems found in it
+type packageInspection struct {
+	*nupkg.Package
+	Problems []nupkg.Problem `json:"Problems"`
+}
+
+var packageDetailColumns = []output.Column{
+	{Header: "Id", Field: "Id"},
+	{Header: "Version", Field: "Version"},
+	{Header: "Title", Field: "Title"},
+	{Header: "Authors", Field: "Authors"},
+	{Header: "Description", Field: "Description"},
+	{Header: "Project", Field: "ProjectName"},
+	{Header: "Output Type", Field: "OutputType"},
+	{Header: "Target Framework", Field: "TargetFramework"},
+	{Header: "Expression Language", Field: "ExpressionLanguage"},
+	{Header: "Studio Version", Field: "StudioVersion"},
+	{Header: "Main", Field: "Main"},
+}
+
+var entryPointColumns = []output.Column{
+	{Header: "ENTRY POINT", Field: "FilePath"},
+	{Header: "UNIQUE ID", Field: "UniqueId", Wide: true},
+}
+
+var dependencyColumns = []output.Column{
+	{Header: "DEPENDENCY", Field: "Id"},
+	{Header: "VERSION", Field: "Version"},
+	{Header: "TARGET FRAMEWORK", Field: "TargetFramework", Wide: true},
+}
+
+// CmdPackagesInspect represents the flags supported by the packages inspect command
+type CmdPackagesInspect struct {
+	CheckFeed bool           `long:"check-feed" description:"Also check that the dependency versions are in the Orchestrator library feed"`
+	Args      packageFileArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesInspect) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesInspect) Execute(args []string) error {
+
+	pkg, err := nupkg.Open(cmd.Args.File)
+	if err != nil {
+		return err
+	}
+
+	inspection := packageInspection{Package: pkg, Problems: pkg.Validate()}
+
+	if cmd.CheckFeed {
+		client, err := orchestrator.NewClient(cmd.Config)
+		if err != nil {
+			return err
+		}
+		problems, err := feedDependencyProblems(client, pkg, map[string][]string{})
+		if err != nil {
+			return err
+		}
+		inspection.Problems = append(inspection.Problems, problems...)
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if !renderer.IsHuman() {
+		err = renderer.RenderOne(packageDetailColumns, inspection)
+	} else {
+		err = renderInspection(renderer, inspection)
+	}
+	if err != nil {
+		return err
+	}
+
+	if nupkg.HasErrors(inspection.Problems) {
+		return &ExitError{Code: ExitCodeError, Message: pkg.File + " has problems that stop it from being uploaded"}
+	}
+
+	return nil
+}
+
+// renderInspection writes the package details followed by its entry points,
+//  dependencies and problems
+func renderInspection(renderer *output.Renderer, inspection packageInspection) error {
+
+	err := renderer.RenderDetails(packageDetailColumns, inspection)
+	if err != nil {
+		return err
+	}
+
+	if len(inspection.EntryPoints) > 0 {
+		fmt.Println("")
+		err = renderer.Render(entryPointColumns, inspection.EntryPoints)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(inspection.Dependencies) > 0 {
+		fmt.Println("")
+		err = renderer.Render(dependencyColumns, inspection.Dependencies)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("")
+	if len(inspection.Problems) == 0 {
+		fmt.Println("No problems found")
+	}
+	for _, problem := range inspection.Problems {
+		fmt.Println(problem.Severity + ": " + problem.Message)
+	}
+
+	return nil
+}
+
+// feedDependencyProblems warns about dependencies whose version range is not met
+//  by any version in the tenant library feed.  UiPath activity packages are left
+//  out, since robots normally get them from the official UiPath feed
+// versions caches the feed versions by package ID across calls
+func feedDependencyProblems(client *orchestrator.Client, pkg *nupkg.Package, versions map[string][]string) ([]nupkg.Problem, error) {
+
+	var problems []nupkg.Problem
+	checked := map[string]bool{}
+	for _, dependency := range pkg.Dependencies {
+
+		// The same dependency is often listed for several target frameworks
+		if checked[dependency.ID+" "+dependency.Version] || strings.HasPrefix(strings.ToLower(dependency.ID), "uipath.") {
+			continue
+		}
+		checked[dependency.ID+" "+dependency.Version] = true
+		versionRange, err := nupkg.ParseVersionRange(dependency.Version)
+		if err != nil {
+			// Reported by Validate
+			continue
+		}
+
+		available, cached := versions[dependency.ID]
+		if !cached {
+			feedVersions, err := client.LibraryVersions(dependency.ID)
+			if err != nil && !orchestrator.IsStatus(err, http.StatusNotFound) {
+				return nil, err
+			}
+			for _, version := range feedVersions {
+				available = append(available, version.Version)
+			}
+			versions[dependency.ID] = available
+		}
+
+		if !versionRange.ContainsAny(available) {
+			message := "Dependency " + dependency.ID + " " + dependency.Version + " is not in the library feed"
+			if len(available) > 0 {
+				message = message + " (the feed has " + strconv.Itoa(len(available)) + " other version(s))"
+			}
+			problems = append(problems, nupkg.Problem{Severity: nupkg.SeverityWarning, Message: message})
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/util"
+)
+
+// packageDeleteServer serves versions 1.0.0 and 1.0.1 of the Invoices package in
+//  the tenant feed and records the keys deleted
+func packageDeleteServer(t *testing.T, deleted *[]string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "GetProcessVersions(processId='Invoices')"):
+			if r.URL.Query().Get("$skip") != "" {
+				fmt.Fprint(w, `{"value":[]}`)
+				return
+			}
+			fmt.Fprint(w, `{"value":[{"Id":"Invoices","Version":"1.0.0"},{"Id":"Invoices","Version":"1.0.1"}]}`)
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/odata/Processes("):
+			*deleted = append(*deleted, strings.TrimPrefix(r.URL.Path, "/odata/Processes"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestPackagesDelete(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cmd     CmdPackagesDelete
+		deleted []string
+		err     string
+	}{
+		{name: "every version", cmd: CmdPackagesDelete{Yes: true}, deleted: []string{"('Invoices')"}},
+		{name: "one version", cmd: CmdPackagesDelete{Yes: true, Version: "1.0.1"}, deleted: []string{"('Invoices:1.0.1')"}},
+		{name: "dry run", cmd: CmdPackagesDelete{DryRun: true}},
+		{name: "unknown version", cmd: CmdPackagesDelete{Yes: true, Version: "2.0.0"}, err: "Package Invoices 2.0.0 was not found in the feed"},
+		{name: "no terminal to confirm on", cmd: CmdPackagesDelete{}, err: "Deleting package Invoices and all 2 of its versions cannot be undone"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !test.cmd.Yes && !test.cmd.DryRun && util.IsTerminal(os.Stdin) {
+				t.Skip("stdin is a terminal")
+			}
+
+			var deleted []string
+			test.cmd.Config = newTestConfig(t, packageDeleteServer(t, &deleted))
+			test.cmd.Args.ID = "Invoices"
+
+			err := test.cmd.Execute(nil)
+			if (err == nil) != (test.err == "") || (err != nil && !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if fmt.Sprint(deleted) != fmt.Sprint(test.deleted) {
+				t.Errorf("deleted %v, want %v", deleted, test.deleted)
+			}
+		})
+	}
+}
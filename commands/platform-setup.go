@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+
+	"github.com/bcsimms/uipo/util"
 )
 
 // CmdPlatformSetup represents the flags this command supports
@@ -15,6 +18,9 @@ type CmdPlatformSetup struct {
 	ServiceLogicalName   string `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
 	ClientID             string `short:"c" long:"client-id" default:"5v7PmPJL6FOGu6RB8I1Y4adLBhIwovQN" descritpion:"Client ID - Used for UiPath Platform Installations.  Should not need to be overridden"`
 	FolderName           string `short:"f" long:"folder" description:"The UiPath folder to be used for subsequent API operations"`
+	SecretStore          string `long:"secret-store" choice:"none" choice:"file" choice:"secret-service" description:"Where tokens and secrets are kept.  none keeps them unencrypted in config.json, file uses UIPO_SECRET_PASSPHRASE or a key file"`
+	SecretKeyFile        string `long:"secret-key-file" description:"Key file used to encrypt the file secret store instead of a passphrase"`
+	AsyncTimeout         int    `long:"async-timeout" description:"Seconds to wait for jobs to finish with --wait (default 1800)"`
 
 	Config Config
 }
@@ -31,9 +37,18 @@ func (cmd *CmdPlatformSetup) Setup(config Config) error {
 func (cmd *CmdPlatformSetup) Execute(args []string) error {
 
 	if cmd.View {
+		// The rest of the setup is still shown when the secret store is locked
+		userToken := "(locked) "
+		if err := cmd.Config.UnlockSecrets(); err != nil {
+			userToken = userToken + err.Error()
+		} else {
+			userToken = util.MaskSecret(cmd.Config.GetRefreshToken())
+		}
+
 		fmt.Println("")
 		fmt.Println("Current UIPO Configuration")
 		fmt.Println("      Config Version: " + cmd.Config.GetConfigVersion())
+		fmt.Println("             Profile: " + cmd.Config.GetProfileName())
 		fmt.Println("         API Version: " + cmd.Config.GetAPIVersion())
 		fmt.Println("            API Type: " + cmd.Config.GetEndpointType())
 		fmt.Println("       Auth Endpoint: " + cmd.Config.GetAuthorizationEndpoint())
@@ -41,8 +56,10 @@ func (cmd *CmdPlatformSetup) Execute(args []string) error {
 		fmt.Println("      Default Folder: " + cmd.Config.GetFolderFQN() + "; ID: " + strconv.Itoa(cmd.Config.GetFolderID()))
 		fmt.Println("Account Logical Name: " + cmd.Config.GetAccountLogicalName())
 		fmt.Println("Service Logical Name: " + cmd.Config.GetServiceLogicalName())
-		fmt.Println("          User Token: " + cmd.Config.GetRefreshToken())
+		fmt.Println("          User Token: " + userToken)
 		fmt.Println("           Client ID: " + cmd.Config.GetClientID())
+		fmt.Println("        Secret Store: " + cmd.Config.GetSecretStore())
+		fmt.Println("       Async Timeout: " + strconv.Itoa(cmd.Config.GetAsyncTimeout()) + "s")
 		fmt.Println("")
 
 	} else {
@@ -53,6 +70,10 @@ func (cmd *CmdPlatformSetup) Execute(args []string) error {
 			cmd.Config.SetAuthorizationEndpoint(cmd.AuthticationEndpoint)
 		}
 		if cmd.RefreshToken != "" {
+			err := cmd.Config.UnlockSecrets()
+			if err != nil {
+				return err
+			}
 			cmd.Config.SetRefreshToken(cmd.RefreshToken)
 		}
 		if cmd.AccountLogicalName != "" {
@@ -67,6 +88,21 @@ func (cmd *CmdPlatformSetup) Execute(args []string) error {
 		if cmd.FolderName != "" {
 			cmd.Config.SetFolderName(cmd.FolderName)
 		}
+		if cmd.AsyncTimeout < 0 {
+			return errors.New("--async-timeout cannot be negative")
+		}
+		if cmd.AsyncTimeout > 0 {
+			cmd.Config.SetAsyncTimeout(cmd.AsyncTimeout)
+		}
+		if cmd.SecretKeyFile != "" {
+			cmd.Config.SetSecretKeyFile(cmd.SecretKeyFile)
+		}
+		if cmd.SecretStore != "" {
+			err := cmd.Config.SetSecretStore(cmd.SecretStore)
+			if err != nil {
+				return err
+			}
+		}
 
 	}
 
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+)
+
+// CmdProcess groups the commands used to manage processes (releases) in the
+//  folder saved with "folders -d".  A process runs a version of a package from the feed
+type CmdProcess struct {
+	List     CmdProcessList     `command:"list" description:"List processes in the current folder"`
+	Create   CmdProcessCreate   `command:"create" description:"Create a process from a package in the feed"`
+	Update   CmdProcessUpdate   `command:"update" description:"Change the package version a process runs"`
+	Rollback CmdProcessRollback `command:"rollback" description:"Return a process to its previous package version"`
+	Delete   CmdProcessDelete   `command:"delete" description:"Delete a process.  The package stays in the feed"`
+}
+
+type processNameArg struct {
+	Name string `positional-arg-name:"name" required:"yes" description:"Process name"`
+}
+
+var processColumns = []output.Column{
+	{Header: "PROCESS ID", Field: "Id"},
+	{Header: "NAME", Field: "Name"},
+	{Header: "PACKAGE", Field: "ProcessKey"},
+	{Header: "VERSION", Field: "ProcessVersion"},
+	{Header: "LATEST", Field: "IsLatestVersion"},
+	{Header: "KEY", Field: "Key", Wide: true},
+	{Header: "FOLDER", Field: "OrganizationUnitFullyQualifiedName", Wide: true},
+	{Header: "DESCRIPTION", Field: "Description", Wide: true},
+}
+
+// CmdProcessList represents the flags supported by the process list command
+type CmdProcessList struct {
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdProcessList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProcessList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewODataPager(orchestrator.ReleasesURI, query, paging)
+
+	return renderPages(cmd.Config, processColumns, pager, &[]orchestrator.Release{}, "No Processes returned")
+}
+
+// CmdProcessCreate represents the flags supported by the process create command
+type CmdProcessCreate struct {
+	Package     string         `short:"p" long:"package" required:"yes" description:"ID of the package in the feed the process runs"`
+	Version     string         `long:"version" description:"Package version to run.  Defaults to the latest version in the feed"`
+	Description string         `short:"d" long:"description" description:"Process description"`
+	Feed        FeedOptions    `group:"Feed Options"`
+	Args        processNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdProcessCreate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProcessCreate) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Version == "" {
+		feed, err := cmd.Feed.Feed(cmd.Config, client)
+		if err != nil {
+			return err
+		}
+		latest, err := client.LatestPackage(feed, cmd.Package)
+		if err != nil {
+			return err
+		}
+		cmd.Version = latest.Version
+	}
+
+	release, err := client.CreateRelease(orchestrator.NewRelease{
+		Name:           cmd.Args.Name,
+		ProcessKey:     cmd.Package,
+		ProcessVersion: cmd.Version,
+		Description:    cmd.Description,
+	})
+	if err != nil {
+		return err
+	}
+
+	return renderProcess(cmd.Config, "Process "+release.Name+" created with "+release.ProcessKey+" "+release.ProcessVersion, release)
+}
+
+// CmdProcessUpdate represents the flags supported by the process update command
+type CmdProcessUpdate struct {
+	Version string         `long:"version" description:"Package version to run"`
+	Latest  bool           `long:"latest" description:"Run the newest version of the package in the feed"`
+	Feed    FeedOptions    `group:"Feed Options"`
+	Args    processNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdProcessUpdate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProcessUpdate) Execute(args []string) error {
+
+	if cmd.Version == "" && !cmd.Latest {
+		return errors.New("A package version (--version) or --latest is required")
+	}
+	if cmd.Version != "" && cmd.Latest {
+		return errors.New("Use either --version or --latest, not both")
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	release, err := client.FindRelease(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Latest {
+		feed, err := cmd.Feed.Feed(cmd.Config, client)
+		if err != nil {
+			return err
+		}
+		latest, err := client.LatestPackage(feed, release.ProcessKey)
+		if err != nil {
+			return err
+		}
+		cmd.Version = latest.Version
+	}
+
+	if cmd.Version == release.ProcessVersion {
+		return renderProcess(cmd.Config, "Process "+release.Name+" already runs "+release.ProcessKey+" "+release.ProcessVersion, release)
+	}
+
+	err = client.UpdateReleaseVersion(release.ID, cmd.Version)
+	if err != nil {
+		return err
+	}
+
+	release, err = client.GetRelease(release.ID)
+	if err != nil {
+		return err
+	}
+
+	return renderProcess(cmd.Config, "Process "+release.Name+" updated to "+release.ProcessKey+" "+release.ProcessVersion, release)
+}
+
+// CmdProcessRollback represents the flags supported by the process rollback command
+type CmdProcessRollback struct {
+	Args processNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdProcessRollback) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProcessRollback) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	release, err := client.FindRelease(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	err = client.RollbackRelease(release.ID)
+	if err != nil {
+		return err
+	}
+
+	release, err = client.GetRelease(release.ID)
+	if err != nil {
+		return err
+	}
+
+	return renderProcess(cmd.Config, "Process "+release.Name+" rolled back to "+release.ProcessKey+" "+release.ProcessVersion, release)
+}
+
+// CmdProcessDelete represents the flags supported by the process delete command
+type CmdProcessDelete struct {
+	Args processNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdProcessDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProcessDelete) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	release, err := client.FindRelease(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteRelease(release.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Process " + release.Name + " deleted")
+
+	return nil
+}
+
+// renderProcess writes a process after a change, preceded by a status message
+//  in the human readable formats
+func renderProcess(conf Config, message string, release *orchestrator.Release) error {
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	if renderer.IsHuman() {
+		fmt.Println(message)
+		fmt.Println("")
+	}
+
+	return renderer.RenderOne(processColumns, release)
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// processFeedServer serves version 1.0.1 of the Invoices package in the tenant
+//  feed and 2.0.0 in the feed of folder 1, along with an Invoices process running
+//  1.0.0.  The version the process is created with or updated to is recorded
+func processFeedServer(t *testing.T, version *string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var sent struct {
+			PackageVersion string `json:"packageVersion"`
+			ProcessVersion string `json:"ProcessVersion"`
+		}
+		json.Unmarshal(body, &sent)
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/PackageFeeds/GetFolderFeed":
+			fmt.Fprint(w, `"feed-1"`)
+		case "GET /odata/Processes":
+			if r.URL.Query().Get("$filter") != "Id eq 'Invoices'" {
+				t.Errorf("package filter = %q", r.URL.Query().Get("$filter"))
+			}
+			if r.URL.Query().Get("feedId") == "feed-1" && r.Header.Get("X-UIPATH-OrganizationUnitId") == "1" {
+				fmt.Fprint(w, `{"value":[{"Id":"Invoices","Version":"2.0.0"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"value":[{"Id":"Invoices","Version":"1.0.1"}]}`)
+		case "GET /odata/Releases":
+			fmt.Fprint(w, `{"value":[{"Id":5,"Name":"Invoices","ProcessKey":"Invoices","ProcessVersion":"1.0.0"}]}`)
+		case "POST /odata/Releases(5)/UiPath.Server.Configuration.OData.UpdateToSpecificPackageVersion":
+			*version = sent.PackageVersion
+		case "GET /odata/Releases(5)":
+			fmt.Fprintf(w, `{"Id":5,"Name":"Invoices","ProcessKey":"Invoices","ProcessVersion":%q}`, *version)
+		case "POST /odata/Releases":
+			*version = sent.ProcessVersion
+			fmt.Fprintf(w, `{"Id":6,"Name":"Invoices","ProcessKey":"Invoices","ProcessVersion":%q}`, *version)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestProcessLatestVersionFeed(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		feed    FeedOptions
+		version string
+	}{
+		{"tenant feed", FeedOptions{}, "1.0.1"},
+		{"folder feed", FeedOptions{FolderFeed: true}, "2.0.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var updated string
+			update := CmdProcessUpdate{Latest: true, Feed: test.feed, Config: newTestConfig(t, processFeedServer(t, &updated))}
+			update.Args.Name = "Invoices"
+			if err := update.Execute(nil); err != nil {
+				t.Fatal(err)
+			}
+			if updated != test.version {
+				t.Errorf("process update --latest ran %q, want %q", updated, test.version)
+			}
+
+			var created string
+			create := CmdProcessCreate{Package: "Invoices", Feed: test.feed, Config: newTestConfig(t, processFeedServer(t, &created))}
+			create.Args.Name = "Invoices"
+			if err := create.Execute(nil); err != nil {
+				t.Fatal(err)
+			}
+			if created != test.version {
+				t.Errorf("process create ran %q, want %q", created, test.version)
+			}
+		})
+	}
+}
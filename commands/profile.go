@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/bcsimms/uipo/output"
+)
+
+// CmdProfile groups the commands used to manage named connection profiles
+//  Each profile holds its own endpoint, tenant, folder and token settings so that
+//  several Orchestrators can be used without re-running setup and auth
+type CmdProfile struct {
+	List   CmdProfileList   `command:"list" description:"List the profiles in the config file"`
+	Use    CmdProfileUse    `command:"use" description:"Set the default profile used by subsequent commands"`
+	Create CmdProfileCreate `command:"create" description:"Create a new profile"`
+	Delete CmdProfileDelete `command:"delete" description:"Delete a profile"`
+	Rename CmdProfileRename `command:"rename" description:"Rename a profile"`
+}
+
+// ProfileCommand is implemented by the profile commands.  They run even when the
+//  profile selected with --profile or UIPO_PROFILE does not exist, since they do
+//  not connect to Orchestrator and may be what creates it
+type ProfileCommand interface {
+	ManagesProfiles()
+}
+
+type profileNameArg struct {
+	Name string `positional-arg-name:"name" required:"yes" description:"Profile name"`
+}
+
+// CmdProfileList represents the flags supported by the profile list command
+type CmdProfileList struct {
+	Config Config
+}
+
+// ManagesProfiles marks the command as a ProfileCommand
+func (cmd *CmdProfileList) ManagesProfiles() {}
+
+// Setup is the standard setup function
+func (cmd *CmdProfileList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProfileList) Execute(args []string) error {
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	// The plain listing marks the default profile with "*"
+	if renderer.IsHuman() && cmd.Config.GetOutputQuery() == "" {
+		for _, name := range cmd.Config.ProfileNames() {
+			marker := " "
+			if name == cmd.Config.GetDefaultProfile() {
+				marker = "*"
+			}
+			fmt.Println(marker + " " + name)
+		}
+		return nil
+	}
+
+	profiles := []profileSummary{}
+	for _, name := range cmd.Config.ProfileNames() {
+		profiles = append(profiles, profileSummary{
+			Name:    name,
+			Default: name == cmd.Config.GetDefaultProfile(),
+		})
+	}
+
+	return renderer.Render(profileColumns, profiles)
+}
+
+// profileSummary is the structured form of a profile list entry
+type profileSummary struct {
+	Name    string `json:"Name"`
+	Default bool   `json:"Default"`
+}
+
+var profileColumns = []output.Column{
+	{Header: "NAME", Field: "Name"},
+	{Header: "DEFAULT", Field: "Default"},
+}
+
+// CmdProfileUse represents the flags supported by the profile use command
+type CmdProfileUse struct {
+	Args profileNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// ManagesProfiles marks the command as a ProfileCommand
+func (cmd *CmdProfileUse) ManagesProfiles() {}
+
+// Setup is the standard setup function
+func (cmd *CmdProfileUse) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProfileUse) Execute(args []string) error {
+
+	err := cmd.Config.UseProfile(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Default profile set to " + cmd.Args.Name)
+
+	return nil
+}
+
+// CmdProfileCreate represents the flags supported by the profile create command
+type CmdProfileCreate struct {
+	CopyFrom string         `long:"from" description:"Copy the endpoint and folder settings (but not tokens) of an existing profile"`
+	Use      bool           `long:"use" description:"Make the new profile the default"`
+	Args     profileNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// ManagesProfiles marks the command as a ProfileCommand
+func (cmd *CmdProfileCreate) ManagesProfiles() {}
+
+// Setup is the standard setup function
+func (cmd *CmdProfileCreate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProfileCreate) Execute(args []string) error {
+
+	err := cmd.Config.CreateProfile(cmd.Args.Name, cmd.CopyFrom)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Profile " + cmd.Args.Name + " created")
+
+	if cmd.Use {
+		return cmd.Config.UseProfile(cmd.Args.Name)
+	}
+
+	fmt.Println("Configure it with: " + cmd.Config.GetBinaryName() + " --profile " + cmd.Args.Name + " setup ...")
+
+	return nil
+}
+
+// CmdProfileDelete represents the flags supported by the profile delete command
+type CmdProfileDelete struct {
+	Args profileNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// ManagesProfiles marks the command as a ProfileCommand
+func (cmd *CmdProfileDelete) ManagesProfiles() {}
+
+// Setup is the standard setup function
+func (cmd *CmdProfileDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProfileDelete) Execute(args []string) error {
+
+	err := cmd.Config.DeleteProfile(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Profile " + cmd.Args.Name + " deleted")
+
+	return nil
+}
+
+// CmdProfileRename represents the flags supported by the profile rename command
+type CmdProfileRename struct {
+	Args struct {
+		OldName string `positional-arg-name:"old-name" required:"yes" description:"Current profile name"`
+		NewName string `positional-arg-name:"new-name" required:"yes" description:"New profile name"`
+	} `positional-args:"yes"`
+
+	Config Config
+}
+
+// ManagesProfiles marks the command as a ProfileCommand
+func (cmd *CmdProfileRename) ManagesProfiles() {}
+
+// Setup is the standard setup function
+func (cmd *CmdProfileRename) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdProfileRename) Execute(args []string) error {
+
+	err := cmd.Config.RenameProfile(cmd.Args.OldName, cmd.Args.NewName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Profile " + cmd.Args.OldName + " renamed to " + cmd.Args.NewName)
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bcsimms/uipo/nupkg"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
+)
+
+// Results of promoting a package
+const (
+	promotePromoted string = "Promoted"
+	promotePresent  string = "Already present"
+)
+
+// CmdPackagesPromote represents the flags supported by the packages promote command
+type CmdPackagesPromote struct {
+	From          string             `long:"from" required:"yes" description:"Profile of the Orchestrator to copy the package from"`
+	To            string             `long:"to" required:"yes" description:"Profile of the Orchestrator to copy the package to"`
+	Deploy        bool               `long:"deploy" description:"Update the processes that run this package in the target to the promoted version"`
+	DeployFolders []string           `long:"deploy-folder" description:"Target folder (fully qualified name or ID) to deploy to.  Repeat for several folders.  Defaults to the target profile's folder"`
+	AllFolders    bool               `long:"all-folders" description:"Deploy to every target folder you have access to"`
+	Args          packageVersionArgs `positional-args:"yes"`
+
+	Config Config
+}
+
+// packagePromotion is the result reported for a promoted package
+type packagePromotion struct {
+	ID          string       `json:"Id"`
+	Version     string       `json:"Version"`
+	From        string       `json:"From"`
+	To          string       `json:"To"`
+	Size        int64        `json:"Size"`
+	SHA256      string       `json:"Sha256"`
+	Result      string       `json:"Result"`
+	Deployments []deployment `json:"Deployments,omitempty"`
+}
+
+var promotionColumns = []output.Column{
+	{Header: "PACKAGE", Field: "Id"},
+	{Header: "VERSION", Field: "Version"},
+	{Header: "FROM", Field: "From"},
+	{Header: "TO", Field: "To"},
+	{Header: "RESULT", Field: "Result"},
+	{Header: "SIZE", Field: "Size", Wide: true},
+	{Header: "SHA256", Field: "Sha256", Wide: true},
+}
+
+// Setup is the standard setup function
+func (cmd *CmdPackagesPromote) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdPackagesPromote) Execute(args []string) error {
+
+	if cmd.From == cmd.To {
+		return errors.New("--from and --to must be different profiles")
+	}
+	for _, profile := range []string{cmd.From, cmd.To} {
+		if !hasProfile(cmd.Config, profile) {
+			return errors.New("Profile '" + profile + "' does not exist")
+		}
+	}
+	if !cmd.Deploy && (len(cmd.DeployFolders) > 0 || cmd.AllFolders) {
+		return errors.New("--deploy-folder and --all-folders are used with --deploy")
+	}
+	if len(cmd.DeployFolders) > 0 && cmd.AllFolders {
+		return errors.New("Use either --deploy-folder or --all-folders, not both")
+	}
+
+	promotion := packagePromotion{
+		ID:      cmd.Args.ID,
+		Version: cmd.Args.Version,
+		From:    cmd.From,
+		To:      cmd.To,
+	}
+
+	// Resolve the target folders first so that a mistyped folder fails before
+	//  anything is copied
+	var deployFolders []orchestrator.Folder
+	if cmd.Deploy {
+		err := withProfile(cmd.Config, cmd.To, func(client *orchestrator.Client) error {
+			var err error
+			deployFolders, err = resolveDeployFolders(cmd.Config, client, cmd.DeployFolders, cmd.AllFolders)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	tempDir, err := ioutil.TempDir("", "uipo-promote")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The upload uses the file name, so the download keeps the NuGet naming
+	packagePath := filepath.Join(tempDir, cmd.Args.ID+"."+cmd.Args.Version+".nupkg")
+
+	err = withProfile(cmd.Config, cmd.From, func(client *orchestrator.Client) error {
+		var err error
+		promotion.Size, err = downloadPackage(packagePath, cmd.Args.ID, cmd.Args.Version, func(w io.Writer) (int64, error) {
+			return client.DownloadPackage(orchestrator.Feed{}, cmd.Args.ID, cmd.Args.Version, w)
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	promotion.SHA256, err = fileChecksum(packagePath)
+	if err != nil {
+		return err
+	}
+	util.LogInfo("Downloaded " + util.FormatBytes(promotion.Size) + " from " + cmd.From + " with SHA256 " + promotion.SHA256)
+
+	pkg, err := nupkg.Open(packagePath)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(pkg.ID, cmd.Args.ID) || !nupkg.SameVersion(pkg.Version, cmd.Args.Version) {
+		return errors.New("The package downloaded from " + cmd.From + " is " + pkg.ID + " " + pkg.Version)
+	}
+
+	err = withProfile(cmd.Config, cmd.To, func(client *orchestrator.Client) error {
+
+		promotion.Result = promotePromoted
+		_, _, err := client.UploadPackage(context.Background(), packagePath, nil)
+		if orchestrator.IsStatus(err, http.StatusConflict) {
+			// The round trip check below tells a re-run apart from a different build
+			//  published under the same version
+			promotion.Result = promotePresent
+		} else if err != nil {
+			return err
+		}
+
+		err = verifyPromotion(client, promotion)
+		if err != nil {
+			return err
+		}
+
+		if cmd.Deploy {
+			promotion.Deployments = deployPackage(client, cmd.Args.ID, cmd.Args.Version, deployFolders)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if !renderer.IsHuman() {
+		err = renderer.RenderOne(promotionColumns, promotion)
+	} else {
+		err = renderPromotion(renderer, promotion, cmd.Deploy)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, deployment := range promotion.Deployments {
+		if deployment.Result == deployFailed {
+			return &ExitError{Code: ExitCodeError, Message: "One or more process updates failed"}
+		}
+	}
+
+	return nil
+}
+
+// renderPromotion writes the promotion followed by any process updates
+func renderPromotion(renderer *output.Renderer, promotion packagePromotion, deploy bool) error {
+
+	if promotion.Result == promotePresent {
+		fmt.Println(promotion.ID + " " + promotion.Version + " is already in " + promotion.To + ".  Checksum verified")
+	} else {
+		fmt.Println(promotion.ID + " " + promotion.Version + " copied from " + promotion.From + " to " + promotion.To + ".  Checksum verified")
+	}
+	fmt.Println("")
+
+	err := renderer.Render(promotionColumns, []packagePromotion{promotion})
+	if err != nil || !deploy {
+		return err
+	}
+
+	fmt.Println("")
+	if len(promotion.Deployments) == 0 {
+		fmt.Println("No processes use the promoted package")
+		return nil
+	}
+
+	return renderer.Render(deploymentColumns, promotion.Deployments)
+}
+
+// verifyPromotion downloads the package back from the target and compares its
+//  checksum with the package read from the source
+func verifyPromotion(client *orchestrator.Client, promotion packagePromotion) error {
+
+	hash := sha256.New()
+	_, err := client.DownloadPackage(orchestrator.Feed{}, promotion.ID, promotion.Version, hash)
+	if err != nil {
+		return errors.New("Unable to verify the package in " + promotion.To + ": " + err.Error())
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if checksum != promotion.SHA256 {
+		if promotion.Result == promotePresent {
+			return errors.New(promotion.To + " already has a different build of " + promotion.ID + " " + promotion.Version + " (SHA256 " + checksum + ")")
+		}
+		return errors.New("The package in " + promotion.To + " does not match the package in " + promotion.From + " (SHA256 " + checksum + " instead of " + promotion.SHA256 + ")")
+	}
+
+	return nil
+}
+
+// withProfile runs fn with a client for the named profile, then switches back to
+//  the profile the command started with.  Refreshed tokens are kept in each profile
+func withProfile(conf Config, profile string, fn func(client *orchestrator.Client) error) error {
+
+	original := conf.GetProfileName()
+	err := conf.SelectProfile(profile)
+	if err != nil {
+		return err
+	}
+	defer conf.SelectProfile(original)
+
+	client, err := orchestrator.NewClient(conf)
+	if err != nil {
+		return errors.New("Profile " + profile + ": " + err.Error())
+	}
+
+	return fn(client)
+}
+
+func hasProfile(conf Config, name string) bool {
+
+	for _, profile := range conf.ProfileNames() {
+		if profile == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileChecksum returns the hex encoded SHA256 of a file
+func fileChecksum(path string) (string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
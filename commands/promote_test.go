@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/nupkg"
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// testPackage returns a package file holding only the .nuspec manifest
+func testPackage(t *testing.T, id string, version string, description string) []byte {
+
+	file := &bytes.Buffer{}
+	archive := zip.NewWriter(file)
+	manifest, err := archive.Create(id + ".nuspec")
+	if err == nil {
+		_, err = fmt.Fprintf(manifest, `<package><metadata><id>%s</id><version>%s</version><description>%s</description></metadata></package>`, id, version, description)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file.Bytes()
+}
+
+// addTestProfile adds a signed in profile for an Orchestrator served by handler
+//  to a config using the default profile
+func addTestProfile(t *testing.T, conf *config.Config, name string, handler http.HandlerFunc) {
+
+	err := conf.CreateProfile(name, config.DefaultProfileName)
+	if err == nil {
+		err = conf.SelectProfile(name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ConfigFile = newTestConfig(t, handler).ConfigFile
+	if err := conf.SelectProfile(config.DefaultProfileName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// packageFeed is a fake process feed holding package files by <id>:<version>
+type packageFeed struct {
+	t        *testing.T
+	mu       sync.Mutex
+	packages map[string][]byte
+	uploads  int
+}
+
+func (f *packageFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	download := orchestrator.ProcessesURI + "/UiPath.Server.Configuration.OData.DownloadPackage(key='"
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, download):
+		file, ok := f.packages[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, download), "')")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(file)
+	case r.Method == "POST" && r.URL.Path == orchestrator.ProcessUploadURI:
+		upload, header, err := r.FormFile("file")
+		if err != nil {
+			f.t.Errorf("reading the upload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id, version, _ := nupkg.IdentityFromFile(header.Filename)
+		if _, ok := f.packages[id+":"+version]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.packages[id+":"+version], _ = ioutil.ReadAll(upload)
+		f.uploads++
+		fmt.Fprintf(w, `{"value":[{"Key":"%s:%s","Status":"OK"}]}`, id, version)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPackagesPromote(t *testing.T) {
+
+	build := testPackage(t, "Invoices", "1.0.2", "release build")
+	rebuild := testPackage(t, "Invoices", "1.0.2", "another build")
+
+	tests := []struct {
+		name    string
+		source  map[string][]byte
+		target  map[string][]byte
+		uploads int
+		err     string
+	}{
+		{"copied", map[string][]byte{"Invoices:1.0.2": build}, map[string][]byte{}, 1, ""},
+		{"already present", map[string][]byte{"Invoices:1.0.2": build}, map[string][]byte{"Invoices:1.0.2": build}, 0, ""},
+		{"different build in the target", map[string][]byte{"Invoices:1.0.2": build}, map[string][]byte{"Invoices:1.0.2": rebuild}, 0, "uat already has a different build of Invoices 1.0.2"},
+		{"missing from the source", map[string][]byte{}, map[string][]byte{}, 0, "Package Invoices 1.0.2 was not found in the feed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := &packageFeed{t: t, packages: test.source}
+			target := &packageFeed{t: t, packages: test.target}
+			conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("the default profile was used for %s %s", r.Method, r.URL)
+			})
+			conf.GlobalFlgs.OutputFormat = "json"
+			addTestProfile(t, conf, "dev", source.ServeHTTP)
+			addTestProfile(t, conf, "uat", target.ServeHTTP)
+
+			cmd := CmdPackagesPromote{From: "dev", To: "uat", Config: conf}
+			cmd.Args.ID, cmd.Args.Version = "Invoices", "1.0.2"
+			err := cmd.Execute(nil)
+
+			if (err == nil) != (test.err == "") || (err != nil && !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if target.uploads != test.uploads {
+				t.Errorf("%d uploads to the target, want %d", target.uploads, test.uploads)
+			}
+			if test.err == "" && !bytes.Equal(target.packages["Invoices:1.0.2"], build) {
+				t.Error("the target does not hold the source package")
+			}
+			if conf.GetProfileName() != config.DefaultProfileName {
+				t.Errorf("profile %q is selected after the promotion", conf.GetProfileName())
+			}
+		})
+	}
+}
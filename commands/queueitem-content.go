@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/util"
+)
+
+// queueItemTimeFormat is the UTC format Orchestrator expects for queue item dates
+const queueItemTimeFormat string = "2006-01-02T15:04:05.000Z"
+
+// buildSpecificContent combines --content and the --field values into the JSON
+//  object sent as a queue item's SpecificContent.  Fields are written key=value for
+//  text or key:=json for numbers, booleans, arrays and objects.  A value of @path is
+//  read from the file, or stdin for @-, and --content accepts @path too.  Fields
+//  replace keys of the same name in --content
+func buildSpecificContent(content string, fields []string) (json.RawMessage, error) {
+
+	object := map[string]json.RawMessage{}
+	if content != "" {
+		raw, err := readValueArg(content)
+		if err != nil {
+			return nil, err
+		}
+		object, err = parseContentObject(raw)
+		if err != nil {
+			return nil, errors.New("--content " + err.Error())
+		}
+	}
+
+	for _, field := range fields {
+		key, value, err := parseContentField(field)
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+
+	if len(object) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(object)
+}
+
+// parseContentField splits a key=value or key:=json field
+func parseContentField(field string) (string, json.RawMessage, error) {
+
+	idx := strings.Index(field, "=")
+	if idx < 1 {
+		return "", nil, errors.New("Invalid --field '" + field + "'.  Use key=value for text or key:=json for other values")
+	}
+
+	key := field[:idx]
+	typed := strings.HasSuffix(key, ":")
+	if typed {
+		key = strings.TrimSuffix(key, ":")
+	}
+	if key == "" {
+		return "", nil, errors.New("Invalid --field '" + field + "'.  The key is empty")
+	}
+
+	raw, err := readValueArg(field[idx+1:])
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !typed {
+		value, err := json.Marshal(string(raw))
+		return key, value, err
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if !json.Valid(raw) {
+		return "", nil, errors.New("--field " + key + " is not valid JSON.  Use " + key + "=value for text")
+	}
+
+	return key, json.RawMessage(raw), nil
+}
+
+// parseContentObject checks that raw is a JSON object and returns its keys
+func parseContentObject(raw []byte) (map[string]json.RawMessage, error) {
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return map[string]json.RawMessage{}, nil
+	}
+	if raw[0] != '{' {
+		return nil, errors.New("must be a JSON object, e.g. '{\"Name\":\"Value\"}'")
+	}
+
+	object := map[string]json.RawMessage{}
+	err := json.Unmarshal(raw, &object)
+	if err != nil {
+		return nil, errors.New("is not valid JSON: " + err.Error())
+	}
+
+	return object, nil
+}
+
+// readValueArg returns the value of an argument, reading @path from the file or
+//  @- from stdin.  One trailing line break is removed from file content
+func readValueArg(value string) ([]byte, error) {
+
+	if !strings.HasPrefix(value, "@") {
+		return []byte(value), nil
+	}
+
+	var raw []byte
+	var err error
+	if value == "@-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(value[1:])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(bytes.TrimSuffix(raw, []byte("\n")), []byte("\r")), nil
+}
+
+// mergeSpecificContent adds the keys of base that content does not set
+func mergeSpecificContent(base json.RawMessage, content json.RawMessage) json.RawMessage {
+
+	if len(base) == 0 {
+		return content
+	}
+	if len(content) == 0 {
+		return base
+	}
+
+	object := map[string]json.RawMessage{}
+	_ = json.Unmarshal(base, &object)
+	_ = json.Unmarshal(content, &object)
+	merged, _ := json.Marshal(object)
+
+	return merged
+}
+
+// normalizeQueueItemDates checks the item's DueDate and DeferDate, which may be
+//  relative to now such as +2h, and converts them to the UTC format Orchestrator expects
+func normalizeQueueItemDates(item *orchestrator.QueueItemData, now time.Time) error {
+
+	var due, deferred time.Time
+	var err error
+	if item.DueDate != "" {
+		due, err = util.ParseTime(item.DueDate, now)
+		if err != nil {
+			return errors.New("DueDate: " + err.Error())
+		}
+		item.DueDate = due.UTC().Format(queueItemTimeFormat)
+	}
+	if item.DeferDate != "" {
+		deferred, err = util.ParseTime(item.DeferDate, now)
+		if err != nil {
+			return errors.New("DeferDate: " + err.Error())
+		}
+		item.DeferDate = deferred.UTC().Format(queueItemTimeFormat)
+	}
+
+	if !due.IsZero() && !deferred.IsZero() && !deferred.Before(due) {
+		return errors.New("The DeferDate (postpone) " + item.DeferDate + " must be before the DueDate (deadline) " + item.DueDate)
+	}
+
+	return nil
+}
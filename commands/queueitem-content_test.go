@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func TestParseContentField(t *testing.T) {
+
+	dir := t.TempDir()
+	noteFile := filepath.Join(dir, "note.txt")
+	if err := ioutil.WriteFile(noteFile, []byte("from a file\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	linesFile := filepath.Join(dir, "lines.json")
+	if err := ioutil.WriteFile(linesFile, []byte(" [1, 2]\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field string
+		key   string
+		value string
+		err   string
+	}{
+		{"Invoice=INV-1", "Invoice", `"INV-1"`, ""},
+		{"Note=", "Note", `""`, ""},
+		{"Formula=a=b", "Formula", `"a=b"`, ""},
+		{"Amount=10", "Amount", `"10"`, ""},
+		{"Amount:=10", "Amount", `10`, ""},
+		{"Paid:=true", "Paid", `true`, ""},
+		{"Lines:=[1,2]", "Lines", `[1,2]`, ""},
+		{"Note=@" + noteFile, "Note", `"from a file"`, ""},
+		{"Lines:=@" + linesFile, "Lines", `[1, 2]`, ""},
+		{"Invoice", "", "", "Invalid --field 'Invoice'.  Use key=value for text or key:=json for other values"},
+		{"=INV-1", "", "", "Invalid --field '=INV-1'.  Use key=value for text or key:=json for other values"},
+		{":=1", "", "", "Invalid --field ':=1'.  The key is empty"},
+		{"Amount:=ten", "", "", "--field Amount is not valid JSON.  Use Amount=value for text"},
+	}
+
+	for _, test := range tests {
+		key, value, err := parseContentField(test.field)
+		if key != test.key || string(value) != test.value {
+			t.Errorf("parseContentField(%q) = %q, %s, want %q, %s", test.field, key, value, test.key, test.value)
+		}
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("parseContentField(%q) err = %v, want %q", test.field, err, test.err)
+		}
+	}
+}
+
+func TestBuildSpecificContent(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		fields  []string
+		want    string
+		err     string
+	}{
+		{"nothing given", "", nil, "", ""},
+		{"empty object", "{}", nil, "", ""},
+		{"content only", `{"Invoice":"INV-1"}`, nil, `{"Invoice":"INV-1"}`, ""},
+		{"fields replace content keys", `{"Invoice":"INV-1","Amount":1}`, []string{"Amount:=2", "Note=x"}, `{"Amount":2,"Invoice":"INV-1","Note":"x"}`, ""},
+		{"content that is not an object", `[1]`, nil, "", `--content must be a JSON object, e.g. '{"Name":"Value"}'`},
+		{"content that is not JSON", `{"Invoice":}`, nil, "", "--content is not valid JSON: invalid character '}' looking for beginning of value"},
+		{"invalid field", "", []string{"Invoice"}, "", "Invalid --field 'Invoice'.  Use key=value for text or key:=json for other values"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := buildSpecificContent(test.content, test.fields)
+			if string(content) != test.want {
+				t.Errorf("content = %s, want %s", content, test.want)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestMergeSpecificContent(t *testing.T) {
+
+	tests := []struct {
+		base    string
+		content string
+		want    string
+	}{
+		{"", "", ""},
+		{`{"Source":"cli"}`, "", `{"Source":"cli"}`},
+		{"", `{"Invoice":"INV-1"}`, `{"Invoice":"INV-1"}`},
+		{`{"Source":"cli","Invoice":"default"}`, `{"Invoice":"INV-1"}`, `{"Invoice":"INV-1","Source":"cli"}`},
+	}
+
+	for _, test := range tests {
+		merged := mergeSpecificContent([]byte(test.base), []byte(test.content))
+		if string(merged) != test.want {
+			t.Errorf("mergeSpecificContent(%s, %s) = %s, want %s", test.base, test.content, merged, test.want)
+		}
+	}
+}
+
+func TestNormalizeQueueItemDates(t *testing.T) {
+
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	tests := []struct {
+		name      string
+		due       string
+		deferred  string
+		wantDue   string
+		wantDefer string
+		err       string
+	}{
+		{"no dates", "", "", "", "", ""},
+		{"relative dates", "+1d", "+2h", "2026-10-18T07:30:00.000Z", "2026-10-17T09:30:00.000Z", ""},
+		{"RFC3339 is converted to UTC", "2026-10-20T12:00:00+02:00", "", "2026-10-20T10:00:00.000Z", "", ""},
+		{"dates are in the local zone", "2026-10-20", "", "2026-10-19T22:00:00.000Z", "", ""},
+		{"invalid due date", "tomorrow", "", "tomorrow", "", "DueDate: Invalid time 'tomorrow'.  Use RFC3339 (2006-01-02T15:04:05Z), a date (2006-01-02) or a relative time such as +2h or -7d"},
+		{"invalid defer date", "", "+2x", "", "+2x", "DeferDate: Invalid duration '2x'"},
+		{"defer date after the due date", "+1h", "+1h", "2026-10-17T08:30:00.000Z", "2026-10-17T08:30:00.000Z", "The DeferDate (postpone) 2026-10-17T08:30:00.000Z must be before the DueDate (deadline) 2026-10-17T08:30:00.000Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := orchestrator.QueueItemData{DueDate: test.due, DeferDate: test.deferred}
+			err := normalizeQueueItemDates(&item, now)
+			if item.DueDate != test.wantDue || item.DeferDate != test.wantDefer {
+				t.Errorf("dates = %q, %q, want %q, %q", item.DueDate, item.DeferDate, test.wantDue, test.wantDefer)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// Formats of a queue item file
+const (
+	queueFileCSV   string = "csv"
+	queueFileJSONL string = "jsonl"
+)
+
+// queueItemRecord is an item read from a queue item file.  Items that could not
+//  be read keep the error and are rejected without being sent
+type queueItemRecord struct {
+	line   int
+	item   orchestrator.QueueItemData
+	source json.RawMessage
+	err    string
+}
+
+// readQueueItemFile reads queue items from a CSV or JSON Lines file, or stdin when
+//  path is "-".  Columns or keys named Reference, Priority, DueDate (or Deadline)
+//  and DeferDate (or Postpone) set those fields and every other one becomes part of
+//  the SpecificContent.  defaults supplies the fields an item does not set
+func readQueueItemFile(path string, format string, defaults orchestrator.QueueItemData) ([]queueItemRecord, error) {
+
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = queueFileFormat(path, raw)
+	}
+
+	var records []queueItemRecord
+	if format == queueFileCSV {
+		records, err = readQueueItemCSV(bytes.NewReader(raw))
+	} else {
+		records, err = readQueueItemJSONL(bytes.NewReader(raw))
+	}
+	if err != nil {
+		return nil, errors.New("Unable to read " + path + ": " + err.Error())
+	}
+
+	for i := range records {
+		applyQueueItemDefaults(&records[i], defaults)
+	}
+
+	return records, nil
+}
+
+// queueFileFormat picks the format from the file extension, or from the content
+//  for stdin and other names
+func queueFileFormat(path string, raw []byte) string {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return queueFileCSV
+	case ".jsonl", ".ndjson", ".json":
+		return queueFileJSONL
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "{") {
+		return queueFileJSONL
+	}
+
+	return queueFileCSV
+}
+
+func readQueueItemCSV(r io.Reader) ([]queueItemRecord, error) {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Files saved by Excel start with a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var records []queueItemRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Blank lines are skipped and quoted fields may span lines, so take the
+		//  line the row starts on from the reader
+		line, _ := reader.FieldPos(0)
+
+		record := queueItemRecord{line: line}
+		fields := map[string]string{}
+		content := map[string]string{}
+		for i, value := range row {
+			if i >= len(header) {
+				record.err = "The row has " + strconv.Itoa(len(row)) + " columns but the header has " + strconv.Itoa(len(header))
+				break
+			}
+			fields[header[i]] = value
+			if !setQueueItemField(&record.item, header[i], value) {
+				content[header[i]] = value
+			}
+		}
+		record.source, _ = json.Marshal(fields)
+		if len(content) > 0 {
+			record.item.SpecificContent, _ = json.Marshal(content)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func readQueueItemJSONL(r io.Reader) ([]queueItemRecord, error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var records []queueItemRecord
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		record := queueItemRecord{line: line}
+		fields := map[string]json.RawMessage{}
+		err := json.Unmarshal([]byte(text), &fields)
+		if err != nil {
+			record.source, _ = json.Marshal(text)
+			record.err = "The line is not a JSON object: " + err.Error()
+			records = append(records, record)
+			continue
+		}
+		record.source = json.RawMessage(text)
+
+		content := map[string]json.RawMessage{}
+		for key, value := range fields {
+			if strings.EqualFold(key, "SpecificContent") {
+				err = json.Unmarshal(value, &content)
+				if err != nil {
+					record.err = "SpecificContent must be a JSON object"
+				}
+				continue
+			}
+			if !isQueueItemField(key) {
+				continue
+			}
+			var text string
+			if json.Unmarshal(value, &text) != nil {
+				record.err = key + " must be a JSON string"
+				continue
+			}
+			setQueueItemField(&record.item, key, text)
+		}
+		// Keys outside SpecificContent are added to it
+		for key, value := range fields {
+			if !strings.EqualFold(key, "SpecificContent") && !isQueueItemField(key) {
+				content[key] = value
+			}
+		}
+		if len(content) > 0 {
+			record.item.SpecificContent, _ = json.Marshal(content)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+func isQueueItemField(name string) bool {
+	return setQueueItemField(&orchestrator.QueueItemData{}, name, "")
+}
+
+// setQueueItemField sets the queue item field a column or key names and reports
+//  whether it named one
+func setQueueItemField(item *orchestrator.QueueItemData, name string, value string) bool {
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "reference":
+		item.Reference = value
+	case "priority":
+		item.Priority = value
+	case "duedate", "deadline":
+		item.DueDate = value
+	case "deferdate", "postpone":
+		item.DeferDate = value
+	default:
+		return false
+	}
+
+	return true
+}
+
+// checkQueueItemReferences rejects items without a reference, and items that
+//  repeat the reference of an earlier item.  Failures reported by the bulk API
+//  are matched to items by reference, so each one must be unique in the file
+func checkQueueItemReferences(records []queueItemRecord) {
+
+	lines := map[string]int{}
+	for i := range records {
+		record := &records[i]
+		if record.err != "" {
+			continue
+		}
+		if record.item.Reference == "" {
+			record.err = "A Reference is required"
+			continue
+		}
+		if line, found := lines[record.item.Reference]; found {
+			record.err = "The Reference is already used on line " + strconv.Itoa(line)
+			continue
+		}
+		lines[record.item.Reference] = record.line
+	}
+}
+
+// applyQueueItemDefaults fills the fields an item does not set and checks its priority
+func applyQueueItemDefaults(record *queueItemRecord, defaults orchestrator.QueueItemData) {
+
+	record.item.Name = defaults.Name
+	if record.item.DueDate == "" {
+		record.item.DueDate = defaults.DueDate
+	}
+	if record.item.DeferDate == "" {
+		record.item.DeferDate = defaults.DeferDate
+	}
+
+	switch strings.ToLower(record.item.Priority) {
+	case "":
+		record.item.Priority = defaults.Priority
+	case "low":
+		record.item.Priority = "Low"
+	case "normal":
+		record.item.Priority = "Normal"
+	case "high":
+		record.item.Priority = "High"
+	default:
+		if record.err == "" {
+			record.err = "Priority must be Low, Normal or High, not '" + record.item.Priority + "'"
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+func TestReadQueueItemCSV(t *testing.T) {
+
+	input := "\ufeffReference,Priority,Invoice,Note\n" +
+		"inv-1,High,INV-1,plain\n" +
+		"\n" +
+		"inv-2,,INV-2,\"spans\ntwo lines\"\n" +
+		"inv-3,Low,INV-3\n" +
+		"inv-4,Normal,INV-4,last\n"
+
+	records, err := readQueueItemCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line      int
+		reference string
+		priority  string
+		content   string
+		err       string
+	}{
+		{2, "inv-1", "High", `{"Invoice":"INV-1","Note":"plain"}`, ""},
+		{4, "inv-2", "", `{"Invoice":"INV-2","Note":"spans\ntwo lines"}`, ""},
+		{6, "inv-3", "Low", `{"Invoice":"INV-3"}`, ""},
+		{7, "inv-4", "Normal", `{"Invoice":"INV-4","Note":"last"}`, ""},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(records), len(tests))
+	}
+	for i, test := range tests {
+		record := records[i]
+		if record.line != test.line {
+			t.Errorf("record %d: line = %d, want %d", i, record.line, test.line)
+		}
+		if record.item.Reference != test.reference || record.item.Priority != test.priority {
+			t.Errorf("record %d: reference, priority = %q, %q, want %q, %q", i, record.item.Reference, record.item.Priority, test.reference, test.priority)
+		}
+		if string(record.item.SpecificContent) != test.content {
+			t.Errorf("record %d: content = %s, want %s", i, record.item.SpecificContent, test.content)
+		}
+		if record.err != test.err {
+			t.Errorf("record %d: err = %q, want %q", i, record.err, test.err)
+		}
+	}
+}
+
+func TestReadQueueItemCSVExtraColumns(t *testing.T) {
+
+	records, err := readQueueItemCSV(strings.NewReader("Reference,Invoice\ninv-1,INV-1,extra\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].err != "The row has 3 columns but the header has 2" {
+		t.Errorf("records = %+v, want a row with too many columns", records)
+	}
+}
+
+func TestReadQueueItemJSONL(t *testing.T) {
+
+	input := `{"Reference":"inv-1","Deadline":"+1d","SpecificContent":{"Invoice":"INV-1"},"Amount":10}` + "\n" +
+		"\n" +
+		`not json` + "\n" +
+		`{"Reference":7}` + "\n" +
+		`{"Reference":"inv-4","SpecificContent":[1]}` + "\n"
+
+	records, err := readQueueItemJSONL(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line int
+		err  string
+	}{
+		{1, ""},
+		{3, "The line is not a JSON object: invalid character 'o' in literal null (expecting 'u')"},
+		{4, "Reference must be a JSON string"},
+		{5, "SpecificContent must be a JSON object"},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(records), len(tests))
+	}
+	for i, test := range tests {
+		if records[i].line != test.line || records[i].err != test.err {
+			t.Errorf("record %d: line, err = %d, %q, want %d, %q", i, records[i].line, records[i].err, test.line, test.err)
+		}
+	}
+
+	item := records[0].item
+	if item.Reference != "inv-1" || item.DueDate != "+1d" {
+		t.Errorf("item = %+v, want reference inv-1 and due date +1d", item)
+	}
+	content := map[string]interface{}{}
+	_ = json.Unmarshal(item.SpecificContent, &content)
+	if !reflect.DeepEqual(content, map[string]interface{}{"Invoice": "INV-1", "Amount": float64(10)}) {
+		t.Errorf("content = %s, want the SpecificContent keys and the other keys", item.SpecificContent)
+	}
+}
+
+func TestApplyQueueItemDefaults(t *testing.T) {
+
+	defaults := orchestrator.QueueItemData{Name: "Invoices", Priority: "Normal", DueDate: "2026-10-20T00:00:00.000Z"}
+
+	tests := []struct {
+		priority string
+		want     string
+		err      string
+	}{
+		{"", "Normal", ""},
+		{"high", "High", ""},
+		{"LOW", "Low", ""},
+		{"urgent", "urgent", "Priority must be Low, Normal or High, not 'urgent'"},
+	}
+
+	for _, test := range tests {
+		record := queueItemRecord{item: orchestrator.QueueItemData{Priority: test.priority}}
+		applyQueueItemDefaults(&record, defaults)
+		if record.item.Priority != test.want || record.err != test.err {
+			t.Errorf("priority %q: got %q, %q, want %q, %q", test.priority, record.item.Priority, record.err, test.want, test.err)
+		}
+		if record.item.Name != "Invoices" || record.item.DueDate != defaults.DueDate {
+			t.Errorf("priority %q: defaults not applied: %+v", test.priority, record.item)
+		}
+	}
+}
+
+func TestCheckQueueItemReferences(t *testing.T) {
+
+	records := []queueItemRecord{
+		{line: 2, item: orchestrator.QueueItemData{Reference: "a"}},
+		{line: 3, item: orchestrator.QueueItemData{}},
+		{line: 4, item: orchestrator.QueueItemData{Reference: "b"}},
+		{line: 5, item: orchestrator.QueueItemData{Reference: "a"}},
+		{line: 6, item: orchestrator.QueueItemData{Reference: "c"}, err: "unreadable"},
+		{line: 7, item: orchestrator.QueueItemData{Reference: "c"}},
+	}
+
+	checkQueueItemReferences(records)
+
+	var errs []string
+	for _, record := range records {
+		errs = append(errs, record.err)
+	}
+	want := []string{"", "A Reference is required", "", "The Reference is already used on line 2", "unreadable", ""}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %q, want %q", errs, want)
+	}
+}
@@ -0,0 +1,607 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+)
+
+// CmdQueueItems groups the commands used to inspect and manage queue items in the
+//  folder saved with "folders -d".  Items are added with addq
+type CmdQueueItems struct {
+	List   CmdQueueItemsList   `command:"list" description:"List queue items in the current folder"`
+	Get    CmdQueueItemsGet    `command:"get" description:"Show a queue item, including its content, output and progress history"`
+	Retry  CmdQueueItemsRetry  `command:"retry" description:"Retry failed and abandoned queue items"`
+	Delete CmdQueueItemsDelete `command:"delete" description:"Delete queue items that have not been processed"`
+	Export CmdQueueItemsExport `command:"export" description:"Write queue items to a CSV or JSON file for reporting"`
+}
+
+// QueueItemFilters select the items that list, retry, delete and export work on
+type QueueItemFilters struct {
+	Queue     string   `short:"q" long:"queue" description:"Only select items in the named queue"`
+	Statuses  []string `long:"status" description:"Only select items with this status (e.g. Failed).  Repeat or comma separate for several statuses"`
+	Reference string   `short:"r" long:"reference" description:"Only select items with this reference.  End it with * to match references that start with it"`
+	Since     string   `long:"since" description:"Only select items created after this time.  RFC3339, a date or a duration ago such as 2h or 7d"`
+	Until     string   `long:"until" description:"Only select items created before this time.  RFC3339, a date or a duration ago such as 2h or 7d"`
+	Exception string   `long:"exception" choice:"Application" choice:"Business" description:"Only select items that failed with this type of exception"`
+}
+
+// queueItemBatchSize is the number of items retried or deleted in each request
+const queueItemBatchSize int = 100
+
+// queueItemLookupSize is the number of items looked up by ID in each request.  Each
+//  ID adds an "or" condition to the filter, and Orchestrator rejects filters with
+//  more than about 100 OData nodes
+const queueItemLookupSize int = 20
+
+type queueItemIDArg struct {
+	ID int `positional-arg-name:"id" required:"yes" description:"Queue item ID"`
+}
+
+type queueItemIDsArg struct {
+	IDs []int `positional-arg-name:"id" description:"Queue item IDs.  Use the filters to select items instead"`
+}
+
+var queueItemStatuses = []string{
+	orchestrator.QueueItemNew,
+	orchestrator.QueueItemInProgress,
+	orchestrator.QueueItemFailed,
+	orchestrator.QueueItemSuccessful,
+	orchestrator.QueueItemAbandoned,
+	orchestrator.QueueItemRetried,
+	orchestrator.QueueItemDeleted,
+}
+
+var queueItemListColumns = []output.Column{
+	{Header: "ITEM ID", Field: "Id"},
+	{Header: "REFERENCE", Field: "Reference"},
+	{Header: "STATUS", Field: "Status"},
+	{Header: "PRIORITY", Field: "Priority"},
+	{Header: "CREATION TIME", Field: "CreationTime"},
+	{Header: "EXCEPTION", Field: "ProcessingExceptionType"},
+	{Header: "QUEUE ID", Field: "QueueDefinitionId", Wide: true},
+	{Header: "REVIEW STATUS", Field: "ReviewStatus", Wide: true},
+	{Header: "RETRY", Field: "RetryNumber", Wide: true},
+	{Header: "ROBOT", Field: "Robot.Name", Wide: true},
+	{Header: "REASON", Field: "ProcessingException.Reason", Wide: true},
+}
+
+var queueItemDetailColumns = []output.Column{
+	{Header: "Item ID", Field: "Id"},
+	{Header: "Key", Field: "Key"},
+	{Header: "Queue ID", Field: "QueueDefinitionId"},
+	{Header: "Reference", Field: "Reference"},
+	{Header: "Status", Field: "Status"},
+	{Header: "Review Status", Field: "ReviewStatus"},
+	{Header: "Priority", Field: "Priority"},
+	{Header: "Robot", Field: "Robot.Name"},
+	{Header: "Creation Time", Field: "CreationTime"},
+	{Header: "Defer Date", Field: "DeferDate"},
+	{Header: "Due Date", Field: "DueDate"},
+	{Header: "Start Processing", Field: "StartProcessing"},
+	{Header: "End Processing", Field: "EndProcessing"},
+	{Header: "Retry Number", Field: "RetryNumber"},
+	{Header: "Retry Of", Field: "AncestorId"},
+	{Header: "Progress", Field: "Progress"},
+	{Header: "Exception", Field: "ProcessingExceptionType"},
+	{Header: "Reason", Field: "ProcessingException.Reason"},
+	{Header: "Details", Field: "ProcessingException.Details"},
+	{Header: "Specific Content", Field: "SpecificContent"},
+	{Header: "Output", Field: "Output"},
+}
+
+var queueItemHistoryColumns = []output.Column{
+	{Header: "ITEM ID", Field: "Id"},
+	{Header: "RETRY", Field: "RetryNumber"},
+	{Header: "STATUS", Field: "Status"},
+	{Header: "ROBOT", Field: "Robot.Name"},
+	{Header: "START PROCESSING", Field: "StartProcessing"},
+	{Header: "END PROCESSING", Field: "EndProcessing"},
+	{Header: "PROGRESS", Field: "Progress"},
+	{Header: "REASON", Field: "ProcessingException.Reason"},
+	{Header: "OUTPUT", Field: "Output", Wide: true},
+}
+
+// queueItemExportColumns use the field names as headers so that exports are easy
+//  to load into reporting tools
+var queueItemExportColumns = []output.Column{
+	{Header: "Id", Field: "Id"},
+	{Header: "QueueDefinitionId", Field: "QueueDefinitionId"},
+	{Header: "Reference", Field: "Reference"},
+	{Header: "Status", Field: "Status"},
+	{Header: "ReviewStatus", Field: "ReviewStatus"},
+	{Header: "Priority", Field: "Priority"},
+	{Header: "CreationTime", Field: "CreationTime"},
+	{Header: "DeferDate", Field: "DeferDate"},
+	{Header: "DueDate", Field: "DueDate"},
+	{Header: "StartProcessing", Field: "StartProcessing"},
+	{Header: "EndProcessing", Field: "EndProcessing"},
+	{Header: "RetryNumber", Field: "RetryNumber"},
+	{Header: "Robot", Field: "Robot.Name"},
+	{Header: "ExceptionType", Field: "ProcessingExceptionType"},
+	{Header: "ExceptionReason", Field: "ProcessingException.Reason"},
+	{Header: "Progress", Field: "Progress"},
+	{Header: "SpecificContent", Field: "SpecificContent"},
+	{Header: "Output", Field: "Output"},
+}
+
+// isSet reports whether any filter was given
+func (filters QueueItemFilters) isSet() bool {
+	return filters.Queue != "" || len(filters.Statuses) > 0 || filters.Reference != "" ||
+		filters.Since != "" || filters.Until != "" || filters.Exception != ""
+}
+
+// query builds the $filter condition for the filters.  The queue is looked up by
+//  name in the client's folder
+func (filters QueueItemFilters) query(client *orchestrator.Client) (string, error) {
+
+	var conditions []string
+
+	statusFilter, err := choiceFilter("Status", filters.Statuses, queueItemStatuses, "queue item status")
+	if err != nil {
+		return "", err
+	}
+	if statusFilter != "" {
+		conditions = append(conditions, "("+statusFilter+")")
+	}
+
+	if filters.Reference != "" {
+		if strings.HasSuffix(filters.Reference, "*") {
+			conditions = append(conditions, "startswith(Reference,"+orchestrator.ODataString(strings.TrimSuffix(filters.Reference, "*"))+")")
+		} else {
+			conditions = append(conditions, "Reference eq "+orchestrator.ODataString(filters.Reference))
+		}
+	}
+	if filters.Since != "" {
+		since, err := parseTimeAgo(filters.Since)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, "CreationTime ge "+odataTime(since))
+	}
+	if filters.Until != "" {
+		until, err := parseTimeAgo(filters.Until)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, "CreationTime lt "+odataTime(until))
+	}
+	if filters.Exception != "" {
+		conditions = append(conditions, "ProcessingExceptionType eq "+orchestrator.ODataString(filters.Exception+"Exception"))
+	}
+
+	// Checked last so that mistakes in the other filters are reported without a request
+	if filters.Queue != "" {
+		queue, err := client.FindQueue(filters.Queue)
+		if err != nil {
+			return "", err
+		}
+		conditions = append([]string{"QueueDefinitionId eq " + strconv.Itoa(queue.ID)}, conditions...)
+	}
+
+	return strings.Join(conditions, " and "), nil
+}
+
+// CmdQueueItemsList represents the flags supported by the queueitems list command
+type CmdQueueItemsList struct {
+	Filters QueueItemFilters `group:"Queue Item Filters"`
+	OData   ODataOptions     `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueueItemsList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueueItemsList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	filter, err := cmd.Filters.query(client)
+	if err != nil {
+		return err
+	}
+	query.And(filter)
+
+	// Most recent items first unless asked otherwise
+	if query.OrderBy == "" {
+		query.OrderBy = "CreationTime desc"
+	}
+	if query.Expand == "" {
+		query.Expand = "Robot"
+	}
+
+	pager := client.NewODataPager(orchestrator.QueueItemsURI, query, paging)
+
+	return renderPages(cmd.Config, queueItemListColumns, pager, &[]orchestrator.QueueItem{}, "No Queue Items returned")
+}
+
+// queueItemDetails is a queue item with every attempt made to process it
+type queueItemDetails struct {
+	orchestrator.QueueItem
+	ProgressHistory []orchestrator.QueueItem `json:"ProgressHistory"`
+}
+
+// CmdQueueItemsGet represents the flags supported by the queueitems get command
+type CmdQueueItemsGet struct {
+	Args queueItemIDArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueueItemsGet) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueueItemsGet) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	item, err := client.GetQueueItem(cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+	history, err := client.QueueItemHistory(cmd.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+	if !renderer.IsHuman() {
+		return renderer.RenderOne(queueItemDetailColumns, queueItemDetails{QueueItem: *item, ProgressHistory: history})
+	}
+
+	err = renderer.RenderDetails(queueItemDetailColumns, item)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	fmt.Println("")
+	fmt.Println("Progress History:")
+	return renderer.Render(queueItemHistoryColumns, history)
+}
+
+// queueItemChange describes how retry and delete change the selected items
+type queueItemChange struct {
+	verb     string
+	done     string
+	statuses []string
+	apply    func(client *orchestrator.Client, items []orchestrator.QueueItem) error
+}
+
+var retryQueueItems = queueItemChange{
+	verb:     "retry",
+	done:     "Retried",
+	statuses: []string{orchestrator.QueueItemFailed, orchestrator.QueueItemAbandoned},
+	apply:    (*orchestrator.Client).RetryQueueItems,
+}
+
+var deleteQueueItems = queueItemChange{
+	verb:     "delete",
+	done:     "Deleted",
+	statuses: []string{orchestrator.QueueItemNew},
+	apply:    (*orchestrator.Client).DeleteQueueItems,
+}
+
+// CmdQueueItemsRetry represents the flags supported by the queueitems retry command
+type CmdQueueItemsRetry struct {
+	DryRun  bool             `long:"dry-run" description:"Show the items that would be retried without retrying them"`
+	Filters QueueItemFilters `group:"Queue Item Filters"`
+	Args    queueItemIDsArg  `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueueItemsRetry) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueueItemsRetry) Execute(args []string) error {
+	return changeQueueItems(cmd.Config, cmd.Args.IDs, cmd.Filters, cmd.DryRun, retryQueueItems)
+}
+
+// CmdQueueItemsDelete represents the flags supported by the queueitems delete command
+type CmdQueueItemsDelete struct {
+	DryRun  bool             `long:"dry-run" description:"Show the items that would be deleted without deleting them"`
+	Filters QueueItemFilters `group:"Queue Item Filters"`
+	Args    queueItemIDsArg  `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueueItemsDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueueItemsDelete) Execute(args []string) error {
+	return changeQueueItems(cmd.Config, cmd.Args.IDs, cmd.Filters, cmd.DryRun, deleteQueueItems)
+}
+
+// changeQueueItems applies a change to the items given by ID or selected by the
+//  filters.  Items whose status the change does not apply to are skipped, and
+//  filters select only those statuses unless --status is given
+func changeQueueItems(conf Config, itemIDs []int, filters QueueItemFilters, dryRun bool, change queueItemChange) error {
+
+	if len(itemIDs) > 0 && filters.isSet() {
+		return errors.New("Give the IDs of the items to " + change.verb + " or filters to select them, not both")
+	}
+	if len(itemIDs) == 0 && !filters.isSet() {
+		return errors.New("Give the IDs of the items to " + change.verb + ", or filters such as --queue and --status to select them")
+	}
+
+	client, err := newFolderClient(conf)
+	if err != nil {
+		return err
+	}
+
+	var items []orchestrator.QueueItem
+	if len(itemIDs) > 0 {
+		items, err = findQueueItems(client, itemIDs)
+	} else {
+		if len(filters.Statuses) == 0 {
+			filters.Statuses = change.statuses
+		}
+		var filter string
+		filter, err = filters.query(client)
+		if err == nil {
+			items, err = client.ListQueueItems(orchestrator.ODataQuery{Filter: filter, OrderBy: "Id"}, orchestrator.Paging{All: true, PageSize: orchestrator.MaxPageSize})
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var selected []orchestrator.QueueItem
+	for _, item := range items {
+		if containsString(change.statuses, item.Status) {
+			selected = append(selected, item)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "Skipping item "+strconv.Itoa(item.ID)+" ("+item.Reference+").  Only "+strings.Join(change.statuses, " and ")+" items can be "+strings.ToLower(change.done)+", not "+item.Status)
+	}
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	if len(selected) == 0 {
+		if len(items) > 0 {
+			return errors.New("None of the queue items can be " + strings.ToLower(change.done))
+		}
+		if renderer.IsHuman() {
+			fmt.Println("No queue items match the filters")
+			fmt.Println("")
+			return nil
+		}
+		return renderer.Render(queueItemListColumns, selected)
+	}
+
+	if dryRun {
+		if renderer.IsHuman() {
+			fmt.Println("Would " + change.verb + " " + strconv.Itoa(len(selected)) + " queue item(s):")
+			fmt.Println("")
+		}
+		return renderer.Render(queueItemListColumns, selected)
+	}
+
+	for start := 0; start < len(selected); start += queueItemBatchSize {
+		end := start + queueItemBatchSize
+		if end > len(selected) {
+			end = len(selected)
+		}
+		err = change.apply(client, selected[start:end])
+		if err != nil {
+			if start > 0 {
+				return errors.New(change.done + " " + strconv.Itoa(start) + " of " + strconv.Itoa(len(selected)) + " queue item(s) before failing: " + err.Error())
+			}
+			return err
+		}
+	}
+
+	if renderer.IsHuman() {
+		fmt.Println(change.done + " " + strconv.Itoa(len(selected)) + " queue item(s)")
+		return nil
+	}
+
+	return renderer.Render(queueItemListColumns, selected)
+}
+
+// findQueueItems looks up items by ID in the client's folder.  Every item must exist
+func findQueueItems(client *orchestrator.Client, itemIDs []int) ([]orchestrator.QueueItem, error) {
+
+	var items []orchestrator.QueueItem
+	for start := 0; start < len(itemIDs); start += queueItemLookupSize {
+		end := start + queueItemLookupSize
+		if end > len(itemIDs) {
+			end = len(itemIDs)
+		}
+
+		conditions := make([]string, end-start)
+		for i, itemID := range itemIDs[start:end] {
+			conditions[i] = "Id eq " + strconv.Itoa(itemID)
+		}
+		batch, err := client.ListQueueItems(orchestrator.ODataQuery{Filter: strings.Join(conditions, " or ")}, orchestrator.Paging{All: true, PageSize: queueItemLookupSize})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+	}
+
+	found := map[int]bool{}
+	for _, item := range items {
+		found[item.ID] = true
+	}
+	var missing []string
+	for _, itemID := range itemIDs {
+		if !found[itemID] {
+			missing = append(missing, strconv.Itoa(itemID))
+			found[itemID] = true
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New("Queue item(s) " + strings.Join(missing, ", ") + " were not found in the current folder")
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items, nil
+}
+
+func containsString(values []string, value string) bool {
+
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CmdQueueItemsExport represents the flags supported by the queueitems export command
+type CmdQueueItemsExport struct {
+	Format        string           `long:"format" default:"csv" choice:"csv" choice:"json" description:"Format of the export"`
+	File          string           `short:"f" long:"file" description:"Write the export to this file instead of stdout"`
+	ContentFields []string         `long:"content-field" description:"Add a column for this SpecificContent key.  Repeat for several"`
+	Limit         int              `long:"limit" description:"Stop after this many items.  Every matching item is exported by default"`
+	Filters       QueueItemFilters `group:"Queue Item Filters"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueueItemsExport) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueueItemsExport) Execute(args []string) error {
+
+	if cmd.Limit < 0 {
+		return errors.New("--limit cannot be negative")
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	filter, err := cmd.Filters.query(client)
+	if err != nil {
+		return err
+	}
+
+	columns := append([]output.Column{}, queueItemExportColumns...)
+	for _, key := range cmd.ContentFields {
+		columns = append(columns, output.Column{Header: key, Field: "SpecificContent." + key})
+	}
+
+	query := orchestrator.ODataQuery{Filter: filter, OrderBy: "Id", Expand: "Robot"}
+	pager := client.NewODataPager(orchestrator.QueueItemsURI, query, orchestrator.Paging{All: true, PageSize: orchestrator.MaxPageSize, Limit: cmd.Limit})
+
+	if cmd.File == "" {
+		_, err = cmd.export(os.Stdout, pager, columns)
+		return err
+	}
+
+	file, err := os.Create(cmd.File)
+	if err != nil {
+		return err
+	}
+	count, err := cmd.export(file, pager, columns)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A partial export is removed rather than left looking complete
+		os.Remove(cmd.File)
+		return err
+	}
+
+	fmt.Println("Exported " + strconv.Itoa(count) + " queue item(s) to " + cmd.File)
+
+	return nil
+}
+
+// export writes every page of queue items to out and returns the number written
+func (cmd *CmdQueueItemsExport) export(out io.Writer, pager *orchestrator.ODataPager, columns []output.Column) (int, error) {
+
+	renderer, err := output.New(out, cmd.Format, cmd.Config.GetOutputQuery())
+	if err != nil {
+		return 0, err
+	}
+
+	err = renderer.Start(columns)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		// A new slice for each page, so that null fields do not keep the last page's values
+		page := []orchestrator.QueueItem{}
+		more, err := pager.Next(&page)
+		if err != nil {
+			return 0, err
+		}
+		if !more {
+			break
+		}
+		err = renderer.Items(page)
+		if err != nil {
+			return 0, err
+		}
+		err = renderer.Flush()
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = renderer.Finish()
+	if err != nil {
+		return 0, err
+	}
+
+	return renderer.Count(), nil
+}
@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestQueueItemFiltersQuery(t *testing.T) {
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("$filter") != "Name eq 'Invoices'" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{map[string]interface{}{"Id": 7, "Name": "Invoices"}}})
+	})
+
+	tests := []struct {
+		name    string
+		filters QueueItemFilters
+		filter  string
+		err     string
+	}{
+		{"no filters", QueueItemFilters{}, "", ""},
+		{
+			name:    "statuses are joined with or",
+			filters: QueueItemFilters{Statuses: []string{"failed,new"}},
+			filter:  "(Status eq 'Failed' or Status eq 'New')",
+		},
+		{
+			name:    "reference prefix",
+			filters: QueueItemFilters{Reference: "inv-*", Exception: "Business"},
+			filter:  "startswith(Reference,'inv-') and ProcessingExceptionType eq 'BusinessException'",
+		},
+		{
+			name:    "queue is looked up and comes first",
+			filters: QueueItemFilters{Queue: "Invoices", Reference: "o'neil"},
+			filter:  "QueueDefinitionId eq 7 and Reference eq 'o''neil'",
+		},
+		{
+			name:    "missing queue",
+			filters: QueueItemFilters{Queue: "Orders"},
+			err:     "No queue named 'Orders' was found in the current folder",
+		},
+		{
+			name:    "unknown status",
+			filters: QueueItemFilters{Statuses: []string{"Done"}},
+			err:     "Unknown queue item status 'Done'.  Use one of New, InProgress, Failed, Successful, Abandoned, Retried, Deleted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := test.filters.query(client)
+			if filter != test.filter {
+				t.Errorf("filter = %q, want %q", filter, test.filter)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestQueueItemFiltersQueryTimes(t *testing.T) {
+
+	filter, err := QueueItemFilters{Since: "2026-10-01", Until: "2026-10-02T12:00:00Z"}.query(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filter, "CreationTime ge 2026-") || !strings.HasSuffix(filter, " and CreationTime lt 2026-10-02T12:00:00.000Z") {
+		t.Errorf("filter = %q", filter)
+	}
+}
+
+func TestFindQueueItems(t *testing.T) {
+
+	var conditions []int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		items := []map[string]interface{}{}
+		if skip := r.URL.Query().Get("$skip"); skip != "" && skip != "0" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": items})
+			return
+		}
+		filter := r.URL.Query().Get("$filter")
+		conditions = append(conditions, strings.Count(filter, " or ")+1)
+
+		for _, condition := range strings.Split(filter, " or ") {
+			itemID, _ := strconv.Atoi(strings.TrimPrefix(condition, "Id eq "))
+			if itemID != 13 {
+				items = append(items, map[string]interface{}{"Id": itemID})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": items})
+	})
+
+	tests := []struct {
+		name       string
+		itemIDs    int
+		missing    bool
+		conditions []int
+	}{
+		{"one lookup", 5, false, []int{5}},
+		{"lookups are batched to keep filters small", 45, true, []int{20, 20, 5}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			conditions = nil
+			var itemIDs []int
+			for itemID := test.itemIDs; itemID > 0; itemID-- {
+				itemIDs = append(itemIDs, itemID)
+			}
+
+			items, err := findQueueItems(client, itemIDs)
+
+			if test.missing {
+				if err == nil || err.Error() != "Queue item(s) 13 were not found in the current folder" {
+					t.Errorf("err = %v, want item 13 missing", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(items) != test.itemIDs || items[0].ID != 1 {
+					t.Errorf("items = %+v, want %d sorted by ID", items, test.itemIDs)
+				}
+			}
+			if !reflect.DeepEqual(conditions, test.conditions) {
+				t.Errorf("conditions per request = %v, want %v", conditions, test.conditions)
+			}
+		})
+	}
+}
+
+func TestQueueItemsExport(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// failPage makes the request for this page fail, 0 for none
+		failPage int
+		items    []map[string]string
+		err      string
+	}{
+		{
+			name: "every page is written without the last page's values",
+			items: []map[string]string{
+				{"Reference": "inv-1", "Status": "Failed", "ExceptionType": "BusinessException", "ExceptionReason": "Bad invoice", "EndProcessing": "2026-10-17T09:00:00Z", "DeferDate": "2026-10-16T09:00:00Z", "Robot": "Bot1"},
+				{"Reference": "inv-2", "Status": "Successful", "ExceptionType": "", "ExceptionReason": "", "EndProcessing": "", "DeferDate": "", "Robot": ""},
+			},
+		},
+		{
+			name:     "a failed page removes the partial file",
+			failPage: 2,
+			err:      "API Request Failed: 500 Internal Server Error - Timeout (Error Code: 1)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			conf := newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				page := 1
+				if r.URL.Query().Get("page") != "" {
+					page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+				}
+				if page == test.failPage {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(`{"message":"Timeout","errorCode":1}`))
+					return
+				}
+				if skip := r.URL.Query().Get("$skip"); skip != "" && skip != "0" {
+					w.Write([]byte(`{"value":[]}`))
+					return
+				}
+				// Page 1 is a failed item and page 2 a successful one, with nulls for
+				//  the fields only failed items have
+				if page == 2 {
+					w.Write([]byte(`{"value":[{"Id":2,"Reference":"inv-2","Status":"Successful","ProcessingExceptionType":null,"ProcessingException":null,"DeferDate":null,"Robot":null}]}`))
+					return
+				}
+				w.Write([]byte(`{"@odata.nextLink":"QueueItems?page=2","value":[{"Id":1,"Reference":"inv-1","Status":"Failed",` +
+					`"ProcessingExceptionType":"BusinessException","ProcessingException":{"Reason":"Bad invoice"},` +
+					`"EndProcessing":"2026-10-17T09:00:00Z","DeferDate":"2026-10-16T09:00:00Z","Robot":{"Name":"Bot1"}}]}`))
+			})
+
+			file := filepath.Join(t.TempDir(), "items.csv")
+			cmd := CmdQueueItemsExport{Format: "csv", File: file, Config: conf}
+
+			err := cmd.Execute(nil)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("err = %v, want %q", err, test.err)
+				}
+				if _, statErr := os.Stat(file); !os.IsNotExist(statErr) {
+					t.Errorf("the partial export was left at %s", file)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			export, _ := os.Open(file)
+			defer export.Close()
+			rows, err := csv.NewReader(export).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 3 {
+				t.Fatalf("export has %d rows, want a header and 2 items: %q", len(rows), rows)
+			}
+			got := []map[string]string{}
+			for _, row := range rows[1:] {
+				fields := map[string]string{}
+				for i, header := range rows[0] {
+					switch header {
+					case "Reference", "Status", "ExceptionType", "ExceptionReason", "EndProcessing", "DeferDate", "Robot":
+						fields[header] = row[i]
+					}
+				}
+				got = append(got, fields)
+			}
+			if !reflect.DeepEqual(got, test.items) {
+				t.Errorf("exported items = %v, want %v", got, test.items)
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
+	"github.com/bcsimms/uipo/util"
+)
+
+// CmdQueues groups the commands used to manage queues in the folder saved with
+//  "folders -d".  Items are added to a queue with addq
+type CmdQueues struct {
+	List   CmdQueuesList   `command:"list" description:"List queues in the current folder"`
+	Get    CmdQueuesGet    `command:"get" description:"Show a queue's settings"`
+	Create CmdQueuesCreate `command:"create" description:"Create a queue"`
+	Update CmdQueuesUpdate `command:"update" description:"Change a queue's settings"`
+	Delete CmdQueuesDelete `command:"delete" description:"Delete a queue and its items"`
+	Stats  CmdQueuesStats  `command:"stats" description:"Show item counts and the average processing time of queues"`
+}
+
+// QueueOptions are the queue settings shared by create and update.  Options that
+//  are not given keep their current value on update
+type QueueOptions struct {
+	Description    string `short:"d" long:"description" description:"Queue description"`
+	AutoRetry      string `long:"auto-retry" choice:"true" choice:"false" description:"Retry items that fail with an application exception"`
+	MaxRetries     *int   `long:"max-retries" description:"Number of times a failed item is retried"`
+	SLA            string `long:"sla" description:"Time within which items must be processed, e.g. 4h or 2d.  0 removes the SLA"`
+	RiskSLA        string `long:"risk-sla" description:"Time after which items are at risk of missing the SLA, e.g. 3h.  0 removes it"`
+	SpecificSchema string `long:"specific-schema" description:"JSON schema file that the SpecificContent of items must match.  none removes the schema"`
+	OutputSchema   string `long:"output-schema" description:"JSON schema file that the output of processed items must match.  none removes the schema"`
+}
+
+type queueNameArg struct {
+	Name string `positional-arg-name:"name" required:"yes" description:"Queue name"`
+}
+
+var queueColumns = []output.Column{
+	{Header: "QUEUE ID", Field: "Id"},
+	{Header: "NAME", Field: "Name"},
+	{Header: "UNIQUE REFERENCE", Field: "EnforceUniqueReference"},
+	{Header: "AUTO RETRY", Field: "AcceptAutomaticallyRetry"},
+	{Header: "MAX RETRIES", Field: "MaxNumberOfRetries"},
+	{Header: "SLA (MIN)", Field: "SlaInMinutes", Wide: true},
+	{Header: "RISK SLA (MIN)", Field: "RiskSlaInMinutes", Wide: true},
+	{Header: "DESCRIPTION", Field: "Description", Wide: true},
+}
+
+var queueDetailColumns = []output.Column{
+	{Header: "Queue ID", Field: "Id"},
+	{Header: "Key", Field: "Key"},
+	{Header: "Name", Field: "Name"},
+	{Header: "Description", Field: "Description"},
+	{Header: "Unique Reference", Field: "EnforceUniqueReference"},
+	{Header: "Auto Retry", Field: "AcceptAutomaticallyRetry"},
+	{Header: "Max Retries", Field: "MaxNumberOfRetries"},
+	{Header: "SLA (Minutes)", Field: "SlaInMinutes"},
+	{Header: "Risk SLA (Minutes)", Field: "RiskSlaInMinutes"},
+	{Header: "Specific Data Schema", Field: "SpecificDataJsonSchema"},
+	{Header: "Output Data Schema", Field: "OutputDataJsonSchema"},
+	{Header: "Created", Field: "CreationTime"},
+}
+
+// CmdQueuesList represents the flags supported by the queues list command
+type CmdQueuesList struct {
+	OData ODataOptions `group:"OData Query Options"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesList) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesList) Execute(args []string) error {
+
+	query, err := cmd.OData.Query()
+	if err != nil {
+		return err
+	}
+	paging, err := cmd.OData.Paging()
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	pager := client.NewODataPager(orchestrator.QueueDefinitionsURI, query, paging)
+
+	return renderPages(cmd.Config, queueColumns, pager, &[]orchestrator.QueueDefinition{}, "No Queues returned")
+}
+
+// CmdQueuesGet represents the flags supported by the queues get command
+type CmdQueuesGet struct {
+	Args queueNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesGet) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesGet) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	queue, err := client.FindQueue(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	return renderQueue(cmd.Config, "", queue)
+}
+
+// CmdQueuesCreate represents the flags supported by the queues create command
+type CmdQueuesCreate struct {
+	UniqueReference bool         `long:"unique-reference" description:"Reject items whose reference is already in the queue.  This cannot be changed later"`
+	Options         QueueOptions `group:"Queue Options"`
+	Args            queueNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesCreate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesCreate) Execute(args []string) error {
+
+	newQueue := orchestrator.QueueDefinition{
+		Name:                   cmd.Args.Name,
+		EnforceUniqueReference: cmd.UniqueReference,
+	}
+	err := cmd.Options.apply(&newQueue)
+	if err != nil {
+		return err
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	queue, err := client.CreateQueue(newQueue)
+	if err != nil {
+		return err
+	}
+
+	return renderQueue(cmd.Config, "Queue "+queue.Name+" created", queue)
+}
+
+// CmdQueuesUpdate represents the flags supported by the queues update command
+type CmdQueuesUpdate struct {
+	Options QueueOptions `group:"Queue Options"`
+	Args    queueNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesUpdate) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesUpdate) Execute(args []string) error {
+
+	if cmd.Options == (QueueOptions{}) {
+		return errors.New("Nothing to update.  Give the settings to change")
+	}
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	queue, err := client.FindQueue(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Options.apply(queue)
+	if err != nil {
+		return err
+	}
+
+	err = client.UpdateQueue(*queue)
+	if err != nil {
+		return err
+	}
+
+	queue, err = client.GetQueue(queue.ID)
+	if err != nil {
+		return err
+	}
+
+	return renderQueue(cmd.Config, "Queue "+queue.Name+" updated", queue)
+}
+
+// CmdQueuesDelete represents the flags supported by the queues delete command
+type CmdQueuesDelete struct {
+	Yes    bool         `short:"y" long:"yes" description:"Delete without asking for confirmation"`
+	DryRun bool         `long:"dry-run" description:"Show the queue that would be deleted without deleting it"`
+	Args   queueNameArg `positional-args:"yes"`
+
+	Config Config
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesDelete) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesDelete) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	queue, err := client.FindQueue(cmd.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	if cmd.DryRun {
+		return renderQueue(cmd.Config, "Would delete queue "+queue.Name+" and its items:", queue)
+	}
+
+	err = confirmDelete("queue "+queue.Name+" and all of its items", cmd.Yes)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteQueue(queue.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Queue " + queue.Name + " deleted")
+
+	return nil
+}
+
+// CmdQueuesStats represents the flags supported by the queues stats command
+type CmdQueuesStats struct {
+	Args struct {
+		Name string `positional-arg-name:"name" description:"Queue name.  Defaults to every queue in the folder"`
+	} `positional-args:"yes"`
+
+	Config Config
+}
+
+// queueStats is the status of a queue as reported by queues stats
+type queueStats struct {
+	Queue                 string  `json:"Queue"`
+	New                   int     `json:"New"`
+	InProgress            int     `json:"InProgress"`
+	Successful            int     `json:"Successful"`
+	Failed                int     `json:"Failed"`
+	ApplicationExceptions int     `json:"ApplicationExceptions"`
+	BusinessExceptions    int     `json:"BusinessExceptions"`
+	AverageSeconds        float64 `json:"AverageSeconds"`
+	AverageTime           string  `json:"AverageTime"`
+	LastProcessed         string  `json:"LastProcessed"`
+}
+
+var queueStatsColumns = []output.Column{
+	{Header: "QUEUE", Field: "Queue"},
+	{Header: "NEW", Field: "New"},
+	{Header: "IN PROGRESS", Field: "InProgress"},
+	{Header: "SUCCESSFUL", Field: "Successful"},
+	{Header: "FAILED", Field: "Failed"},
+	{Header: "AVERAGE TIME", Field: "AverageTime"},
+	{Header: "APPLICATION EXCEPTIONS", Field: "ApplicationExceptions", Wide: true},
+	{Header: "BUSINESS EXCEPTIONS", Field: "BusinessExceptions", Wide: true},
+	{Header: "LAST PROCESSED", Field: "LastProcessed", Wide: true},
+}
+
+// Setup is the standard setup function
+func (cmd *CmdQueuesStats) Setup(config Config) error {
+	cmd.Config = config
+	return nil
+}
+
+// Execute is the main entry point for this command
+func (cmd *CmdQueuesStats) Execute(args []string) error {
+
+	client, err := newFolderClient(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	query := orchestrator.ODataQuery{OrderBy: "QueueDefinitionName"}
+	if cmd.Args.Name != "" {
+		query.Filter = "QueueDefinitionName eq " + orchestrator.ODataString(cmd.Args.Name)
+	}
+
+	statuses, err := client.QueueStatuses(query)
+	if err != nil {
+		return err
+	}
+	if cmd.Args.Name != "" && len(statuses) == 0 {
+		return errors.New("No queue named '" + cmd.Args.Name + "' was found in the current folder")
+	}
+
+	stats := make([]queueStats, len(statuses))
+	for i, status := range statuses {
+		stats[i] = queueStats{
+			Queue:                 status.QueueDefinitionName,
+			New:                   status.ItemsToProcess,
+			InProgress:            status.ItemsInProgress,
+			Successful:            status.SuccessfulTransactionsNo,
+			Failed:                status.ApplicationExceptionsNo + status.BusinessExceptionsNo,
+			ApplicationExceptions: status.ApplicationExceptionsNo,
+			BusinessExceptions:    status.BusinessExceptionsNo,
+			AverageSeconds:        status.ProcessingMeanTime,
+			AverageTime:           (time.Duration(status.ProcessingMeanTime * float64(time.Second))).Round(time.Second).String(),
+			LastProcessed:         status.LastProcessed,
+		}
+	}
+
+	renderer, err := newRenderer(cmd.Config)
+	if err != nil {
+		return err
+	}
+	if renderer.IsHuman() && len(stats) == 0 {
+		fmt.Println("No Queues returned")
+		return nil
+	}
+
+	return renderer.Render(queueStatsColumns, stats)
+}
+
+// apply sets the options that were given on queue
+func (opts QueueOptions) apply(queue *orchestrator.QueueDefinition) error {
+
+	if opts.Description != "" {
+		queue.Description = opts.Description
+	}
+	if opts.AutoRetry != "" {
+		queue.AcceptAutomaticallyRetry = opts.AutoRetry == "true"
+	}
+	if opts.MaxRetries != nil {
+		if *opts.MaxRetries < 0 {
+			return errors.New("--max-retries cannot be negative")
+		}
+		queue.MaxNumberOfRetries = *opts.MaxRetries
+	}
+
+	var err error
+	if opts.SLA != "" {
+		queue.SlaInMinutes, err = slaMinutes("--sla", opts.SLA)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.RiskSLA != "" {
+		queue.RiskSlaInMinutes, err = slaMinutes("--risk-sla", opts.RiskSLA)
+		if err != nil {
+			return err
+		}
+	}
+	if queue.RiskSlaInMinutes > 0 && (queue.SlaInMinutes == 0 || queue.RiskSlaInMinutes >= queue.SlaInMinutes) {
+		return errors.New("The risk SLA must be shorter than the SLA")
+	}
+
+	if opts.SpecificSchema != "" {
+		queue.SpecificDataJSONSchema, err = readQueueSchema("--specific-schema", opts.SpecificSchema)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.OutputSchema != "" {
+		queue.OutputDataJSONSchema, err = readQueueSchema("--output-schema", opts.OutputSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// slaMinutes converts an SLA such as 4h or 2d to whole minutes
+func slaMinutes(flag string, value string) (int, error) {
+
+	if value == "0" {
+		return 0, nil
+	}
+
+	duration, err := util.ParseDuration(value)
+	if err != nil || duration < time.Minute {
+		return 0, errors.New(flag + " must be a duration of at least a minute, e.g. 90m, 4h or 2d")
+	}
+
+	return int(duration / time.Minute), nil
+}
+
+// readQueueSchema reads a JSON schema file.  none removes the schema
+func readQueueSchema(flag string, path string) (string, error) {
+
+	if path == "none" {
+		return "", nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	schema := map[string]interface{}{}
+	err = json.Unmarshal(raw, &schema)
+	if err != nil {
+		return "", errors.New(flag + " " + path + " is not a JSON schema object: " + err.Error())
+	}
+
+	// Orchestrator shows the schema as a single line
+	compact := bytes.Buffer{}
+	err = json.Compact(&compact, raw)
+	if err != nil {
+		return "", err
+	}
+
+	return compact.String(), nil
+}
+
+// renderQueue writes a queue, preceded by a status message in the human readable
+//  formats when one is given
+func renderQueue(conf Config, message string, queue *orchestrator.QueueDefinition) error {
+
+	renderer, err := newRenderer(conf)
+	if err != nil {
+		return err
+	}
+
+	if !renderer.IsHuman() {
+		return renderer.RenderOne(queueColumns, queue)
+	}
+
+	if message != "" {
+		fmt.Println(message)
+		fmt.Println("")
+	}
+
+	return renderer.RenderDetails(queueDetailColumns, queue)
+}
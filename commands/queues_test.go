@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/util"
+)
+
+func TestQueuesDelete(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cmd     CmdQueuesDelete
+		deleted bool
+		err     string
+	}{
+		{name: "with --yes", cmd: CmdQueuesDelete{Yes: true}, deleted: true},
+		{name: "dry run", cmd: CmdQueuesDelete{DryRun: true}},
+		{name: "no terminal to confirm on", cmd: CmdQueuesDelete{}, err: "Deleting queue Invoices and all of its items cannot be undone"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !test.cmd.Yes && !test.cmd.DryRun && util.IsTerminal(os.Stdin) {
+				t.Skip("stdin is a terminal")
+			}
+
+			deleted := false
+			test.cmd.Config = newTestConfig(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method + " " + r.URL.Path {
+				case "GET /odata/QueueDefinitions":
+					fmt.Fprint(w, `{"value":[{"Id":7,"Name":"Invoices"}]}`)
+				case "DELETE /odata/QueueDefinitions(7)":
+					deleted = true
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+			test.cmd.Args.Name = "Invoices"
+
+			err := test.cmd.Execute(nil)
+			if (err == nil) != (test.err == "") || (err != nil && !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if deleted != test.deleted {
+				t.Errorf("deleted = %v, want %v", deleted, test.deleted)
+			}
+		})
+	}
+}
+
+func TestSlaMinutes(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		minutes int
+		err     string
+	}{
+		{"0", 0, ""},
+		{"90m", 90, ""},
+		{"4h", 240, ""},
+		{"2d", 2880, ""},
+		{"1h30m", 90, ""},
+		{"30s", 0, "--sla must be a duration of at least a minute, e.g. 90m, 4h or 2d"},
+		{"soon", 0, "--sla must be a duration of at least a minute, e.g. 90m, 4h or 2d"},
+		{"-1h", 0, "--sla must be a duration of at least a minute, e.g. 90m, 4h or 2d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			minutes, err := slaMinutes("--sla", test.value)
+			if minutes != test.minutes {
+				t.Errorf("slaMinutes = %d, want %d", minutes, test.minutes)
+			}
+			if (err == nil) != (test.err == "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestQueueOptionsApply(t *testing.T) {
+
+	negative := -1
+	three := 3
+
+	tests := []struct {
+		name    string
+		current orchestrator.QueueDefinition
+		opts    QueueOptions
+		want    orchestrator.QueueDefinition
+		err     string
+	}{
+		{"new settings", orchestrator.QueueDefinition{}, QueueOptions{AutoRetry: "true", MaxRetries: &three, SLA: "4h", RiskSLA: "3h"}, orchestrator.QueueDefinition{AcceptAutomaticallyRetry: true, MaxNumberOfRetries: 3, SlaInMinutes: 240, RiskSlaInMinutes: 180}, ""},
+		{"unset options are kept", orchestrator.QueueDefinition{Description: "Invoices", SlaInMinutes: 240, RiskSlaInMinutes: 180}, QueueOptions{SLA: "5h"}, orchestrator.QueueDefinition{Description: "Invoices", SlaInMinutes: 300, RiskSlaInMinutes: 180}, ""},
+		{"SLA and risk SLA removed", orchestrator.QueueDefinition{SlaInMinutes: 240, RiskSlaInMinutes: 180}, QueueOptions{SLA: "0", RiskSLA: "0"}, orchestrator.QueueDefinition{}, ""},
+		{"risk SLA without an SLA", orchestrator.QueueDefinition{}, QueueOptions{RiskSLA: "1h"}, orchestrator.QueueDefinition{}, "The risk SLA must be shorter than the SLA"},
+		{"risk SLA as long as the SLA", orchestrator.QueueDefinition{}, QueueOptions{SLA: "2h", RiskSLA: "120m"}, orchestrator.QueueDefinition{}, "The risk SLA must be shorter than the SLA"},
+		{"SLA shortened below the current risk SLA", orchestrator.QueueDefinition{SlaInMinutes: 240, RiskSlaInMinutes: 180}, QueueOptions{SLA: "2h"}, orchestrator.QueueDefinition{}, "The risk SLA must be shorter than the SLA"},
+		{"SLA removed with a risk SLA", orchestrator.QueueDefinition{SlaInMinutes: 240, RiskSlaInMinutes: 180}, QueueOptions{SLA: "0"}, orchestrator.QueueDefinition{}, "The risk SLA must be shorter than the SLA"},
+		{"negative retries", orchestrator.QueueDefinition{}, QueueOptions{MaxRetries: &negative}, orchestrator.QueueDefinition{}, "--max-retries cannot be negative"},
+		{"invalid risk SLA", orchestrator.QueueDefinition{}, QueueOptions{RiskSLA: "3x"}, orchestrator.QueueDefinition{}, "--risk-sla must be a duration of at least a minute, e.g. 90m, 4h or 2d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := test.current
+			err := test.opts.apply(&queue)
+			if (err == nil) != (test.err == "") || (err != nil && err.Error() != test.err) {
+				t.Fatalf("err = %v, want %q", err, test.err)
+			}
+			if err == nil && queue != test.want {
+				t.Errorf("queue = %+v, want %+v", queue, test.want)
+			}
+		})
+	}
+}
+
+func TestReadQueueSchema(t *testing.T) {
+
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(schema, []byte("{\n  \"type\": \"object\",\n  \"required\": [\"Amount\"]\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(invalid, []byte(`["Amount"]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		schema string
+		err    string
+	}{
+		{"compacted", schema, `{"type":"object","required":["Amount"]}`, ""},
+		{"removed", "none", "", ""},
+		{"not an object", invalid, "", "--specific-schema " + invalid + " is not a JSON schema object: "},
+		{"missing file", filepath.Join(dir, "missing.json"), "", "open "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readQueueSchema("--specific-schema", test.path)
+			if got != test.schema {
+				t.Errorf("readQueueSchema = %q, want %q", got, test.schema)
+			}
+			if (err == nil) != (test.err == "") || (err != nil && !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
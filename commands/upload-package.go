@@ -1,41 +1,67 @@
 package commands
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 
-	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/nupkg"
+	"github.com/bcsimms/uipo/orchestrator"
+	"github.com/bcsimms/uipo/output"
 	"github.com/bcsimms/uipo/util"
 )
 
-const upEndPointURI string = "/odata/Processes/UiPath.Server.Configuration.OData.UploadPackage"
-
 // CmdUploadPackage represents the flags this command support
 type CmdUploadPackage struct {
-	APIEndpoint        string `short:"e" long:"api-endpoint" description:"API endpoint (e.g. https://api.example.com)"`
-	AccountLogicalName string `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
-	ServiceLogicalName string `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
-	PackagePath        string `short:"p" long:"package" description:"Full path to NuGet package file"`
+	APIEndpoint        string   `short:"e" long:"api-endpoint" description:"API endpoint (e.g. https://api.example.com)"`
+	AccountLogicalName string   `short:"a" long:"alname" description:"Account Logical Name - Used for UiPath Platform Installations"`
+	ServiceLogicalName string   `short:"s" long:"slname" description:"Service Logical Name - Used for UiPath Platform Installations"`
+	PackagePaths       []string `short:"p" long:"package" description:"NuGet package file, directory of packages or glob pattern.  Repeat for several, or pass them as arguments"`
+	Parallel           int      `short:"j" long:"parallel" default:"4" description:"Number of packages to upload at the same time"`
+	Deploy             bool     `long:"deploy" description:"Update the processes that run this package to the uploaded version"`
+	DeployFolders      []string `long:"deploy-folder" description:"Folder (fully qualified name or ID) to deploy to.  Repeat for several folders.  Defaults to the current folder"`
+	AllFolders         bool     `long:"all-folders" description:"Deploy to every folder you have access to"`
+	NoProgress         bool     `long:"no-progress" description:"Do not show the upload progress bar"`
+	SkipValidation     bool     `long:"skip-validation" description:"Upload without checking the packages first"`
 
 	Config Config
 }
 
-type uploadRespWrapper struct {
-	ODataContext string       `json:"@odata.context"`
-	UploadResp   []uploadResp `json:"value"`
+// Results of uploading a package
+const (
+	uploadUploaded string = "Uploaded"
+	uploadSkipped  string = "Skipped"
+	uploadFailed   string = "Failed"
+)
+
+// packageUpload is the result reported for an uploaded package
+type packageUpload struct {
+	PackageFile string       `json:"PackageFile"`
+	FileSize    int64        `json:"FileSize"`
+	Status      string       `json:"Status"`
+	Result      string       `json:"Result"`
+	Error       string       `json:"Error,omitempty"`
+	Deployments []deployment `json:"Deployments,omitempty"`
+
+	path   string
+	result *orchestrator.UploadResult
 }
 
-type uploadResp struct {
-	Key    string `json:"Key"`
-	Status string `json:"Status"`
-	Body   string `json:"Body"`
+var uploadColumns = []output.Column{
+	{Header: "PACKAGE FILE", Field: "PackageFile"},
+	{Header: "FILE SIZE", Field: "FileSize"},
+	{Header: "RESULT", Field: "Result"},
+	{Header: "ERROR", Field: "Error"},
+	{Header: "STATUS", Field: "Status", Wide: true},
 }
 
 // Setup is the standard setup function
@@ -71,84 +97,415 @@ func (cmd *CmdUploadPackage) Setup(conf Config) error {
 func (cmd *CmdUploadPackage) Execute(args []string) error {
 
 	// Validate incoming flags
-	err := cmd.validateFlags()
+	err := cmd.validateFlags(args)
 
 	if err != nil {
 		return err
 	}
 
-	// Open our package file
-	file, err := os.Open(cmd.PackagePath)
+	packagePaths, err := expandPackagePaths(append(cmd.PackagePaths, args...))
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// Setup our multipart writer and create the request body using the file supplied
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("file", filepath.Base(cmd.PackagePath))
+	client, err := orchestrator.NewClient(cmd.Config)
 	if err != nil {
 		return err
 	}
-	fileSize, err := io.Copy(part, file)
-	err = writer.Close()
+
+	// Packages that fail validation are reported as failed and not uploaded
+	rejected := map[string]string{}
+	if !cmd.SkipValidation {
+		rejected = validatePackages(client, packagePaths, false)
+	}
+
+	// Resolve the folders first so that a mistyped folder fails before the upload
+	var deployFolders []orchestrator.Folder
+	if cmd.Deploy {
+		deployFolders, err = resolveDeployFolders(cmd.Config, client, cmd.DeployFolders, cmd.AllFolders)
+		if err != nil {
+			return err
+		}
+	}
+
+	uploads, cancelled := runUploads(packagePaths, rejected, cmd.Parallel, !cmd.NoProgress, client.UploadPackage)
+
+	renderer, err := newRenderer(cmd.Config)
 	if err != nil {
 		return err
 	}
 
-	var endpoint string
+	if renderer.IsHuman() {
+		err = renderer.Render(uploadColumns, uploads)
+		if err != nil {
+			return err
+		}
+	}
 
-	if cmd.Config.GetEndpointType() == config.EndpointTypeHosted {
-		endpoint = cmd.APIEndpoint + "/" + cmd.Config.GetAccountLogicalName() + "/" + cmd.Config.GetServiceLogicalName() + upEndPointURI
-	} else if cmd.Config.GetEndpointType() == config.EndpointTypeOnPremise {
-		endpoint = cmd.APIEndpoint + upEndPointURI
-	} else {
-		return errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
+	// Versions that already existed are deployed too, since the feed holds them
+	var deployments []deployment
+	if cmd.Deploy && !cancelled {
+		for i, upload := range uploads {
+			if upload.Result == uploadFailed {
+				continue
+			}
+			uploads[i].Deployments, err = deployUpload(client, upload, deployFolders)
+			if err != nil {
+				uploads[i].Result = uploadFailed
+				uploads[i].Error = err.Error()
+				continue
+			}
+			deployments = append(deployments, uploads[i].Deployments...)
+		}
+		if renderer.IsHuman() {
+			fmt.Println("")
+			if len(deployments) == 0 {
+				fmt.Println("No processes use the uploaded packages")
+			} else {
+				err = renderer.Render(deploymentColumns, deployments)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
-	client := http.Client{}
-	req, err := http.NewRequest("POST", endpoint, body)
-	if err != nil {
-		return err
+	if !renderer.IsHuman() {
+		err = renderer.Render(uploadColumns, uploads)
+		if err != nil {
+			return err
+		}
+	}
+
+	counts := uploadCounts(uploads)
+	failedDeployments := 0
+	for _, deployment := range deployments {
+		if deployment.Result == deployFailed {
+			failedDeployments++
+		}
+	}
+
+	if renderer.IsHuman() {
+		fmt.Println("")
+		fmt.Println(uploadSummary(counts))
+	}
+
+	if counts[uploadFailed] > 0 || failedDeployments > 0 {
+		message := strconv.Itoa(counts[uploadFailed]) + " package(s) failed"
+		if cancelled {
+			message = "Upload cancelled.  " + message
+		}
+		if failedDeployments > 0 {
+			message = message + " and " + strconv.Itoa(failedDeployments) + " process update(s) failed"
+		}
+		return &ExitError{Code: ExitCodeError, Message: message}
+	}
+	if cancelled {
+		return &ExitError{Code: ExitCodeError, Message: "Upload cancelled"}
+	}
+
+	return nil
+
+}
+
+// expandPackagePaths turns the files, directories and glob patterns given to push
+//  into a list of package files.  Directories contribute the .nupkg files they contain
+func expandPackagePaths(patterns []string) ([]string, error) {
+
+	var paths []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, errors.New("No package files match '" + pattern + "'")
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			files := []string{match}
+			if info.IsDir() {
+				files, err = filepath.Glob(filepath.Join(match, "*.nupkg"))
+				if err != nil {
+					return nil, err
+				}
+				if len(files) == 0 {
+					return nil, errors.New("No .nupkg files were found in " + match)
+				}
+			}
+
+			for _, file := range files {
+				if !seen[file] {
+					seen[file] = true
+					paths = append(paths, file)
+				}
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// validatePackages checks each package before it is uploaded, writing any
+//  problems to stderr.  Dependencies missing from the library feed, and packages
+//  that look meant for the other feed, are warnings only.  Returns the reason each
+//  rejected package will not be uploaded, by path
+func validatePackages(client *orchestrator.Client, paths []string, libraryFeed bool) map[string]string {
+
+	rejected := map[string]string{}
+	feedVersions := map[string][]string{}
+	checkFeed := true
+	for _, path := range paths {
+
+		pkg, err := nupkg.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+			rejected[path] = err.Error()
+			continue
+		}
+
+		problems := pkg.Validate()
+		isLibrary := strings.EqualFold(pkg.OutputType, "Library")
+		if isLibrary != libraryFeed && pkg.OutputType != "" {
+			if isLibrary {
+				problems = append(problems, nupkg.Problem{Severity: nupkg.SeverityWarning, Message: "The package is a library.  Upload it with the libraries push command"})
+			} else {
+				problems = append(problems, nupkg.Problem{Severity: nupkg.SeverityWarning, Message: "The package is a " + strings.ToLower(pkg.OutputType) + ", not a library"})
+			}
+		}
+		if checkFeed {
+			feedProblems, err := feedDependencyProblems(client, pkg, feedVersions)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: Unable to check dependencies against the library feed: "+err.Error())
+				checkFeed = false
+			}
+			problems = append(problems, feedProblems...)
+		}
+
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem.Severity+": "+pkg.File+": "+problem.Message)
+			if problem.Severity == nupkg.SeverityError && rejected[path] == "" {
+				rejected[path] = "Invalid package: " + problem.Message
+			}
+		}
+	}
+
+	return rejected
+}
+
+// packagesSize returns the combined size of the package files that will be
+//  uploaded, used as the total of the progress bar
+func packagesSize(paths []string, rejected map[string]string) int64 {
+
+	var total int64
+	for _, path := range paths {
+		if _, ok := rejected[path]; ok {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+
+	return total
+}
+
+// uploadFunc sends one package file to a feed, e.g. Client.UploadPackage
+type uploadFunc func(ctx context.Context, packagePath string, onProgress func(sent int64)) (*orchestrator.UploadResult, int64, error)
+
+// runUploads uploads the packages with a progress bar on stderr, when it is a
+//  terminal.  Ctrl-C cancels the uploads in flight and a second Ctrl-C ends uipo
+//  immediately.  Returns the results and whether the uploads were cancelled
+func runUploads(paths []string, rejected map[string]string, parallel int, showProgress bool, send uploadFunc) ([]packageUpload, bool) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			signal.Stop(interrupt)
+			fmt.Fprintln(os.Stderr, "\nCancelling uploads")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var progress *util.Progress
+	if showProgress && util.IsTerminal(os.Stderr) {
+		label := "Uploading " + strconv.Itoa(len(paths)-len(rejected)) + " package(s)"
+		progress = util.NewProgress(os.Stderr, label, packagesSize(paths, rejected))
+		progress.Start()
+	}
+
+	uploads := uploadPackages(ctx, paths, rejected, parallel, progress, send)
+
+	if progress != nil {
+		progress.Stop()
+	}
+
+	return uploads, ctx.Err() != nil
+}
+
+// uploadCounts returns the number of uploads with each result
+func uploadCounts(uploads []packageUpload) map[string]int {
+
+	counts := map[string]int{}
+	for _, upload := range uploads {
+		counts[upload.Result]++
+	}
+
+	return counts
+}
+
+// uploadSummary describes the upload counts in a single line
+func uploadSummary(counts map[string]int) string {
+	return strconv.Itoa(counts[uploadUploaded]) + " uploaded, " + strconv.Itoa(counts[uploadSkipped]) + " skipped, " + strconv.Itoa(counts[uploadFailed]) + " failed"
+}
+
+// uploadPackages uploads the packages using a pool of parallel workers.  The
+//  results are returned in the same order as the paths.  Rejected packages, and
+//  packages not yet started when ctx is cancelled, are reported as failed
+func uploadPackages(ctx context.Context, paths []string, rejected map[string]string, parallel int, progress *util.Progress, send uploadFunc) []packageUpload {
+
+	uploads := make([]packageUpload, len(paths))
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < parallel && worker < len(paths); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if reason, ok := rejected[paths[i]]; ok {
+					uploads[i] = packageUpload{PackageFile: filepath.Base(paths[i]), Result: uploadFailed, Error: reason, path: paths[i]}
+					continue
+				}
+				uploads[i] = uploadPackage(ctx, paths[i], progress, send)
+			}
+		}()
 	}
 
-	// Add our required request headers
-	req.Header.Add("X-UIPATH-TenantName", cmd.Config.GetServiceLogicalName())
-	req.Header.Add("Authorization", "Bearer "+cmd.Config.GetAccessToken())
-	req.Header.Add("Content-Type", writer.FormDataContentType())
+	for i := range paths {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return uploads
+}
 
-	// Use the HTTPHelper to make our API call
-	respBody, err := util.HTTPHelper(client, req)
+// uploadPackage uploads a single package.  A version that already exists in the
+//  feed is reported as skipped rather than failed
+func uploadPackage(ctx context.Context, path string, progress *util.Progress, send uploadFunc) packageUpload {
+
+	upload := packageUpload{
+		PackageFile: filepath.Base(path),
+		path:        path,
+	}
+
+	if ctx.Err() != nil {
+		upload.Result = uploadFailed
+		upload.Error = "Cancelled"
+		return upload
+	}
+
+	// The progress bar counts the bytes of every package.  A retried request starts
+	//  the file again, so the bytes it had sent are taken back
+	var reported int64
+	onProgress := func(sent int64) {
+		if progress != nil {
+			progress.Add(sent - reported)
+		}
+		reported = sent
+	}
+
+	result, fileSize, err := send(ctx, path, onProgress)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		upload.Result = uploadFailed
+		upload.Error = "Cancelled"
+	case orchestrator.IsStatus(err, http.StatusConflict):
+		upload.Result = uploadSkipped
+		upload.Error = "Version already exists"
+	case err != nil:
+		upload.Result = uploadFailed
+		upload.Error = err.Error()
+	default:
+		upload.PackageFile = result.Key
+		upload.FileSize = fileSize
+		upload.Status = result.Status
+		upload.Result = uploadUploaded
+		upload.result = result
+	}
+	util.LogInfo(upload.PackageFile + ": " + upload.Result)
+
+	return upload
+}
+
+// deployUpload updates the processes running an uploaded package in the selected folders
+func deployUpload(client *orchestrator.Client, upload packageUpload, folders []orchestrator.Folder) ([]deployment, error) {
+
+	packageID, version, err := uploadIdentity(upload.result, upload.path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	apiResp := uploadRespWrapper{}
-	jsonErr := json.Unmarshal(respBody, &apiResp)
-	if jsonErr != nil {
-		return jsonErr
+	deployments := deployPackage(client, packageID, version, folders)
+	if len(deployments) == 0 {
+		util.LogInfo("No processes use package " + packageID)
 	}
 
-	fmt.Println("Package uploaded successfully")
-	fmt.Println("")
-	fmt.Println("  Package File: ", apiResp.UploadResp[0].Key)
-	fmt.Println("     File Size: ", fileSize)
-	fmt.Println("        Status: ", apiResp.UploadResp[0].Status)
-	fmt.Println("")
+	return deployments, nil
+}
 
-	return nil
+// uploadIdentity returns the ID and version of an uploaded package.  They are read
+// from the upload result when Orchestrator includes them, otherwise from the NuGet
+// file name (<id>.<version>.nupkg)
+func uploadIdentity(result *orchestrator.UploadResult, packagePath string) (string, string, error) {
+
+	var body struct {
+		ID      string `json:"Id"`
+		Version string `json:"Version"`
+	}
+	if result != nil && json.Unmarshal([]byte(result.Body), &body) == nil && body.ID != "" && body.Version != "" {
+		return body.ID, body.Version, nil
+	}
 
+	packageID, version, ok := nupkg.IdentityFromFile(packagePath)
+	if !ok {
+		return "", "", errors.New("Unable to read the package ID and version from " + filepath.Base(packagePath))
+	}
+
+	return packageID, version, nil
 }
 
-func (cmd *CmdUploadPackage) validateFlags() error {
+func (cmd *CmdUploadPackage) validateFlags(args []string) error {
 
-	if cmd.PackagePath == "" {
+	if len(cmd.PackagePaths) == 0 && len(args) == 0 {
 		return errors.New("A package file is required")
 	}
+	if cmd.Parallel < 1 {
+		return errors.New("--parallel must be at least 1")
+	}
 	if cmd.APIEndpoint == "" {
 		return errors.New("An API end point is required and a value was not found in the cached config")
 	}
+	if !cmd.Deploy && (len(cmd.DeployFolders) > 0 || cmd.AllFolders) {
+		return errors.New("--deploy-folder and --all-folders are used with --deploy")
+	}
+	if len(cmd.DeployFolders) > 0 && cmd.AllFolders {
+		return errors.New("Use either --deploy-folder or --all-folders, not both")
+	}
 
 	return nil
 
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/orchestrator"
+)
+
+// uploadServer accepts package uploads to uri.  Versions 1.0.0 already exist in
+//  the feed and Broken packages are refused.  The files received and the most
+//  uploads in flight at once are recorded
+type uploadServer struct {
+	t           *testing.T
+	uri         string
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	files       []string
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" || r.URL.Path != s.uri {
+		s.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, header, err := r.FormFile("file")
+	if err != nil {
+		s.t.Errorf("reading the upload: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.files = append(s.files, header.Filename)
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+
+	switch {
+	case strings.Contains(header.Filename, ".1.0.0."):
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"message":"Package already exists.","errorCode":1004}`)
+	case strings.HasPrefix(header.Filename, "Broken"):
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message":"Invalid package.","errorCode":1670}`)
+	default:
+		key := strings.Replace(strings.TrimSuffix(header.Filename, ".nupkg"), ".", ":", 1)
+		fmt.Fprintf(w, `{"value":[{"Key":%q,"Status":"OK"}]}`, key)
+	}
+}
+
+// writePackages creates package files in dir and returns their paths
+func writePackages(t *testing.T, dir string, names ...string) []string {
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(paths[i], []byte("package "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return paths
+}
+
+func TestUploadIdentity(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		result  *orchestrator.UploadResult
+		path    string
+		id      string
+		version string
+		err     string
+	}{
+		{"from the upload result", &orchestrator.UploadResult{Body: `{"Id":"Invoices","Version":"1.0.2"}`}, "renamed.nupkg", "Invoices", "1.0.2", ""},
+		{"from the file name", &orchestrator.UploadResult{Body: `{"Key":"Invoices:1.0.2"}`}, "/builds/Acme.Invoices.1.0.2.nupkg", "Acme.Invoices", "1.0.2", ""},
+		{"without a result", nil, "Invoices.2.0.0-beta.nupkg", "Invoices", "2.0.0-beta", ""},
+		{"renamed file", nil, "/builds/latest.nupkg", "", "", "Unable to read the package ID and version from latest.nupkg"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, version, err := uploadIdentity(test.result, test.path)
+			if id != test.id || version != test.version {
+				t.Errorf("uploadIdentity = %q, %q, want %q, %q", id, version, test.id, test.version)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestUploadPackages(t *testing.T) {
+
+	server := &uploadServer{t: t, uri: orchestrator.ProcessUploadURI}
+	client := newTestClient(t, server.ServeHTTP)
+
+	paths := writePackages(t, t.TempDir(), "Invoices.1.0.0.nupkg", "Invoices.1.0.1.nupkg", "Billing.2.0.0.nupkg", "Broken.1.0.1.nupkg", "Common.3.0.0.nupkg", "Reports.1.2.0.nupkg")
+	rejected := map[string]string{paths[5]: "Missing the .nuspec manifest"}
+
+	uploads := uploadPackages(context.Background(), paths, rejected, 3, nil, client.UploadPackage)
+
+	want := []struct{ file, result, err string }{
+		{"Invoices.1.0.0.nupkg", uploadSkipped, "Version already exists"},
+		{"Invoices:1.0.1", uploadUploaded, ""},
+		{"Billing:2.0.0", uploadUploaded, ""},
+		{"Broken.1.0.1.nupkg", uploadFailed, "API Request Failed: 400 Bad Request - Invalid package. (Error Code: 1670)"},
+		{"Common:3.0.0", uploadUploaded, ""},
+		{"Reports.1.2.0.nupkg", uploadFailed, "Missing the .nuspec manifest"},
+	}
+	for i, upload := range uploads {
+		if upload.PackageFile != want[i].file || upload.Result != want[i].result || upload.Error != want[i].err {
+			t.Errorf("upload %d = %s %s %q, want %s %s %q", i, upload.PackageFile, upload.Result, upload.Error, want[i].file, want[i].result, want[i].err)
+		}
+	}
+
+	counts := uploadCounts(uploads)
+	if summary := uploadSummary(counts); summary != "3 uploaded, 1 skipped, 2 failed" {
+		t.Errorf("summary = %q", summary)
+	}
+	if len(server.files) != 5 || server.maxInFlight < 2 || server.maxInFlight > 3 {
+		t.Errorf("%d files were sent with up to %d at once, want 5 with 2 or 3 at once", len(server.files), server.maxInFlight)
+	}
+}
+
+func TestPushExitCode(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		packages []string
+		err      string
+	}{
+		{"existing versions are skipped", []string{"Invoices.1.0.0.nupkg", "Invoices.1.0.1.nupkg"}, ""},
+		{"failed uploads", []string{"Invoices.1.0.1.nupkg", "Broken.1.0.1.nupkg"}, "1 package(s) failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &uploadServer{t: t, uri: orchestrator.ProcessUploadURI}
+			conf := newTestConfig(t, server.ServeHTTP)
+			conf.GlobalFlgs.OutputFormat = "json"
+
+			dir := t.TempDir()
+			writePackages(t, dir, append(test.packages, "notes.txt")...)
+
+			cmd := CmdUploadPackage{PackagePaths: []string{dir}, Parallel: 2, NoProgress: true, SkipValidation: true, APIEndpoint: conf.ConfigFile.APIEndpoint, Config: conf}
+			err := cmd.Execute(nil)
+
+			var exitErr *ExitError
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("err = %v", err)
+			case test.err != "" && (!errors.As(err, &exitErr) || exitErr.Code != ExitCodeError || exitErr.Message != test.err):
+				t.Errorf("err = %v, want exit code 1 with %q", err, test.err)
+			}
+			if len(server.files) != len(test.packages) {
+				t.Errorf("sent %v, want the %d packages", server.files, len(test.packages))
+			}
+		})
+	}
+}
+
+func TestRunUploadsInterrupted(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent to a process on Windows")
+	}
+
+	// The server reads the start of the first package and then stops reading, so
+	//  the upload is still streaming when it is interrupted
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.ReadFull(r.Body, make([]byte, 32*1024))
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	dir := t.TempDir()
+	paths := writePackages(t, dir, "Next.1.0.0.nupkg")
+	large := filepath.Join(dir, "Large.1.0.0.nupkg")
+	if err := ioutil.WriteFile(large, make([]byte, 16<<20), 0600); err != nil {
+		t.Fatal(err)
+	}
+	paths = append([]string{large}, paths...)
+
+	go func() {
+		<-started
+		process, _ := os.FindProcess(os.Getpid())
+		process.Signal(os.Interrupt)
+	}()
+
+	done := make(chan struct{})
+	var uploads []packageUpload
+	var cancelled bool
+	go func() {
+		uploads, cancelled = runUploads(paths, nil, 1, false, client.UploadPackage)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the upload was not cancelled")
+	}
+
+	if !cancelled {
+		t.Error("runUploads did not report the cancellation")
+	}
+	for _, upload := range uploads {
+		if upload.Result != uploadFailed || upload.Error != "Cancelled" {
+			t.Errorf("%s = %s %q, want Failed and Cancelled", upload.PackageFile, upload.Result, upload.Error)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package config
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+
+	"github.com/bcsimms/uipo/util"
+)
 
 //Config is the main configuration object used throughout the UIPO CLI
 type Config struct {
@@ -14,26 +19,62 @@ type Config struct {
 	detectedSettings detectedSettings
 
 	GlobalFlgs globalFlgs
+
+	// profiles holds every named profile in the config file.  ConfigFile is the
+	//  working copy of the active profile and is synced back before writing
+	profiles map[string]JSONConfig
+
+	// currentProfile is the default profile saved in the config file
+	currentProfile string
+
+	// activeProfile is the profile ConfigFile was loaded from for this run
+	activeProfile string
+
+	// profileErr records a UIPO_PROFILE that does not exist (see ProfileError)
+	profileErr error
+
+	// secretStoreName selects where tokens and secrets are kept (see SecretStore)
+	secretStoreName string
+
+	// secretKeyFile is the key file used by the file secret store
+	secretKeyFile string
+
+	// secretStores caches the secret store backends opened during this run
+	secretStores map[string]SecretStore
+
+	// storedSecrets and storedSecretStore record what is already in the secret
+	//  store so that only changes are written back
+	storedSecrets     map[string]string
+	storedSecretStore string
+
+	// secretsUnlocked is set once the secrets have been read (see UnlockSecrets)
+	secretsUnlocked bool
 }
 
 // JSONConfig is the representation of the contents of our configuration file
 // It stores temporary authentication details in addition to other info that might persist
 // between API calls
 type JSONConfig struct {
-	ConfigVersion         int    `json:"ConfigVersion"`
-	AccessToken           string `json:"AccessToken"`
-	APIVersion            string `json:"APIVersion"`
-	AsyncTimeout          int    `json:"AsyncTimeout"`
-	AuthorizationEndpoint string `json:"AuthorizationEndpoint"`
-	APIEndpoint           string `json:"APIEndpoint"`
-	EndpointType          string `json:"EndpointType"`
-	TargetedTenant        Tenant `json:"TenantFields"`
-	TargetedFolder        Folder `json:"FolderFields"`
-	RefreshToken          string `json:"RefreshToken"`
-	Trace                 string `json:"Trace"`
-	AccountLogicalName    string `json:"AccountLogicalName"`
-	ServiceLogicalName    string `json:"ServiceLogicalName"`
-	ClientID              string `json:"ClientID"`
+	ConfigVersion         int       `json:"ConfigVersion,omitempty"`
+	AccessToken           string    `json:"AccessToken"`
+	AccessTokenExpiry     time.Time `json:"AccessTokenExpiry"`
+	APIVersion            string    `json:"APIVersion"`
+	AsyncTimeout          int       `json:"AsyncTimeout"`
+	AuthorizationEndpoint string    `json:"AuthorizationEndpoint"`
+	APIEndpoint           string    `json:"APIEndpoint"`
+	EndpointType          string    `json:"EndpointType"`
+	TargetedTenant        Tenant    `json:"TenantFields"`
+	TargetedFolder        Folder    `json:"FolderFields"`
+	RefreshToken          string    `json:"RefreshToken"`
+	Trace                 string    `json:"Trace"`
+	AccountLogicalName    string    `json:"AccountLogicalName"`
+	ServiceLogicalName    string    `json:"ServiceLogicalName"`
+	ClientID              string    `json:"ClientID"`
+	AuthType              string    `json:"AuthType"`
+	IdentityEndpoint      string    `json:"IdentityEndpoint"`
+	AppID                 string    `json:"AppID"`
+	AppSecret             string    `json:"AppSecret"`
+	Scopes                string    `json:"Scopes"`
 }
 
 // Tenant is the representation of a Tenant object in UiPath Orchestrator
@@ -47,7 +88,7 @@ type Tenant struct {
 // Folder is the representation of a Folder in UiPath Orchestrator
 type Folder struct {
 	DisplayName        string `json:"DisplayName"`
-	FullyQualifiedName string `json:"FullyQualifiedName":`
+	FullyQualifiedName string `json:"FullyQualifiedName"`
 	Description        string `json:"Description"`
 	ParentID           int    `json:"ParentId"`
 	ID                 int    `json:"Id"`
@@ -56,23 +97,37 @@ type Folder struct {
 //EnvOverride is the represetnation of environment variables that might override
 //  other config
 type EnvOverride struct {
-	BinaryName   string
-	UIPOHome     string
-	UIPOPassword string
-	UIPOUsername string
-	HTTPSProxy   string
+	BinaryName    string
+	UIPOHome      string
+	UIPOPassword  string
+	UIPOUsername  string
+	UIPOAppSecret string
+	UIPOProfile   string
+	HTTPSProxy    string
+
+	UIPOSecretPassphrase string
+	UIPOSecretKeyFile    string
 }
 
 const EndpointTypeHosted string = "Hosted"
 const EndpointTypeOnPremise string = "OnPremise"
 
+// AuthTypeClientCredentials marks a config authenticated as an external application
+//  When AuthType is empty the authentication flow is determined by the EndpointType
+const AuthTypeClientCredentials string = "ClientCredentials"
+
+// AuthTypeAuthorizationCode marks a config authenticated through the interactive browser login
+const AuthTypeAuthorizationCode string = "AuthorizationCode"
+
 type detectedSettings struct {
 	currentDirectory string
 }
 
 type globalFlgs struct {
-	Unsafe  bool
-	Verbose bool
+	Unsafe       bool
+	Verbose      bool
+	OutputFormat string
+	OutputQuery  string
 }
 
 func (config *Config) GetConfigVersion() string {
@@ -92,9 +147,20 @@ func (config *Config) GetAPIEndpoint() string {
 
 // SetAccessToken sets the current access token.
 func (config *Config) SetAccessToken(accessToken string) {
+	util.AddSecret(accessToken)
 	config.ConfigFile.AccessToken = accessToken
 }
 
+// GetAccessTokenExpiry returns when the cached access token expires.  Zero if unknown
+func (config *Config) GetAccessTokenExpiry() time.Time {
+	return config.ConfigFile.AccessTokenExpiry
+}
+
+// SetAccessTokenExpiry sets when the cached access token expires
+func (config *Config) SetAccessTokenExpiry(expiry time.Time) {
+	config.ConfigFile.AccessTokenExpiry = expiry
+}
+
 func (config *Config) GetBinaryVersion() string {
 	panic("not implemented")
 }
@@ -104,6 +170,7 @@ func (config *Config) GetRefreshToken() string {
 }
 
 func (config *Config) SetRefreshToken(token string) {
+	util.AddSecret(token)
 	config.ConfigFile.RefreshToken = token
 }
 
@@ -173,6 +240,75 @@ func (config *Config) SetClientID(id string) {
 	config.ConfigFile.ClientID = id
 }
 
+// GetTenantName returns the tenant used for on-premise authentication
+func (config *Config) GetTenantName() string {
+	return config.ConfigFile.TargetedTenant.Name
+}
+
+// SetTenantName sets the tenant used for on-premise authentication
+func (config *Config) SetTenantName(name string) {
+	config.ConfigFile.TargetedTenant.Name = name
+}
+
+func (config *Config) GetAuthType() string {
+	return config.ConfigFile.AuthType
+}
+
+func (config *Config) SetAuthType(authType string) {
+	config.ConfigFile.AuthType = authType
+}
+
+// GetIdentityEndpoint returns the base URL of the identity server used for OAuth flows
+func (config *Config) GetIdentityEndpoint() string {
+	return config.ConfigFile.IdentityEndpoint
+}
+
+func (config *Config) SetIdentityEndpoint(e string) {
+	config.ConfigFile.IdentityEndpoint = e
+}
+
+// GetAppID returns the external application ID used for client credentials authentication
+func (config *Config) GetAppID() string {
+	return config.ConfigFile.AppID
+}
+
+func (config *Config) SetAppID(id string) {
+	config.ConfigFile.AppID = id
+}
+
+// GetAppSecret returns the external application secret.  The "UIPO_APP_SECRET"
+//  environment variable takes precedence over the cached value
+func (config *Config) GetAppSecret() string {
+	if config.ENV.UIPOAppSecret != "" {
+		return config.ENV.UIPOAppSecret
+	}
+	return config.ConfigFile.AppSecret
+}
+
+func (config *Config) SetAppSecret(secret string) {
+	util.AddSecret(secret)
+	config.ConfigFile.AppSecret = secret
+}
+
+// GetScopes returns the space separated scopes requested for client credentials authentication
+func (config *Config) GetScopes() string {
+	return config.ConfigFile.Scopes
+}
+
+func (config *Config) SetScopes(scopes string) {
+	config.ConfigFile.Scopes = scopes
+}
+
+// GetAsyncTimeout returns how many seconds to wait for long running operations such
+//  as jobs to finish.  0 means it has not been set
+func (config *Config) GetAsyncTimeout() int {
+	return config.ConfigFile.AsyncTimeout
+}
+
+func (config *Config) SetAsyncTimeout(seconds int) {
+	config.ConfigFile.AsyncTimeout = seconds
+}
+
 func (config *Config) GetFolderID() int {
 	return config.ConfigFile.TargetedFolder.ID
 }
@@ -220,3 +356,13 @@ func (config *Config) IsUnsafeMode() bool {
 func (config *Config) IsVerboseMode() bool {
 	return config.GlobalFlgs.Verbose
 }
+
+// GetOutputFormat returns the format selected with --output
+func (config *Config) GetOutputFormat() string {
+	return config.GlobalFlgs.OutputFormat
+}
+
+// GetOutputQuery returns the field selector given with --query
+func (config *Config) GetOutputQuery() string {
+	return config.GlobalFlgs.OutputQuery
+}
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -31,10 +31,8 @@ func LoadConfig() (*Config, error) {
 	configFilePath := ConfigFilePath()
 
 	config := Config{
-		ConfigFile: JSONConfig{
-			ConfigVersion: 1,
-			ClientID:      DefaultClientID,
-		},
+		profiles:       map[string]JSONConfig{DefaultProfileName: newProfile()},
+		currentProfile: DefaultProfileName,
 	}
 
 	var jsonError error
@@ -48,22 +46,58 @@ func LoadConfig() (*Config, error) {
 
 		if len(file) != 0 {
 
-			var configFile JSONConfig
-			err = json.Unmarshal(file, &configFile)
+			// Older single connection files are migrated to the profile layout here
+			//  and written back in the new layout by WriteConfig
+			configFile, err := parseConfigFile(file)
 			if err != nil {
 				return nil, err
 			}
-			config.ConfigFile = configFile
+			if _, ok := configFile.Profiles[configFile.CurrentProfile]; !ok {
+				configFile.CurrentProfile = DefaultProfileName
+				if _, ok := configFile.Profiles[DefaultProfileName]; !ok {
+					configFile.Profiles[DefaultProfileName] = newProfile()
+				}
+			}
+			config.profiles = configFile.Profiles
+			config.currentProfile = configFile.CurrentProfile
+			config.secretStoreName = configFile.SecretStore
+			config.secretKeyFile = configFile.SecretKeyFile
 		}
 	}
 
 	config.ENV = EnvOverride{
-		BinaryName:   filepath.Base(os.Args[0]),
-		UIPOPassword: os.Getenv("UIPO_PASSWORD"),
-		UIPOUsername: os.Getenv("UIPO_USERNAME"),
-		UIPOHome:     os.Getenv("UIPO_HOME"),
-		HTTPSProxy:   os.Getenv("https_proxy"),
+		BinaryName:    filepath.Base(os.Args[0]),
+		UIPOPassword:  os.Getenv("UIPO_PASSWORD"),
+		UIPOUsername:  os.Getenv("UIPO_USERNAME"),
+		UIPOAppSecret: os.Getenv("UIPO_APP_SECRET"),
+		UIPOProfile:   os.Getenv("UIPO_PROFILE"),
+		UIPOHome:      os.Getenv("UIPO_HOME"),
+		HTTPSProxy:    os.Getenv("https_proxy"),
+
+		UIPOSecretPassphrase: os.Getenv("UIPO_SECRET_PASSPHRASE"),
+		UIPOSecretKeyFile:    os.Getenv("UIPO_SECRET_KEYFILE"),
+	}
+
+	err = config.loadSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	// UIPO_PROFILE selects the profile for this run without changing the saved default
+	//  The --profile flag is applied afterwards with SelectProfile.  An unknown profile
+	//  is reported by ProfileError rather than failing here, so that the profile
+	//  commands can still run (e.g. to create it)
+	profileName := config.currentProfile
+	if config.ENV.UIPOProfile != "" {
+		if _, ok := config.profiles[config.ENV.UIPOProfile]; ok {
+			profileName = config.ENV.UIPOProfile
+		} else {
+			config.profileErr = errors.New("Profile '" + config.ENV.UIPOProfile + "' set by UIPO_PROFILE does not exist")
+		}
 	}
+	config.activeProfile = profileName
+	config.ConfigFile = config.profiles[profileName]
+	config.ConfigFile.ConfigVersion = CurrentConfigVersion
 
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// CurrentConfigVersion is the config file layout written by this version of UIPO
+//  Version 1 held a single connection; version 2 holds named profiles
+const CurrentConfigVersion = 2
+
+// DefaultProfileName is the profile created when migrating a version 1 config file
+const DefaultProfileName = "default"
+
+// configFileV2 is the on-disk layout of the config file from version 2 onwards
+type configFileV2 struct {
+	ConfigVersion  int                   `json:"ConfigVersion"`
+	CurrentProfile string                `json:"CurrentProfile"`
+	SecretStore    string                `json:"SecretStore,omitempty"`
+	SecretKeyFile  string                `json:"SecretKeyFile,omitempty"`
+	Profiles       map[string]JSONConfig `json:"Profiles"`
+}
+
+// newProfile returns the settings used for a brand new profile
+func newProfile() JSONConfig {
+	return JSONConfig{
+		ClientID: DefaultClientID,
+	}
+}
+
+// parseConfigFile reads either config file layout.  Version 1 files are migrated
+//  into a version 2 layout holding a single "default" profile
+func parseConfigFile(file []byte) (configFileV2, error) {
+
+	var versionCheck struct {
+		ConfigVersion int             `json:"ConfigVersion"`
+		Profiles      json.RawMessage `json:"Profiles"`
+	}
+	err := json.Unmarshal(file, &versionCheck)
+	if err != nil {
+		return configFileV2{}, err
+	}
+
+	if versionCheck.ConfigVersion < CurrentConfigVersion || versionCheck.Profiles == nil {
+
+		var singleProfile JSONConfig
+		err = json.Unmarshal(file, &singleProfile)
+		if err != nil {
+			return configFileV2{}, err
+		}
+		singleProfile.ConfigVersion = 0
+
+		migrated := configFileV2{
+			ConfigVersion:  CurrentConfigVersion,
+			CurrentProfile: DefaultProfileName,
+			Profiles:       map[string]JSONConfig{DefaultProfileName: singleProfile},
+		}
+		return migrated, nil
+	}
+
+	fileV2 := configFileV2{}
+	err = json.Unmarshal(file, &fileV2)
+	if err != nil {
+		return configFileV2{}, err
+	}
+	if fileV2.Profiles == nil {
+		fileV2.Profiles = map[string]JSONConfig{}
+	}
+
+	return fileV2, nil
+}
+
+// syncActiveProfile copies the working settings back into the profile map
+func (config *Config) syncActiveProfile() {
+
+	// A Config built in code rather than by LoadConfig starts without any profiles
+	if config.profiles == nil {
+		config.profiles = map[string]JSONConfig{}
+	}
+	if config.activeProfile == "" {
+		config.activeProfile = DefaultProfileName
+	}
+	if config.currentProfile == "" {
+		config.currentProfile = config.activeProfile
+	}
+
+	activeProfile := config.ConfigFile
+	activeProfile.ConfigVersion = 0
+	config.profiles[config.activeProfile] = activeProfile
+}
+
+// SelectProfile switches the settings used for this run to the named profile
+//  The default profile saved in the config file is not changed
+func (config *Config) SelectProfile(name string) error {
+
+	if _, ok := config.profiles[name]; !ok {
+		return errors.New("Profile '" + name + "' does not exist.  Create it with the profile create command")
+	}
+
+	config.syncActiveProfile()
+	config.activeProfile = name
+	config.ConfigFile = config.profiles[name]
+	config.ConfigFile.ConfigVersion = CurrentConfigVersion
+	config.profileErr = nil
+
+	return nil
+}
+
+// ProfileError returns the error for a UIPO_PROFILE that does not exist.  The
+//  default profile is used in its place until a profile is selected
+func (config *Config) ProfileError() error {
+	return config.profileErr
+}
+
+// GetProfileName returns the name of the profile used for this run
+func (config *Config) GetProfileName() string {
+	return config.activeProfile
+}
+
+// GetDefaultProfile returns the profile used when neither --profile nor UIPO_PROFILE is set
+func (config *Config) GetDefaultProfile() string {
+	return config.currentProfile
+}
+
+// ProfileNames returns the names of all profiles in alphabetical order
+func (config *Config) ProfileNames() []string {
+
+	names := make([]string, 0, len(config.profiles))
+	for name := range config.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// UseProfile makes the named profile the default for future runs
+func (config *Config) UseProfile(name string) error {
+
+	if _, ok := config.profiles[name]; !ok {
+		return errors.New("Profile '" + name + "' does not exist")
+	}
+	config.currentProfile = name
+
+	return nil
+}
+
+// CreateProfile adds a new profile.  When copyFrom is provided the endpoint and
+//  folder settings of that profile are copied, but not its tokens or secrets
+func (config *Config) CreateProfile(name string, copyFrom string) error {
+
+	if name == "" {
+		return errors.New("A profile name is required")
+	}
+	if _, ok := config.profiles[name]; ok {
+		return errors.New("Profile '" + name + "' already exists")
+	}
+
+	profile := newProfile()
+	if copyFrom != "" {
+		config.syncActiveProfile()
+		source, ok := config.profiles[copyFrom]
+		if !ok {
+			return errors.New("Profile '" + copyFrom + "' does not exist")
+		}
+		profile = source
+		profile.AccessToken = ""
+		profile.AccessTokenExpiry = time.Time{}
+		profile.RefreshToken = ""
+		profile.AppSecret = ""
+	}
+	config.profiles[name] = profile
+
+	return nil
+}
+
+// DeleteProfile removes a profile.  The default profile and the profile in use
+//  for this run cannot be deleted
+func (config *Config) DeleteProfile(name string) error {
+
+	if _, ok := config.profiles[name]; !ok {
+		return errors.New("Profile '" + name + "' does not exist")
+	}
+	if name == config.currentProfile {
+		return errors.New("Profile '" + name + "' is the default profile.  Use another profile first")
+	}
+	if name == config.activeProfile {
+		return errors.New("Profile '" + name + "' is in use by this command.  Select another profile with --profile")
+	}
+
+	// Its secrets are removed from the secret store when the config is written
+	if err := config.UnlockSecrets(); err != nil {
+		return err
+	}
+	delete(config.profiles, name)
+
+	return nil
+}
+
+// RenameProfile renames a profile, keeping it as the default and active profile if it was either
+func (config *Config) RenameProfile(oldName string, newName string) error {
+
+	if newName == "" {
+		return errors.New("A new profile name is required")
+	}
+	if _, ok := config.profiles[oldName]; !ok {
+		return errors.New("Profile '" + oldName + "' does not exist")
+	}
+	if _, ok := config.profiles[newName]; ok {
+		return errors.New("Profile '" + newName + "' already exists")
+	}
+
+	// Its secrets are moved in the secret store when the config is written
+	if err := config.UnlockSecrets(); err != nil {
+		return err
+	}
+	config.syncActiveProfile()
+	config.profiles[newName] = config.profiles[oldName]
+	delete(config.profiles, oldName)
+
+	if config.currentProfile == oldName {
+		config.currentProfile = newName
+	}
+	if config.activeProfile == oldName {
+		config.activeProfile = newName
+	}
+
+	return nil
+}
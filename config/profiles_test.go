@@ -0,0 +1,425 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useConfigHome points UIPO_HOME at an empty directory, writing rawConfig as its
+//  config file when one is given
+func useConfigHome(t *testing.T, rawConfig []byte) {
+
+	home := t.TempDir()
+	t.Setenv("UIPO_HOME", home)
+	t.Setenv("UIPO_PROFILE", "")
+	t.Setenv("UIPO_APP_SECRET", "")
+	t.Setenv("UIPO_PASSWORD", "")
+
+	if rawConfig == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".uipo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ConfigFilePath(), rawConfig, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigMigratesV1(t *testing.T) {
+
+	rawConfig, err := ioutil.ReadFile(filepath.Join("testdata", "config-v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfigHome(t, rawConfig)
+
+	want := JSONConfig{
+		ConfigVersion:         CurrentConfigVersion,
+		AccessToken:           "v1-access",
+		AccessTokenExpiry:     time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+		APIVersion:            "12.0",
+		AsyncTimeout:          600,
+		AuthorizationEndpoint: "https://account.uipath.com/oauth/token",
+		APIEndpoint:           "https://cloud.uipath.com/",
+		EndpointType:          EndpointTypeHosted,
+		TargetedTenant:        Tenant{Name: "DefaultTenant"},
+		TargetedFolder:        Folder{DisplayName: "Finance", FullyQualifiedName: "Shared/Finance", ParentID: 1, ID: 7},
+		RefreshToken:          "v1-refresh",
+		AccountLogicalName:    "acme",
+		ServiceLogicalName:    "DefaultTenant",
+		ClientID:              DefaultClientID,
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.ConfigFile, want) {
+		t.Errorf("migrated settings = %+v, want %+v", config.ConfigFile, want)
+	}
+	if !reflect.DeepEqual(config.ProfileNames(), []string{DefaultProfileName}) || config.GetProfileName() != DefaultProfileName || config.GetDefaultProfile() != DefaultProfileName {
+		t.Errorf("profiles = %v, active %q, default %q, want only %q", config.ProfileNames(), config.GetProfileName(), config.GetDefaultProfile(), DefaultProfileName)
+	}
+
+	// The migrated file is written back in the version 2 layout
+	if err = config.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+	rawConfig, err = ioutil.ReadFile(ConfigFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written configFileV2
+	if err = json.Unmarshal(rawConfig, &written); err != nil {
+		t.Fatal(err)
+	}
+	want.ConfigVersion = 0
+	if written.ConfigVersion != CurrentConfigVersion || written.CurrentProfile != DefaultProfileName || len(written.Profiles) != 1 || !reflect.DeepEqual(written.Profiles[DefaultProfileName], want) {
+		t.Errorf("written config = %s", rawConfig)
+	}
+
+	reloaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.ConfigVersion = CurrentConfigVersion
+	if !reflect.DeepEqual(reloaded.ConfigFile, want) {
+		t.Errorf("reloaded settings = %+v, want %+v", reloaded.ConfigFile, want)
+	}
+}
+
+func TestLoadConfigWithoutFile(t *testing.T) {
+
+	useConfigHome(t, nil)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.GetProfileName() != DefaultProfileName || config.ConfigFile.ClientID != DefaultClientID || config.ConfigFile.ConfigVersion != CurrentConfigVersion {
+		t.Errorf("profile %q = %+v, want a new %q profile", config.GetProfileName(), config.ConfigFile, DefaultProfileName)
+	}
+}
+
+func TestLoadConfigProfileSelection(t *testing.T) {
+
+	rawConfig := []byte(`{
+		"ConfigVersion": 2,
+		"CurrentProfile": "dev",
+		"Profiles": {
+			"dev": {"APIEndpoint": "https://dev.example.com"},
+			"prod": {"APIEndpoint": "https://prod.example.com"}
+		}
+	}`)
+
+	tests := []struct {
+		name     string
+		env      string
+		active   string
+		endpoint string
+		err      string
+	}{
+		{"saved default", "", "dev", "https://dev.example.com", ""},
+		{"UIPO_PROFILE", "prod", "prod", "https://prod.example.com", ""},
+		{"unknown UIPO_PROFILE", "missing", "dev", "https://dev.example.com", "Profile 'missing' set by UIPO_PROFILE does not exist"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			useConfigHome(t, rawConfig)
+			t.Setenv("UIPO_PROFILE", test.env)
+
+			config, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = config.ProfileError()
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("ProfileError = %v, want %q", err, test.err)
+			}
+			if config.GetProfileName() != test.active || config.GetDefaultProfile() != "dev" || config.ConfigFile.APIEndpoint != test.endpoint {
+				t.Errorf("active %q (%s), default %q, want %q (%s), dev", config.GetProfileName(), config.ConfigFile.APIEndpoint, config.GetDefaultProfile(), test.active, test.endpoint)
+			}
+
+			// Selecting a profile that exists, as --profile does, replaces the error
+			if err = config.SelectProfile("prod"); err != nil || config.ProfileError() != nil {
+				t.Errorf("SelectProfile = %v, ProfileError = %v", err, config.ProfileError())
+			}
+		})
+	}
+}
+
+// newProfilesConfig returns a config using its "dev" default profile, with an
+//  unsaved change to dev's endpoint, and a "prod" profile holding tokens
+func newProfilesConfig() *Config {
+
+	config := &Config{
+		profiles: map[string]JSONConfig{
+			"dev": {APIEndpoint: "https://dev.example.com"},
+			"prod": {
+				APIEndpoint:    "https://prod.example.com",
+				TargetedFolder: Folder{ID: 9},
+				AccessToken:    "access",
+				RefreshToken:   "refresh",
+				AppSecret:      "secret",
+			},
+		},
+		currentProfile: "dev",
+		activeProfile:  "dev",
+	}
+	config.ConfigFile = config.profiles["dev"]
+	config.ConfigFile.APIEndpoint = "https://dev2.example.com"
+
+	return config
+}
+
+func TestProfileOperations(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		run      func(config *Config) error
+		err      string
+		names    []string
+		def      string
+		active   string
+		endpoint string
+		// check inspects anything else the operation should have changed
+		check func(t *testing.T, config *Config)
+	}{
+		{
+			name:     "select",
+			run:      func(config *Config) error { return config.SelectProfile("prod") },
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "prod",
+			endpoint: "https://prod.example.com",
+			check: func(t *testing.T, config *Config) {
+				if config.profiles["dev"].APIEndpoint != "https://dev2.example.com" {
+					t.Errorf("unsaved change to dev was lost: %+v", config.profiles["dev"])
+				}
+				if config.ConfigFile.ConfigVersion != CurrentConfigVersion || config.GetAccessToken() != "access" {
+					t.Errorf("selected settings = %+v", config.ConfigFile)
+				}
+			},
+		},
+		{
+			name:     "select missing",
+			run:      func(config *Config) error { return config.SelectProfile("missing") },
+			err:      "Profile 'missing' does not exist.  Create it with the profile create command",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "use",
+			run:      func(config *Config) error { return config.UseProfile("prod") },
+			names:    []string{"dev", "prod"},
+			def:      "prod",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "use missing",
+			run:      func(config *Config) error { return config.UseProfile("missing") },
+			err:      "Profile 'missing' does not exist",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "create",
+			run:      func(config *Config) error { return config.CreateProfile("test", "") },
+			names:    []string{"dev", "prod", "test"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+			check: func(t *testing.T, config *Config) {
+				if !reflect.DeepEqual(config.profiles["test"], newProfile()) {
+					t.Errorf("test = %+v, want a new profile", config.profiles["test"])
+				}
+			},
+		},
+		{
+			name:     "create from a profile with tokens",
+			run:      func(config *Config) error { return config.CreateProfile("test", "prod") },
+			names:    []string{"dev", "prod", "test"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+			check: func(t *testing.T, config *Config) {
+				want := JSONConfig{APIEndpoint: "https://prod.example.com", TargetedFolder: Folder{ID: 9}}
+				if !reflect.DeepEqual(config.profiles["test"], want) {
+					t.Errorf("test = %+v, want prod's settings without its tokens", config.profiles["test"])
+				}
+				if config.profiles["prod"].AccessToken != "access" {
+					t.Errorf("prod lost its tokens: %+v", config.profiles["prod"])
+				}
+			},
+		},
+		{
+			name:     "create from the active profile",
+			run:      func(config *Config) error { return config.CreateProfile("test", "dev") },
+			names:    []string{"dev", "prod", "test"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+			check: func(t *testing.T, config *Config) {
+				if config.profiles["test"].APIEndpoint != "https://dev2.example.com" {
+					t.Errorf("test = %+v, want the unsaved dev endpoint", config.profiles["test"])
+				}
+			},
+		},
+		{
+			name:     "create existing",
+			run:      func(config *Config) error { return config.CreateProfile("prod", "") },
+			err:      "Profile 'prod' already exists",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "create without a name",
+			run:      func(config *Config) error { return config.CreateProfile("", "") },
+			err:      "A profile name is required",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "create from missing",
+			run:      func(config *Config) error { return config.CreateProfile("test", "missing") },
+			err:      "Profile 'missing' does not exist",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "delete",
+			run:      func(config *Config) error { return config.DeleteProfile("prod") },
+			names:    []string{"dev"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "delete the default",
+			run:      func(config *Config) error { return config.DeleteProfile("dev") },
+			err:      "Profile 'dev' is the default profile.  Use another profile first",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name: "delete the profile in use",
+			run: func(config *Config) error {
+				config.SelectProfile("prod")
+				return config.DeleteProfile("prod")
+			},
+			err:      "Profile 'prod' is in use by this command.  Select another profile with --profile",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "prod",
+			endpoint: "https://prod.example.com",
+		},
+		{
+			name:     "delete missing",
+			run:      func(config *Config) error { return config.DeleteProfile("missing") },
+			err:      "Profile 'missing' does not exist",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "rename the default and active profile",
+			run:      func(config *Config) error { return config.RenameProfile("dev", "main") },
+			names:    []string{"main", "prod"},
+			def:      "main",
+			active:   "main",
+			endpoint: "https://dev2.example.com",
+			check: func(t *testing.T, config *Config) {
+				config.syncActiveProfile()
+				if len(config.profiles) != 2 || config.profiles["main"].APIEndpoint != "https://dev2.example.com" {
+					t.Errorf("profiles after sync = %+v", config.profiles)
+				}
+			},
+		},
+		{
+			name:     "rename another profile",
+			run:      func(config *Config) error { return config.RenameProfile("prod", "live") },
+			names:    []string{"dev", "live"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+			check: func(t *testing.T, config *Config) {
+				if config.profiles["live"].AccessToken != "access" {
+					t.Errorf("live = %+v, want prod's settings", config.profiles["live"])
+				}
+			},
+		},
+		{
+			name:     "rename to an existing name",
+			run:      func(config *Config) error { return config.RenameProfile("dev", "prod") },
+			err:      "Profile 'prod' already exists",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "rename without a new name",
+			run:      func(config *Config) error { return config.RenameProfile("dev", "") },
+			err:      "A new profile name is required",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+		{
+			name:     "rename missing",
+			run:      func(config *Config) error { return config.RenameProfile("missing", "other") },
+			err:      "Profile 'missing' does not exist",
+			names:    []string{"dev", "prod"},
+			def:      "dev",
+			active:   "dev",
+			endpoint: "https://dev2.example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			config := newProfilesConfig()
+
+			err := test.run(config)
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if !reflect.DeepEqual(config.ProfileNames(), test.names) {
+				t.Errorf("ProfileNames = %v, want %v", config.ProfileNames(), test.names)
+			}
+			if config.GetDefaultProfile() != test.def || config.GetProfileName() != test.active {
+				t.Errorf("default, active = %q, %q, want %q, %q", config.GetDefaultProfile(), config.GetProfileName(), test.def, test.active)
+			}
+			if config.ConfigFile.APIEndpoint != test.endpoint {
+				t.Errorf("working endpoint = %q, want %q", config.ConfigFile.APIEndpoint, test.endpoint)
+			}
+			if test.check != nil {
+				test.check(t, config)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/bcsimms/uipo/util"
+)
+
+// SecretStore keeps tokens and application secrets out of config.json
+//  Get returns an empty string and no error when the key has not been stored
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+const (
+	// SecretStoreNone keeps secrets in config.json (the behaviour before secret stores existed)
+	SecretStoreNone string = "none"
+
+	// SecretStoreFile keeps secrets in an encrypted file next to config.json
+	SecretStoreFile string = "file"
+
+	// SecretStoreSecretService keeps secrets in the freedesktop Secret Service (GNOME Keyring, KWallet)
+	SecretStoreSecretService string = "secret-service"
+)
+
+// warningOutput receives the warning printed when tokens are saved in config.json
+var warningOutput io.Writer = os.Stderr
+
+// openSecretStore returns the backend for the given store name.  No store is
+//  returned for SecretStoreNone.  Backends are opened once per run
+func (config *Config) openSecretStore(name string) (SecretStore, error) {
+
+	if store, ok := config.secretStores[name]; ok {
+		return store, nil
+	}
+
+	var store SecretStore
+	var err error
+	switch name {
+	case "", SecretStoreNone:
+		return nil, nil
+	case SecretStoreFile:
+		keyFile := config.ENV.UIPOSecretKeyFile
+		if keyFile == "" {
+			keyFile = config.secretKeyFile
+		}
+		store, err = openEncryptedFileStore(secretsFilePath(), config.ENV.UIPOSecretPassphrase, keyFile)
+	case SecretStoreSecretService:
+		store, err = newSecretServiceStore()
+	default:
+		return nil, errors.New("Unknown secret store '" + name + "'.  Use none, file or secret-service")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.secretStores == nil {
+		config.secretStores = map[string]SecretStore{}
+	}
+	config.secretStores[name] = store
+
+	return store, nil
+}
+
+// profileSecrets returns the secret values held by every profile, keyed by
+//  "<profile>/<field>" as used in the secret store
+func (config *Config) profileSecrets() map[string]string {
+
+	secrets := map[string]string{}
+	for name, profile := range config.profiles {
+		secrets[name+"/AccessToken"] = profile.AccessToken
+		secrets[name+"/RefreshToken"] = profile.RefreshToken
+		secrets[name+"/AppSecret"] = profile.AppSecret
+	}
+
+	return secrets
+}
+
+// loadSecrets reads the secrets kept in config.json.  Secrets kept in a secret
+//  store are left locked until UnlockSecrets is called, so that commands which
+//  do not use them run without the store's passphrase
+func (config *Config) loadSecrets() error {
+
+	if config.secretStoreName == "" {
+		config.secretStoreName = SecretStoreNone
+	}
+	config.storedSecretStore = config.secretStoreName
+
+	util.AddSecret(config.ENV.UIPOAppSecret)
+	util.AddSecret(config.ENV.UIPOPassword)
+
+	if config.secretStoreName == SecretStoreNone {
+		return config.UnlockSecrets()
+	}
+
+	return nil
+}
+
+// UnlockSecrets fills in the secret fields of every profile from the secret store
+//  and registers every secret so that it is masked in log output.  The store is
+//  only read the first time, and secrets already set during this run are kept
+func (config *Config) UnlockSecrets() error {
+
+	if config.secretsUnlocked {
+		return nil
+	}
+
+	store, err := config.openSecretStore(config.storedSecretStore)
+	if err != nil {
+		return err
+	}
+
+	// With no store this is what config.json already holds, so that saveSecrets
+	//  only warns about tokens written in this run
+	stored := config.profileSecrets()
+	if store != nil {
+		for key := range stored {
+			if stored[key], err = store.Get(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, profile := range config.profiles {
+		fillSecrets(&profile, name, stored)
+		config.profiles[name] = profile
+
+		util.AddSecret(profile.AccessToken)
+		util.AddSecret(profile.RefreshToken)
+		util.AddSecret(profile.AppSecret)
+	}
+	if config.activeProfile != "" {
+		fillSecrets(&config.ConfigFile, config.activeProfile, stored)
+	}
+
+	config.storedSecrets = stored
+	config.secretsUnlocked = true
+
+	return nil
+}
+
+// fillSecrets sets the secret fields of a profile that are still empty from the
+//  stored values
+func fillSecrets(profile *JSONConfig, name string, stored map[string]string) {
+
+	if profile.AccessToken == "" {
+		profile.AccessToken = stored[name+"/AccessToken"]
+	}
+	if profile.RefreshToken == "" {
+		profile.RefreshToken = stored[name+"/RefreshToken"]
+	}
+	if profile.AppSecret == "" {
+		profile.AppSecret = stored[name+"/AppSecret"]
+	}
+}
+
+// saveSecrets writes changed secrets to the secret store and returns the profiles
+//  to serialize, with their secret fields cleared when a store is in use
+func (config *Config) saveSecrets() (map[string]JSONConfig, error) {
+
+	// A store that was never unlocked is left alone unless this run set secrets
+	if !config.secretsUnlocked {
+		unsaved := false
+		for _, value := range config.profileSecrets() {
+			unsaved = unsaved || value != ""
+		}
+		if !unsaved {
+			return config.profiles, nil
+		}
+		if err := config.UnlockSecrets(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Moving to a different store (or back to config.json) clears the old store
+	if config.storedSecretStore != config.secretStoreName && len(config.storedSecrets) > 0 {
+		oldStore, err := config.openSecretStore(config.storedSecretStore)
+		if err != nil {
+			return nil, err
+		}
+		if oldStore != nil {
+			for key := range config.storedSecrets {
+				if err = oldStore.Delete(key); err != nil {
+					return nil, err
+				}
+			}
+		}
+		config.storedSecrets = nil
+	}
+
+	store, err := config.openSecretStore(config.secretStoreName)
+	if err != nil {
+		return nil, err
+	}
+	secrets := config.profileSecrets()
+	if store == nil {
+		config.warnPlainSecrets(secrets)
+		config.storedSecrets = secrets
+		config.storedSecretStore = config.secretStoreName
+		return config.profiles, nil
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if secrets[key] == config.storedSecrets[key] {
+			continue
+		}
+		if secrets[key] == "" {
+			err = store.Delete(key)
+		} else {
+			err = store.Set(key, secrets[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Secrets belonging to deleted or renamed profiles
+	for key := range config.storedSecrets {
+		if _, ok := secrets[key]; !ok {
+			if err = store.Delete(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	config.storedSecrets = secrets
+	config.storedSecretStore = config.secretStoreName
+
+	profiles := map[string]JSONConfig{}
+	for name, profile := range config.profiles {
+		profile.AccessToken = ""
+		profile.RefreshToken = ""
+		profile.AppSecret = ""
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// warnPlainSecrets warns when tokens or secrets not already in config.json are
+//  about to be written to it unencrypted
+func (config *Config) warnPlainSecrets(secrets map[string]string) {
+
+	for key, value := range secrets {
+		if value != "" && value != config.storedSecrets[key] {
+			fmt.Fprintln(warningOutput, "Warning: Tokens are saved unencrypted in "+ConfigFilePath()+".  Use 'uipo setup --secret-store secret-service' or '--secret-store file' to keep them in a secret store")
+			return
+		}
+	}
+}
+
+// GetSecretStore returns the name of the secret store used for tokens and secrets
+func (config *Config) GetSecretStore() string {
+	return config.secretStoreName
+}
+
+// SetSecretStore selects where tokens and secrets are kept.  Existing secrets are
+//  moved to the new store when the config is written
+func (config *Config) SetSecretStore(name string) error {
+
+	switch name {
+	case SecretStoreNone, SecretStoreFile, SecretStoreSecretService:
+	default:
+		return errors.New("Unknown secret store '" + name + "'.  Use none, file or secret-service")
+	}
+	if name == config.secretStoreName {
+		return nil
+	}
+
+	// The secrets are read from the current store now to be moved when the
+	//  config is written
+	if err := config.UnlockSecrets(); err != nil {
+		return err
+	}
+
+	// Fail now rather than when the config is written, e.g. when no passphrase is set
+	if _, err := config.openSecretStore(name); err != nil {
+		return err
+	}
+	config.secretStoreName = name
+
+	return nil
+}
+
+// SetSecretKeyFile sets the key file used to encrypt the file secret store
+//  The "UIPO_SECRET_KEYFILE" environment variable takes precedence
+func (config *Config) SetSecretKeyFile(path string) {
+	config.secretKeyFile = path
+}
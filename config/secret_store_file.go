@@ -0,0 +1,159 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// secretsFilePath returns the location of the encrypted secrets file
+func secretsFilePath() string {
+	return filepath.Join(configDirectory(), "secrets.enc")
+}
+
+// encryptedFile is the on-disk layout of the secrets file.  Data is the AES-GCM
+//  sealed JSON map of secrets, keyed with scrypt from the passphrase or key file
+type encryptedFile struct {
+	Salt  []byte `json:"Salt"`
+	Nonce []byte `json:"Nonce"`
+	Data  []byte `json:"Data"`
+}
+
+// encryptedFileStore is a SecretStore backed by a single encrypted file
+type encryptedFileStore struct {
+	path    string
+	salt    []byte
+	aead    cipher.AEAD
+	secrets map[string]string
+}
+
+func openEncryptedFileStore(path string, passphrase string, keyFile string) (*encryptedFileStore, error) {
+
+	keyMaterial := passphrase
+	if keyFile != "" {
+		rawKey, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		keyMaterial = strings.TrimSpace(string(rawKey))
+	}
+	if keyMaterial == "" {
+		return nil, errors.New("The file secret store needs a passphrase (UIPO_SECRET_PASSPHRASE) or key file (UIPO_SECRET_KEYFILE)")
+	}
+
+	store := encryptedFileStore{
+		path:    path,
+		secrets: map[string]string{},
+	}
+
+	var sealed encryptedFile
+	rawFile, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(rawFile, &sealed)
+		if err != nil {
+			return nil, err
+		}
+		store.salt = sealed.Salt
+	} else if os.IsNotExist(err) {
+		store.salt = make([]byte, 16)
+		if _, err = rand.Read(store.salt); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	key, err := scrypt.Key([]byte(keyMaterial), store.salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	store.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if sealed.Data != nil {
+		plain, err := store.aead.Open(nil, sealed.Nonce, sealed.Data, nil)
+		if err != nil {
+			return nil, errors.New("Unable to decrypt " + path + ".  Check the secret store passphrase or key file")
+		}
+		err = json.Unmarshal(plain, &store.secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &store, nil
+}
+
+func (store *encryptedFileStore) Get(key string) (string, error) {
+	return store.secrets[key], nil
+}
+
+func (store *encryptedFileStore) Set(key string, value string) error {
+	store.secrets[key] = value
+	return store.write()
+}
+
+func (store *encryptedFileStore) Delete(key string) error {
+	if _, ok := store.secrets[key]; !ok {
+		return nil
+	}
+	delete(store.secrets, key)
+	return store.write()
+}
+
+// write seals the secrets with a fresh nonce and replaces the file atomically
+func (store *encryptedFileStore) write() error {
+
+	plain, err := json.Marshal(store.secrets)
+	if err != nil {
+		return err
+	}
+
+	sealed := encryptedFile{
+		Salt:  store.salt,
+		Nonce: make([]byte, store.aead.NonceSize()),
+	}
+	if _, err = rand.Read(sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Data = store.aead.Seal(nil, sealed.Nonce, plain, nil)
+
+	rawFile, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(store.path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(dir, "temp-secrets")
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+
+	err = ioutil.WriteFile(tempFile.Name(), rawFile, 0600)
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), store.path)
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceStore is a SecretStore backed by the freedesktop Secret Service
+//  (GNOME Keyring, KWallet) through the libsecret secret-tool command
+type secretServiceStore struct {
+	secretTool string
+}
+
+func newSecretServiceStore() (*secretServiceStore, error) {
+
+	secretTool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, errors.New("The secret-service store needs the secret-tool command (libsecret-tools) to be installed")
+	}
+
+	return &secretServiceStore{secretTool: secretTool}, nil
+}
+
+func (store *secretServiceStore) Get(key string) (string, error) {
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(store.secretTool, "lookup", "service", "uipo", "key", key)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		// secret-tool exits with status 1 and no output when nothing is stored
+		if _, ok := err.(*exec.ExitError); ok && stderr.Len() == 0 {
+			return "", nil
+		}
+		return "", errors.New("secret-tool lookup failed: " + strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+func (store *secretServiceStore) Set(key string, value string) error {
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(store.secretTool, "store", "--label", "uipo "+key, "service", "uipo", "key", key)
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.New("secret-tool store failed: " + strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (store *secretServiceStore) Delete(key string) error {
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(store.secretTool, "clear", "service", "uipo", "key", key)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil && stderr.Len() > 0 {
+		return errors.New("secret-tool clear failed: " + strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedFileStoreRoundTrip(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+
+	store, err := openEncryptedFileStore(path, "passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"default/AccessToken": "access", "default/RefreshToken": "refresh", "prod/AppSecret": "secret"} {
+		if err = store.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = store.Delete("prod/AppSecret"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete("never/Stored"); err != nil {
+		t.Fatal(err)
+	}
+
+	rawFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(rawFile, []byte("access")) || bytes.Contains(rawFile, []byte("refresh")) {
+		t.Errorf("secrets are readable in the file: %s", rawFile)
+	}
+
+	reopened, err := openEncryptedFileStore(path, "passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"default/AccessToken", "access"},
+		{"default/RefreshToken", "refresh"},
+		{"prod/AppSecret", ""},
+		{"missing/AccessToken", ""},
+	}
+	for _, test := range tests {
+		got, err := reopened.Get(test.key)
+		if err != nil || got != test.want {
+			t.Errorf("Get(%q) = %q, %v, want %q", test.key, got, err, test.want)
+		}
+	}
+}
+
+func TestEncryptedFileStoreKeyFile(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc")
+	keyFile := filepath.Join(dir, "uipo.key")
+	if err := ioutil.WriteFile(keyFile, []byte("key material\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openEncryptedFileStore(path, "ignored", keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set("default/AccessToken", "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key file takes precedence over the passphrase, and its trailing newline is ignored
+	reopened, err := openEncryptedFileStore(path, "key material", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get("default/AccessToken"); got != "access" {
+		t.Errorf("Get = %q, want access", got)
+	}
+}
+
+func TestEncryptedFileStoreErrors(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		passphrase string
+		// corrupt changes the file written with the passphrase "passphrase"
+		corrupt func(rawFile []byte) []byte
+		err     string
+	}{
+		{
+			name: "no passphrase or key file",
+			err:  "The file secret store needs a passphrase (UIPO_SECRET_PASSPHRASE) or key file (UIPO_SECRET_KEYFILE)",
+		},
+		{
+			name:       "wrong passphrase",
+			passphrase: "wrong",
+			err:        "Unable to decrypt <path>.  Check the secret store passphrase or key file",
+		},
+		{
+			name:       "tampered data",
+			passphrase: "passphrase",
+			corrupt: func(rawFile []byte) []byte {
+				var sealed encryptedFile
+				_ = json.Unmarshal(rawFile, &sealed)
+				sealed.Data[0] ^= 0xff
+				rawFile, _ = json.Marshal(sealed)
+				return rawFile
+			},
+			err: "Unable to decrypt <path>.  Check the secret store passphrase or key file",
+		},
+		{
+			name:       "file that is not JSON",
+			passphrase: "passphrase",
+			corrupt: func(rawFile []byte) []byte {
+				return rawFile[:len(rawFile)/2]
+			},
+			err: "unexpected end of JSON input",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			path := filepath.Join(t.TempDir(), "secrets.enc")
+			store, err := openEncryptedFileStore(path, "passphrase", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = store.Set("default/AccessToken", "access"); err != nil {
+				t.Fatal(err)
+			}
+			if test.corrupt != nil {
+				rawFile, _ := ioutil.ReadFile(path)
+				if err = ioutil.WriteFile(path, test.corrupt(rawFile), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = openEncryptedFileStore(path, test.passphrase, "")
+
+			want := strings.Replace(test.err, "<path>", path, 1)
+			if err == nil || err.Error() != want {
+				t.Errorf("err = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestSaveSecretsWarnsAboutPlainTokens(t *testing.T) {
+
+	var warnings bytes.Buffer
+	defer func(previous io.Writer) { warningOutput = previous }(warningOutput)
+	warningOutput = &warnings
+
+	config := &Config{
+		secretStoreName: SecretStoreNone,
+		profiles:        map[string]JSONConfig{"default": {AccessToken: "loaded"}},
+	}
+	if err := config.loadSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		accessToken string
+		warned      bool
+	}{
+		{"tokens already in config.json", "loaded", false},
+		{"new token", "renewed", true},
+		{"token written earlier in the run", "renewed", false},
+		{"token cleared", "", false},
+	}
+
+	for _, test := range tests {
+		warnings.Reset()
+		config.profiles["default"] = JSONConfig{AccessToken: test.accessToken}
+
+		profiles, err := config.saveSecrets()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if profiles["default"].AccessToken != test.accessToken {
+			t.Errorf("%s: config.json token = %q, want %q", test.name, profiles["default"].AccessToken, test.accessToken)
+		}
+		if warned := strings.HasPrefix(warnings.String(), "Warning: Tokens are saved unencrypted"); warned != test.warned {
+			t.Errorf("%s: warned = %v, want %v (%q)", test.name, warned, test.warned, warnings.String())
+		}
+	}
+}
+
+// useLockedFileStore writes a config using the file secret store, whose default
+//  profile's tokens are encrypted with "passphrase", and unsets the passphrase
+func useLockedFileStore(t *testing.T) {
+
+	useConfigHome(t, []byte(`{"ConfigVersion":2,"CurrentProfile":"default","SecretStore":"file","Profiles":{"default":{"APIEndpoint":"https://orch.example.com"},"prod":{}}}`))
+	t.Setenv("UIPO_SECRET_PASSPHRASE", "")
+	t.Setenv("UIPO_SECRET_KEYFILE", "")
+
+	store, err := openEncryptedFileStore(secretsFilePath(), "passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"default/AccessToken": "access", "default/RefreshToken": "refresh", "prod/AccessToken": "prod-access"} {
+		if err = store.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// storedSecret reads a secret back from the file store written by useLockedFileStore
+func storedSecret(t *testing.T, key string) string {
+
+	store, err := openEncryptedFileStore(secretsFilePath(), "passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := store.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func TestLoadConfigLeavesSecretStoreLocked(t *testing.T) {
+
+	locked := "The file secret store needs a passphrase (UIPO_SECRET_PASSPHRASE) or key file (UIPO_SECRET_KEYFILE)"
+
+	tests := []struct {
+		name string
+		run  func(config *Config) error
+		err  string
+	}{
+		{
+			name: "settings without secrets",
+			run: func(config *Config) error {
+				config.SetAPIEndpoint("https://orch2.example.com")
+				config.SetSecretKeyFile("/keys/uipo.key")
+				return config.SetSecretStore(SecretStoreFile)
+			},
+		},
+		{
+			name: "profile changes without secrets",
+			run: func(config *Config) error {
+				if err := config.CreateProfile("test", "default"); err != nil {
+					return err
+				}
+				return config.UseProfile("test")
+			},
+		},
+		{
+			name: "unlock",
+			run:  func(config *Config) error { return config.UnlockSecrets() },
+			err:  locked,
+		},
+		{
+			name: "move to another store",
+			run:  func(config *Config) error { return config.SetSecretStore(SecretStoreNone) },
+			err:  locked,
+		},
+		{
+			name: "delete a profile",
+			run:  func(config *Config) error { return config.DeleteProfile("prod") },
+			err:  locked,
+		},
+		{
+			name: "rename a profile",
+			run:  func(config *Config) error { return config.RenameProfile("prod", "live") },
+			err:  locked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			useLockedFileStore(t)
+
+			config, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.GetAccessToken() != "" {
+				t.Errorf("access token = %q before unlocking", config.GetAccessToken())
+			}
+
+			err = test.run(config)
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if err = config.WriteConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The store is left as it was
+			if storedSecret(t, "default/AccessToken") != "access" || storedSecret(t, "prod/AccessToken") != "prod-access" {
+				t.Error("the secret store was changed without being unlocked")
+			}
+		})
+	}
+}
+
+func TestUnlockSecrets(t *testing.T) {
+
+	useLockedFileStore(t)
+	t.Setenv("UIPO_SECRET_PASSPHRASE", "passphrase")
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token set before the store is unlocked, e.g. by auth, is kept
+	config.SetAccessToken("renewed")
+	if err = config.UnlockSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if config.GetAccessToken() != "renewed" || config.GetRefreshToken() != "refresh" {
+		t.Errorf("access, refresh token = %q, %q, want renewed, refresh", config.GetAccessToken(), config.GetRefreshToken())
+	}
+
+	if err = config.RenameProfile("prod", "live"); err != nil {
+		t.Fatal(err)
+	}
+	if err = config.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"default/AccessToken":  "renewed",
+		"default/RefreshToken": "refresh",
+		"live/AccessToken":     "prod-access",
+		"prod/AccessToken":     "",
+	}
+	for key, value := range want {
+		if got := storedSecret(t, key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestSaveSecretsUnlocksForNewSecrets(t *testing.T) {
+
+	useLockedFileStore(t)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetRefreshToken("new-refresh")
+
+	err = config.WriteConfig()
+	if err == nil || !strings.HasPrefix(err.Error(), "The file secret store needs a passphrase") {
+		t.Errorf("err = %v, want the store's passphrase error", err)
+	}
+
+	config.ENV.UIPOSecretPassphrase = "passphrase"
+	if err = config.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if storedSecret(t, "default/RefreshToken") != "new-refresh" || storedSecret(t, "default/AccessToken") != "access" {
+		t.Error("the new refresh token was not saved alongside the stored access token")
+	}
+}
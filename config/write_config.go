@@ -9,8 +9,28 @@ import (
 )
 
 // WriteConfig creates the .uipo directory and then writes the config.json.
+// The active profile is saved alongside every other profile in the file.
 func (c *Config) WriteConfig() error {
-	rawConfig, err := json.MarshalIndent(c.ConfigFile, "", "  ")
+	c.syncActiveProfile()
+
+	// Tokens and secrets are written to the secret store, if one is configured,
+	//  and cleared from the profiles serialized below
+	profiles, err := c.saveSecrets()
+	if err != nil {
+		return err
+	}
+
+	configFile := configFileV2{
+		ConfigVersion:  CurrentConfigVersion,
+		CurrentProfile: c.currentProfile,
+		SecretKeyFile:  c.secretKeyFile,
+		Profiles:       profiles,
+	}
+	if c.secretStoreName != SecretStoreNone {
+		configFile.SecretStore = c.secretStoreName
+	}
+
+	rawConfig, err := json.MarshalIndent(configFile, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,238 @@
+package nupkg
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is the content of a NuGet package file, read from its .nuspec manifest
+// and the UiPath project.json bundled with the workflows
+type Package struct {
+	File               string       `json:"File"`
+	ID                 string       `json:"Id"`
+	Version            string       `json:"Version"`
+	Title              string       `json:"Title"`
+	Authors            string       `json:"Authors"`
+	Description        string       `json:"Description"`
+	ProjectName        string       `json:"ProjectName"`
+	ProjectVersion     string       `json:"ProjectVersion"`
+	OutputType         string       `json:"OutputType"`
+	TargetFramework    string       `json:"TargetFramework"`
+	ExpressionLanguage string       `json:"ExpressionLanguage"`
+	StudioVersion      string       `json:"StudioVersion"`
+	Main               string       `json:"Main"`
+	EntryPoints        []EntryPoint `json:"EntryPoints"`
+	Dependencies       []Dependency `json:"Dependencies"`
+
+	// hasProject is false for NuGet packages that were not built by UiPath Studio
+	hasProject   bool
+	projectError error
+	projectDir   string
+	files        map[string]bool
+}
+
+// EntryPoint is a workflow that can be started as a job
+type EntryPoint struct {
+	FilePath string `json:"FilePath"`
+	UniqueID string `json:"UniqueId"`
+}
+
+// Dependency is a package the workflows need at run time.  Version is a NuGet
+// version range such as "[1.2.3]" (exactly 1.2.3) or "1.2.3" (1.2.3 or newer)
+type Dependency struct {
+	ID              string `json:"Id"`
+	Version         string `json:"Version"`
+	TargetFramework string `json:"TargetFramework,omitempty"`
+}
+
+type nuspec struct {
+	Metadata struct {
+		ID           string `xml:"id"`
+		Version      string `xml:"version"`
+		Title        string `xml:"title"`
+		Authors      string `xml:"authors"`
+		Description  string `xml:"description"`
+		Dependencies struct {
+			Groups []struct {
+				TargetFramework string             `xml:"targetFramework,attr"`
+				Dependencies    []nuspecDependency `xml:"dependency"`
+			} `xml:"group"`
+			Dependencies []nuspecDependency `xml:"dependency"`
+		} `xml:"dependencies"`
+	} `xml:"metadata"`
+}
+
+type nuspecDependency struct {
+	ID      string `xml:"id,attr"`
+	Version string `xml:"version,attr"`
+}
+
+type projectJSON struct {
+	Name               string            `json:"name"`
+	Main               string            `json:"main"`
+	ProjectVersion     string            `json:"projectVersion"`
+	TargetFramework    string            `json:"targetFramework"`
+	ExpressionLanguage string            `json:"expressionLanguage"`
+	StudioVersion      string            `json:"studioVersion"`
+	Dependencies       map[string]string `json:"dependencies"`
+	EntryPoints        []struct {
+		FilePath string `json:"filePath"`
+		UniqueID string `json:"uniqueId"`
+	} `json:"entryPoints"`
+	DesignOptions struct {
+		OutputType string `json:"outputType"`
+	} `json:"designOptions"`
+}
+
+// Open reads the manifest and project details of a .nupkg file
+// An error is returned when the file is not a zip archive or has no .nuspec
+// manifest.  Problems with project.json are reported by Validate
+func Open(packagePath string) (*Package, error) {
+
+	archive, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, errors.New(filepath.Base(packagePath) + " is not a valid package: " + err.Error())
+	}
+	defer archive.Close()
+
+	pkg := Package{
+		File:  filepath.Base(packagePath),
+		files: map[string]bool{},
+	}
+
+	// NuGet escapes file names in the archive, e.g. spaces become %20
+	var manifest, project *zip.File
+	for _, file := range archive.File {
+		name := file.Name
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		pkg.files[name] = true
+
+		switch {
+		case !strings.Contains(name, "/") && strings.HasSuffix(strings.ToLower(name), ".nuspec"):
+			manifest = file
+		case path.Base(name) == "project.json" && strings.HasPrefix(name, "lib/"):
+			if project == nil || strings.Count(name, "/") < strings.Count(project.Name, "/") {
+				project = file
+				pkg.projectDir = path.Dir(name)
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New(pkg.File + " is not a valid package: no .nuspec manifest was found")
+	}
+	err = pkg.readManifest(manifest)
+	if err != nil {
+		return nil, errors.New(pkg.File + " is not a valid package: " + err.Error())
+	}
+
+	if project != nil {
+		pkg.hasProject = true
+		pkg.projectError = pkg.readProject(project)
+	}
+
+	return &pkg, nil
+}
+
+func (p *Package) readManifest(file *zip.File) error {
+
+	raw, err := readFile(file)
+	if err != nil {
+		return err
+	}
+
+	spec := nuspec{}
+	err = xml.Unmarshal(raw, &spec)
+	if err != nil {
+		return errors.New("unable to read " + file.Name + ": " + err.Error())
+	}
+
+	p.ID = strings.TrimSpace(spec.Metadata.ID)
+	p.Version = strings.TrimSpace(spec.Metadata.Version)
+	p.Title = spec.Metadata.Title
+	p.Authors = spec.Metadata.Authors
+	p.Description = spec.Metadata.Description
+
+	for _, dependency := range spec.Metadata.Dependencies.Dependencies {
+		p.Dependencies = append(p.Dependencies, Dependency{ID: dependency.ID, Version: dependency.Version})
+	}
+	for _, group := range spec.Metadata.Dependencies.Groups {
+		if p.TargetFramework == "" {
+			p.TargetFramework = group.TargetFramework
+		}
+		for _, dependency := range group.Dependencies {
+			p.Dependencies = append(p.Dependencies, Dependency{ID: dependency.ID, Version: dependency.Version, TargetFramework: group.TargetFramework})
+		}
+	}
+
+	return nil
+}
+
+func (p *Package) readProject(file *zip.File) error {
+
+	raw, err := readFile(file)
+	if err != nil {
+		return err
+	}
+
+	project := projectJSON{}
+	err = json.Unmarshal(raw, &project)
+	if err != nil {
+		return errors.New("unable to read " + file.Name + ": " + err.Error())
+	}
+
+	p.ProjectName = project.Name
+	p.ProjectVersion = project.ProjectVersion
+	p.OutputType = project.DesignOptions.OutputType
+	p.ExpressionLanguage = project.ExpressionLanguage
+	p.StudioVersion = project.StudioVersion
+	p.Main = project.Main
+	if project.TargetFramework != "" {
+		p.TargetFramework = project.TargetFramework
+	}
+	for _, entryPoint := range project.EntryPoints {
+		p.EntryPoints = append(p.EntryPoints, EntryPoint{FilePath: entryPoint.FilePath, UniqueID: entryPoint.UniqueID})
+	}
+
+	// Older Studio versions only list dependencies in project.json
+	if len(p.Dependencies) == 0 {
+		var ids []string
+		for id := range project.Dependencies {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			p.Dependencies = append(p.Dependencies, Dependency{ID: id, Version: project.Dependencies[id]})
+		}
+	}
+
+	return nil
+}
+
+// hasFile reports whether a project relative path, as written in project.json,
+// is in the package
+func (p *Package) hasFile(projectPath string) bool {
+	name := path.Join(p.projectDir, strings.Replace(projectPath, "\\", "/", -1))
+	return p.files[name]
+}
+
+func readFile(file *zip.File) ([]byte, error) {
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
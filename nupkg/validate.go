@@ -0,0 +1,136 @@
+package nupkg
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Severity of a problem found in a package
+const (
+	// SeverityError marks a package that should not be uploaded
+	SeverityError string = "Error"
+	// SeverityWarning marks something worth checking that does not stop an upload
+	SeverityWarning string = "Warning"
+)
+
+// Problem is an issue found when validating a package
+type Problem struct {
+	Severity string `json:"Severity"`
+	Message  string `json:"Message"`
+}
+
+// Validate checks the package for problems that would stop it from running in
+// Orchestrator, such as a missing entry point or a file name that does not match
+// the version inside the package.  Checks against the feed are left to the caller
+func (p *Package) Validate() []Problem {
+
+	var problems []Problem
+	fail := func(message string) {
+		problems = append(problems, Problem{Severity: SeverityError, Message: message})
+	}
+	warn := func(message string) {
+		problems = append(problems, Problem{Severity: SeverityWarning, Message: message})
+	}
+
+	if p.ID == "" {
+		fail("The .nuspec manifest has no package id")
+	}
+	if p.Version == "" {
+		fail("The .nuspec manifest has no package version")
+	} else if _, err := ParseVersion(p.Version); err != nil {
+		fail("The package version is not valid: " + err.Error())
+	}
+
+	// Renamed files are common, so only a name that looks like <id>.<version> is checked
+	if id, version, ok := IdentityFromFile(p.File); ok && p.ID != "" && p.Version != "" {
+		if !strings.EqualFold(id, p.ID) || !SameVersion(version, p.Version) {
+			fail("The file name says " + id + " " + version + " but the package is " + p.ID + " " + p.Version)
+		}
+	}
+
+	for _, dependency := range p.Dependencies {
+		if _, err := ParseVersionRange(dependency.Version); err != nil {
+			fail("Dependency " + dependency.ID + ": " + err.Error())
+		}
+	}
+
+	switch {
+	case !p.hasProject:
+		fail("No project.json was found.  The package was not built by UiPath Studio")
+	case p.projectError != nil:
+		fail("The project.json is not valid: " + p.projectError.Error())
+	default:
+		problems = append(problems, p.validateProject()...)
+	}
+
+	if p.ProjectVersion != "" && p.Version != "" && !SameVersion(p.ProjectVersion, p.Version) {
+		warn("project.json has version " + p.ProjectVersion + " but the package is " + p.Version)
+	}
+
+	return problems
+}
+
+func (p *Package) validateProject() []Problem {
+
+	var problems []Problem
+	for _, entryPoint := range p.EntryPoints {
+		if !p.hasFile(entryPoint.FilePath) {
+			problems = append(problems, Problem{Severity: SeverityError, Message: "Entry point " + entryPoint.FilePath + " is not in the package"})
+		}
+	}
+
+	if p.Main != "" && !p.hasFile(p.Main) {
+		problems = append(problems, Problem{Severity: SeverityError, Message: "The main workflow " + p.Main + " is not in the package"})
+	}
+
+	// Libraries and test projects are not started as jobs
+	if (p.OutputType == "" || strings.EqualFold(p.OutputType, "Process")) && len(p.EntryPoints) == 0 && p.Main == "" {
+		problems = append(problems, Problem{Severity: SeverityError, Message: "The process has no entry points"})
+	}
+
+	return problems
+}
+
+// HasErrors reports whether any of the problems stops the package from being uploaded
+func HasErrors(problems []Problem) bool {
+
+	for _, problem := range problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IdentityFromFile splits the name of a <id>.<version>.nupkg file, which may be
+// given as a path.  The version starts at the first dot separated part beginning
+// with a digit
+func IdentityFromFile(fileName string) (string, string, bool) {
+
+	fileName = filepath.Base(fileName)
+	if !strings.HasSuffix(strings.ToLower(fileName), ".nupkg") {
+		return "", "", false
+	}
+
+	parts := strings.Split(fileName[:len(fileName)-len(".nupkg")], ".")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" && parts[i][0] >= '0' && parts[i][0] <= '9' {
+			return strings.Join(parts[:i], "."), strings.Join(parts[i:], "."), true
+		}
+	}
+
+	return "", "", false
+}
+
+// SameVersion compares versions the way NuGet does, so 1.0 and 1.0.0 match
+func SameVersion(a string, b string) bool {
+
+	versionA, errA := ParseVersion(a)
+	versionB, errB := ParseVersion(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+
+	return versionA.Compare(versionB) == 0
+}
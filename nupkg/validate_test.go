@@ -0,0 +1,52 @@
+package nupkg
+
+import "testing"
+
+func TestIdentityFromFile(t *testing.T) {
+
+	tests := []struct {
+		fileName string
+		id       string
+		version  string
+		ok       bool
+	}{
+		{"Invoices.1.0.2.nupkg", "Invoices", "1.0.2", true},
+		{"Acme.Finance.Invoices.2.0.0-beta.1.nupkg", "Acme.Finance.Invoices", "2.0.0-beta.1", true},
+		{"/builds/out/Invoices.1.0.2.NUPKG", "Invoices", "1.0.2", true},
+		{"Invoices.nupkg", "", "", false},
+		{"Invoices.1.0.2.zip", "", "", false},
+	}
+
+	for _, test := range tests {
+		id, version, ok := IdentityFromFile(test.fileName)
+		if id != test.id || version != test.version || ok != test.ok {
+			t.Errorf("IdentityFromFile(%q) = %q, %q, %v, want %q, %q, %v", test.fileName, id, version, ok, test.id, test.version, test.ok)
+		}
+	}
+}
+
+func TestValidateFileName(t *testing.T) {
+
+	tests := []struct {
+		file    string
+		problem string
+	}{
+		{"Invoices.1.0.nupkg", ""},
+		{"dist/invoices.1.0.0.nupkg", ""},
+		{"renamed.nupkg", ""},
+		{"Invoices.1.0.1.nupkg", "The file name says Invoices 1.0.1 but the package is Invoices 1.0.0"},
+		{"Payments.1.0.0.nupkg", "The file name says Payments 1.0.0 but the package is Invoices 1.0.0"},
+	}
+
+	for _, test := range tests {
+		p := Package{File: test.file, ID: "Invoices", Version: "1.0.0", Main: "Main.xaml", hasProject: true, files: map[string]bool{"Main.xaml": true}}
+
+		problem := ""
+		for _, found := range p.Validate() {
+			problem = found.Message
+		}
+		if problem != test.problem {
+			t.Errorf("Validate(%q) = %q, want %q", test.file, problem, test.problem)
+		}
+	}
+}
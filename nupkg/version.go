@@ -0,0 +1,179 @@
+package nupkg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed NuGet version such as 1.2.3 or 2.0.0-beta.1
+type Version struct {
+	Numbers    []int
+	Prerelease string
+}
+
+// ParseVersion parses a NuGet version.  Build metadata after a "+" is ignored
+func ParseVersion(value string) (Version, error) {
+
+	version := Version{}
+	value = strings.TrimSpace(value)
+	invalid := errors.New("Invalid version '" + value + "'")
+	if idx := strings.Index(value, "+"); idx >= 0 {
+		value = value[:idx]
+	}
+	if idx := strings.Index(value, "-"); idx >= 0 {
+		version.Prerelease = value[idx+1:]
+		value = value[:idx]
+		if version.Prerelease == "" {
+			return Version{}, invalid
+		}
+	}
+
+	parts := strings.Split(value, ".")
+	if len(parts) > 4 {
+		return Version{}, invalid
+	}
+	for _, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return Version{}, invalid
+		}
+		version.Numbers = append(version.Numbers, number)
+	}
+
+	return version, nil
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or higher than other
+// Missing parts count as 0, so 1.2 equals 1.2.0, and a prerelease sorts before
+// the release it leads up to
+func (v Version) Compare(other Version) int {
+
+	for i := 0; i < len(v.Numbers) || i < len(other.Numbers); i++ {
+		a, b := 0, 0
+		if i < len(v.Numbers) {
+			a = v.Numbers[i]
+		}
+		if i < len(other.Numbers) {
+			b = other.Numbers[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	case strings.ToLower(v.Prerelease) < strings.ToLower(other.Prerelease):
+		return -1
+	}
+
+	return 1
+}
+
+// VersionRange is a NuGet dependency version range.  A nil bound is open
+type VersionRange struct {
+	Min          *Version
+	MinInclusive bool
+	Max          *Version
+	MaxInclusive bool
+}
+
+// ParseVersionRange parses NuGet range notation: "1.0" (1.0 or newer), "[1.0]"
+// (exactly 1.0) and interval forms such as "[1.0,2.0)" or "(,2.0]"
+func ParseVersionRange(value string) (VersionRange, error) {
+
+	value = strings.TrimSpace(value)
+	invalid := errors.New("Invalid version range '" + value + "'")
+	if value == "" {
+		return VersionRange{}, invalid
+	}
+
+	if !strings.HasPrefix(value, "[") && !strings.HasPrefix(value, "(") {
+		min, err := ParseVersion(value)
+		if err != nil {
+			return VersionRange{}, invalid
+		}
+		return VersionRange{Min: &min, MinInclusive: true}, nil
+	}
+
+	last := value[len(value)-1]
+	if len(value) < 3 || (last != ']' && last != ')') {
+		return VersionRange{}, invalid
+	}
+	versionRange := VersionRange{
+		MinInclusive: value[0] == '[',
+		MaxInclusive: last == ']',
+	}
+	bounds := strings.Split(value[1:len(value)-1], ",")
+
+	switch len(bounds) {
+	case 1:
+		// [1.0] is an exact version
+		exact, err := ParseVersion(bounds[0])
+		if err != nil || !versionRange.MinInclusive || !versionRange.MaxInclusive {
+			return VersionRange{}, invalid
+		}
+		versionRange.Min, versionRange.Max = &exact, &exact
+	case 2:
+		if strings.TrimSpace(bounds[0]) != "" {
+			min, err := ParseVersion(bounds[0])
+			if err != nil {
+				return VersionRange{}, invalid
+			}
+			versionRange.Min = &min
+		}
+		if strings.TrimSpace(bounds[1]) != "" {
+			max, err := ParseVersion(bounds[1])
+			if err != nil {
+				return VersionRange{}, invalid
+			}
+			versionRange.Max = &max
+		}
+	default:
+		return VersionRange{}, invalid
+	}
+
+	return versionRange, nil
+}
+
+// Contains reports whether the version falls inside the range
+func (r VersionRange) Contains(version Version) bool {
+
+	if r.Min != nil {
+		cmp := version.Compare(*r.Min)
+		if cmp < 0 || (cmp == 0 && !r.MinInclusive) {
+			return false
+		}
+	}
+	if r.Max != nil {
+		cmp := version.Compare(*r.Max)
+		if cmp > 0 || (cmp == 0 && !r.MaxInclusive) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContainsAny reports whether any of the versions falls inside the range
+// Values that are not valid versions are ignored
+func (r VersionRange) ContainsAny(versions []string) bool {
+
+	for _, value := range versions {
+		version, err := ParseVersion(value)
+		if err == nil && r.Contains(version) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package nupkg
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+
+	tests := []struct {
+		value      string
+		numbers    int
+		prerelease string
+		err        bool
+	}{
+		{"1.2.3", 3, "", false},
+		{" 1.2 ", 2, "", false},
+		{"1.0.0.4", 4, "", false},
+		{"2.0.0-beta.1", 3, "beta.1", false},
+		{"1.0.0+build.5", 3, "", false},
+		{"1.0.0-", 0, "", true},
+		{"1.0.0.0.1", 0, "", true},
+		{"1.x", 0, "", true},
+		{"1.-2", 0, "", true},
+		{"", 0, "", true},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("ParseVersion(%q) err = %v, want error %v", test.value, err, test.err)
+			continue
+		}
+		if len(version.Numbers) != test.numbers || version.Prerelease != test.prerelease {
+			t.Errorf("ParseVersion(%q) = %+v", test.value, version)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0", "1.99.99", 1},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-Beta", -1},
+		{"1.0.0-RC", "1.0.0-rc", 1},
+	}
+
+	for _, test := range tests {
+		a, _ := ParseVersion(test.a)
+		b, _ := ParseVersion(test.b)
+		if got := a.Compare(b); got != test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParseVersionRange(t *testing.T) {
+
+	tests := []struct {
+		value string
+		err   bool
+		// in and out are versions inside and outside the range
+		in  []string
+		out []string
+	}{
+		{value: "1.0", in: []string{"1.0", "1.0.1", "20.1"}, out: []string{"0.9", "1.0-beta"}},
+		{value: "[1.0]", in: []string{"1.0", "1.0.0"}, out: []string{"1.0.1", "0.9"}},
+		{value: "[1.0,2.0)", in: []string{"1.0", "1.9.9", "2.0-beta"}, out: []string{"2.0", "0.9"}},
+		{value: "(1.0,2.0]", in: []string{"1.0.1", "2.0"}, out: []string{"1.0", "2.0.1"}},
+		{value: "(,2.0]", in: []string{"0.1", "2.0"}, out: []string{"2.1"}},
+		{value: "[1.5, )", in: []string{"1.5", "99"}, out: []string{"1.4"}},
+		{value: "(,)", in: []string{"0.0.1", "99"}},
+		{value: "", err: true},
+		{value: "abc", err: true},
+		{value: "[1.0", err: true},
+		{value: "[]", err: true},
+		{value: "(1.0)", err: true},
+		{value: "[1.0,2.0,3.0]", err: true},
+		{value: "[x,2.0]", err: true},
+		{value: "[1.0,y]", err: true},
+	}
+
+	for _, test := range tests {
+		versionRange, err := ParseVersionRange(test.value)
+		if test.err {
+			if err == nil || err.Error() != "Invalid version range '"+test.value+"'" {
+				t.Errorf("ParseVersionRange(%q) err = %v, want an invalid range", test.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseVersionRange(%q) err = %v", test.value, err)
+			continue
+		}
+		for _, value := range test.in {
+			if !versionRange.ContainsAny([]string{value}) {
+				t.Errorf("range %q does not contain %s", test.value, value)
+			}
+		}
+		for _, value := range test.out {
+			if versionRange.ContainsAny([]string{value}) {
+				t.Errorf("range %q contains %s", test.value, value)
+			}
+		}
+	}
+}
+
+func TestContainsAny(t *testing.T) {
+
+	versionRange, _ := ParseVersionRange("[2.0,3.0)")
+
+	tests := []struct {
+		versions []string
+		want     bool
+	}{
+		{nil, false},
+		{[]string{"1.0", "3.0"}, false},
+		{[]string{"1.0", "not a version", "2.5"}, true},
+	}
+
+	for _, test := range tests {
+		if got := versionRange.ContainsAny(test.versions); got != test.want {
+			t.Errorf("ContainsAny(%q) = %v, want %v", test.versions, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package orchestrator
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// AssetsURI is the OData collection of assets in the client's folder
+const AssetsURI string = "/odata/Assets"
+
+// Asset value types
+const (
+	AssetText       string = "Text"
+	AssetInteger    string = "Integer"
+	AssetBool       string = "Bool"
+	AssetCredential string = "Credential"
+)
+
+// Asset value scopes.  A per robot asset holds a different value for each robot
+// and may also have a default used by robots without their own value
+const (
+	AssetGlobal   string = "Global"
+	AssetPerRobot string = "PerRobot"
+)
+
+// Asset is the representation of an asset in UiPath Orchestrator.  A per robot
+// asset keeps its default, if it has one, in AssetValue
+type Asset struct {
+	ID         int    `json:"Id"`
+	Name       string `json:"Name"`
+	ValueScope string `json:"ValueScope"`
+	AssetValue
+	HasDefaultValue bool              `json:"HasDefaultValue"`
+	Description     string            `json:"Description,omitempty"`
+	CanBeDeleted    bool              `json:"CanBeDeleted"`
+	RobotValues     []AssetRobotValue `json:"RobotValues"`
+}
+
+// AssetValue holds an asset value.  Only the field matching ValueType is used and
+// Value is the text Orchestrator shows for it.  Credential passwords are never
+// returned
+type AssetValue struct {
+	ValueType          string `json:"ValueType"`
+	Value              string `json:"Value,omitempty"`
+	StringValue        string `json:"StringValue,omitempty"`
+	IntValue           int    `json:"IntValue"`
+	BoolValue          bool   `json:"BoolValue"`
+	CredentialUsername string `json:"CredentialUsername,omitempty"`
+	CredentialPassword string `json:"CredentialPassword,omitempty"`
+}
+
+// AssetRobotValue is the value a per robot asset holds for one robot
+type AssetRobotValue struct {
+	RobotID   int    `json:"RobotId"`
+	RobotName string `json:"RobotName,omitempty"`
+	AssetValue
+}
+
+// assetURI returns the path of a single asset
+func assetURI(assetID int) string {
+	return AssetsURI + "(" + strconv.Itoa(assetID) + ")"
+}
+
+// ListAssets returns the assets in the client's folder that match the query
+func (c *Client) ListAssets(query ODataQuery, paging Paging) ([]Asset, error) {
+
+	var assets []Asset
+	err := c.NewODataPager(AssetsURI, query, paging).ReadAll(&assets)
+
+	return assets, err
+}
+
+// GetAsset returns a single asset with its per robot values
+func (c *Client) GetAsset(assetID int) (*Asset, error) {
+
+	asset := Asset{}
+	err := c.Get(assetURI(assetID), url.Values{"$expand": {"RobotValues"}}, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
+
+// FindAsset returns the asset with the given name in the client's folder, with
+// its per robot values
+func (c *Client) FindAsset(name string) (*Asset, error) {
+
+	var assets []Asset
+	err := c.GetOData(AssetsURI, ODataQuery{Filter: "Name eq " + ODataString(name)}, &assets)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, errors.New("No asset named '" + name + "' was found in the current folder")
+	}
+
+	return c.GetAsset(assets[0].ID)
+}
+
+// CreateAsset creates an asset in the client's folder
+func (c *Client) CreateAsset(newAsset Asset) (*Asset, error) {
+
+	asset := Asset{}
+	err := c.Post(AssetsURI, newAsset, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
+
+// UpdateAsset replaces an asset's values.  Credential passwords left empty keep
+// the password already stored
+func (c *Client) UpdateAsset(asset Asset) error {
+	return c.Put(assetURI(asset.ID), asset, nil)
+}
+
+// DeleteAsset removes an asset from the client's folder
+func (c *Client) DeleteAsset(assetID int) error {
+	return c.Delete(assetURI(assetID))
+}
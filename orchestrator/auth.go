@@ -0,0 +1,255 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/util"
+)
+
+// OnPremTokenLifetime is how long an on-premise bearer token is treated as valid
+// The on-premise Authenticate API does not return an expiry, so we use the Orchestrator default
+const OnPremTokenLifetime = 30 * time.Minute
+
+// tokenRefreshMargin is how far ahead of expiry a token is proactively refreshed
+const tokenRefreshMargin = 2 * time.Minute
+
+// Token is a bearer token along with its expiry
+// RefreshToken is only populated when the identity provider rotated the refresh token
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	Scope        string
+	Expiry       time.Time
+}
+
+// Used when making authentication calls into a UiPath hosted Orchestrator
+type refreshTokenReq struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Used when making authentication calls into a Orchestrator hosted on-premise
+type onPremAuthReq struct {
+	TenantName string `json:"tenancyName"`
+	UserID     string `json:"usernameOrEmailAddress"`
+	Password   string `json:"password"`
+}
+
+// Return structure for a hosted authentication call
+type oauthTokenResp struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// Return structure for an on-premise authentication call
+type onPremAuthResp struct {
+	AccessToken         string          `json:"result"`
+	TargetURL           string          `json:"targetUrl"`
+	Success             bool            `json:"success"`
+	Error               json.RawMessage `json:"error"`
+	UnAuthorizedRequest bool            `json:"unAuthorizedRequest"`
+	ABP                 bool            `json:"__abp"`
+}
+
+// RefreshTokenGrant exchanges a UiPath Platform refresh token for a new access token
+func RefreshTokenGrant(httpClient *http.Client, authEndpoint string, clientID string, refreshToken string) (*Token, error) {
+
+	reqBody := refreshTokenReq{
+		GrantType:    "refresh_token",
+		ClientID:     clientID,
+		RefreshToken: refreshToken,
+	}
+
+	requestBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return requestOAuthToken(httpClient, authEndpoint, "application/json", bytes.NewReader(requestBody))
+}
+
+// TokenEndpoint returns the OAuth token endpoint of an identity server
+// (e.g. https://cloud.uipath.com/identity_ or https://orchestrator.example.com/identity)
+func TokenEndpoint(identityURL string) string {
+	return strings.TrimRight(identityURL, "/") + "/connect/token"
+}
+
+// ClientCredentialsGrant requests an access token for a confidential external application
+// scope is the space separated list of scopes the application was granted (e.g. "OR.Jobs OR.Queues")
+func ClientCredentialsGrant(httpClient *http.Client, tokenEndpoint string, appID string, appSecret string, scope string) (*Token, error) {
+
+	form := url.Values{}
+	form.Add("grant_type", "client_credentials")
+	form.Add("client_id", appID)
+	form.Add("client_secret", appSecret)
+	form.Add("scope", scope)
+
+	return requestOAuthToken(httpClient, tokenEndpoint, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func requestOAuthToken(httpClient *http.Client, tokenEndpoint string, contentType string, body io.Reader) (*Token, error) {
+
+	oauthResp := oauthTokenResp{}
+	err := postAuth(httpClient, tokenEndpoint, contentType, body, &oauthResp)
+	if err != nil {
+		return nil, err
+	}
+	if oauthResp.AccessToken == "" {
+		return nil, errors.New("Authentication failed: " + oauthResp.Error + " " + oauthResp.ErrorDesc)
+	}
+
+	token := Token{
+		AccessToken:  oauthResp.AccessToken,
+		RefreshToken: oauthResp.RefreshToken,
+		Scope:        oauthResp.Scope,
+		Expiry:       time.Now().Add(time.Second * time.Duration(oauthResp.ExpiresIn)),
+	}
+
+	return &token, nil
+}
+
+// OnPremAuthenticate retrieves a bearer token from an on-premise Orchestrator's
+// /api/Account/Authenticate endpoint using basic credentials
+func OnPremAuthenticate(httpClient *http.Client, authEndpoint string, tenant string, username string, password string) (*Token, error) {
+
+	reqBody := onPremAuthReq{
+		TenantName: tenant,
+		UserID:     username,
+		Password:   password,
+	}
+
+	requestBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	authResp := onPremAuthResp{}
+	err = postAuth(httpClient, authEndpoint, "application/json", bytes.NewReader(requestBody), &authResp)
+	if err != nil {
+		return nil, err
+	}
+	if !authResp.Success || authResp.AccessToken == "" {
+		return nil, errors.New("Authentication failed: " + string(authResp.Error))
+	}
+
+	token := Token{
+		AccessToken: authResp.AccessToken,
+		Expiry:      time.Now().Add(OnPremTokenLifetime),
+	}
+
+	return &token, nil
+}
+
+func postAuth(httpClient *http.Client, authEndpoint string, contentType string, body io.Reader, v interface{}) error {
+
+	util.LogDebug("Sending authentication request")
+	resp, err := httpClient.Post(authEndpoint, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	util.LogDebug("Reading response body")
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 500 {
+		return newAPIError(resp, respBody)
+	}
+
+	// Identity providers report bad credentials in the JSON body, so 4xx
+	// responses are decoded and left to the caller to interpret
+	err = json.Unmarshal(respBody, v)
+	if err != nil && resp.StatusCode > 299 {
+		return newAPIError(resp, respBody)
+	}
+
+	return err
+}
+
+// canRefresh reports whether the config holds enough to re-authenticate without the user
+func (c *Client) canRefresh() bool {
+
+	switch c.config.GetAuthType() {
+	case config.AuthTypeClientCredentials:
+		return c.config.GetIdentityEndpoint() != "" && c.config.GetAppID() != "" && c.config.GetAppSecret() != ""
+	case config.AuthTypeAuthorizationCode:
+		return c.config.GetIdentityEndpoint() != "" && c.config.GetAppID() != "" && c.config.GetRefreshToken() != ""
+	}
+
+	if c.config.GetAuthorizationEndpoint() == "" {
+		return false
+	}
+
+	switch c.config.GetEndpointType() {
+	case config.EndpointTypeHosted:
+		return c.config.GetRefreshToken() != ""
+	case config.EndpointTypeOnPremise:
+		return c.config.GetUIPOUsername() != "" && c.config.GetUIPOPassword() != "" && c.config.GetTenantName() != ""
+	}
+
+	return false
+}
+
+// tokenExpiring reports whether the cached access token is expired or about to expire
+// Tokens cached before expiry tracking was added have no expiry and are left to the 401 path
+func (c *Client) tokenExpiring() bool {
+
+	expiry := c.config.GetAccessTokenExpiry()
+	if expiry.IsZero() {
+		return false
+	}
+
+	return time.Now().Add(tokenRefreshMargin).After(expiry)
+}
+
+// RefreshAccessToken re-runs the cached authentication flow and stores the new token in the config
+func (c *Client) RefreshAccessToken() error {
+
+	if !c.canRefresh() {
+		return ErrCannotRefresh
+	}
+
+	util.LogInfo("Refreshing access token")
+
+	var token *Token
+	var err error
+	if c.config.GetAuthType() == config.AuthTypeClientCredentials {
+		token, err = ClientCredentialsGrant(c.HTTPClient, TokenEndpoint(c.config.GetIdentityEndpoint()), c.config.GetAppID(), c.config.GetAppSecret(), c.config.GetScopes())
+	} else if c.config.GetAuthType() == config.AuthTypeAuthorizationCode {
+		token, err = IdentityRefreshTokenGrant(c.HTTPClient, TokenEndpoint(c.config.GetIdentityEndpoint()), c.config.GetAppID(), c.config.GetRefreshToken())
+	} else if c.config.GetEndpointType() == config.EndpointTypeHosted {
+		token, err = RefreshTokenGrant(c.HTTPClient, c.config.GetAuthorizationEndpoint(), c.config.GetClientID(), c.config.GetRefreshToken())
+	} else {
+		token, err = OnPremAuthenticate(c.HTTPClient, c.config.GetAuthorizationEndpoint(), c.config.GetTenantName(), c.config.GetUIPOUsername(), c.config.GetUIPOPassword())
+	}
+	if err != nil {
+		return err
+	}
+
+	c.config.SetAccessToken(token.AccessToken)
+	c.config.SetAccessTokenExpiry(token.Expiry)
+	if token.RefreshToken != "" {
+		c.config.SetRefreshToken(token.RefreshToken)
+	}
+
+	return nil
+}
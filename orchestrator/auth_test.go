@@ -0,0 +1,485 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/config"
+)
+
+// authServer is an Orchestrator that only accepts one access token, along with the
+// on-premise, hosted and identity server authentication endpoints that issue it
+type authServer struct {
+	mu         sync.Mutex
+	accept     string
+	issue      string
+	authDelay  time.Duration
+	authStatus int
+	authResp   string
+	authReq    map[string]string
+	refreshes  int
+	apiBodies  []string
+}
+
+func (s *authServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/Account/Authenticate", "/oauth/token", "/identity_/connect/token":
+		s.refreshes++
+		s.authReq = map[string]string{}
+		if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+			form, _ := url.ParseQuery(string(body))
+			for key := range form {
+				s.authReq[key] = form.Get(key)
+			}
+		} else {
+			json.Unmarshal(body, &s.authReq)
+		}
+		time.Sleep(s.authDelay)
+
+		if s.authStatus != 0 {
+			w.WriteHeader(s.authStatus)
+			fmt.Fprint(w, s.authResp)
+		} else if r.URL.Path == "/identity_/connect/token" {
+			fmt.Fprintf(w, `{"access_token":%q,"scope":"OR.Jobs OR.Queues","expires_in":3600}`, s.issue)
+		} else if r.URL.Path == "/oauth/token" {
+			fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"rotated","expires_in":3600}`, s.issue)
+		} else {
+			fmt.Fprintf(w, `{"result":%q,"success":true}`, s.issue)
+		}
+		return
+	}
+
+	s.apiBodies = append(s.apiBodies, string(body))
+	if r.Header.Get("Authorization") != "Bearer "+s.accept {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message":"You are not authenticated!"}`)
+		return
+	}
+	fmt.Fprint(w, `{}`)
+}
+
+// newAuthTestClient returns an on-premise client holding a "stale" token that
+//  can be refreshed with the UIPO_USERNAME and UIPO_PASSWORD credentials for a
+//  "fresh" one, which is the only token the server accepts
+func newAuthTestClient(t *testing.T, server *authServer) (*Client, *config.Config) {
+
+	server.accept, server.issue = "fresh", "fresh"
+	client, conf := newTestClient(t, server.ServeHTTP)
+
+	conf.ConfigFile.AccessToken = "stale"
+	conf.ConfigFile.AuthorizationEndpoint = conf.ConfigFile.APIEndpoint + "/api/Account/Authenticate"
+	conf.ConfigFile.TargetedTenant.Name = "Default"
+	conf.ENV.UIPOUsername = "admin"
+	conf.ENV.UIPOPassword = "secret"
+
+	return client, conf
+}
+
+// useHostedRefresh switches the config to a UiPath hosted login with a refresh token
+func useHostedRefresh(conf *config.Config) {
+
+	conf.ConfigFile.EndpointType = config.EndpointTypeHosted
+	conf.ConfigFile.AuthorizationEndpoint = conf.ConfigFile.APIEndpoint + "/oauth/token"
+	conf.ConfigFile.ClientID = "client-1"
+	conf.ConfigFile.RefreshToken = "refresh"
+}
+
+// useClientCredentials switches the config to an external application login
+func useClientCredentials(conf *config.Config) {
+
+	conf.ConfigFile.AuthType = config.AuthTypeClientCredentials
+	conf.ConfigFile.IdentityEndpoint = conf.ConfigFile.APIEndpoint + "/identity_/"
+	conf.ConfigFile.AppID = "app-1"
+	conf.ConfigFile.AppSecret = "cached-secret"
+	conf.ConfigFile.Scopes = "OR.Jobs OR.Queues"
+}
+
+var onPremAuthBody = map[string]string{"tenancyName": "Default", "usernameOrEmailAddress": "admin", "password": "secret"}
+
+var hostedAuthBody = map[string]string{"grant_type": "refresh_token", "client_id": "client-1", "refresh_token": "refresh"}
+
+func TestClientRefreshesBeforeExpiry(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		hosted    bool
+		token     string
+		expiresIn time.Duration
+		refreshes int
+		authReq   map[string]string
+		refresh   string
+	}{
+		{"on-premise token about to expire", false, "stale", time.Minute, 1, onPremAuthBody, ""},
+		{"on-premise token expired", false, "stale", -time.Hour, 1, onPremAuthBody, ""},
+		{"hosted token about to expire", true, "stale", time.Minute, 1, hostedAuthBody, "rotated"},
+		{"token still valid", false, "fresh", time.Hour, 0, nil, ""},
+		{"no expiry recorded", true, "fresh", 0, 0, nil, "refresh"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := &authServer{}
+			client, conf := newAuthTestClient(t, server)
+			if test.hosted {
+				useHostedRefresh(conf)
+			}
+			conf.ConfigFile.AccessToken = test.token
+			conf.ConfigFile.AccessTokenExpiry = time.Time{}
+			if test.expiresIn != 0 {
+				conf.ConfigFile.AccessTokenExpiry = time.Now().Add(test.expiresIn)
+			}
+
+			if err := client.Get("/odata/Robots", nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if server.refreshes != test.refreshes || len(server.apiBodies) != 1 {
+				t.Errorf("refreshes, API requests = %d, %d, want %d, 1", server.refreshes, len(server.apiBodies), test.refreshes)
+			}
+			if fmt.Sprint(server.authReq) != fmt.Sprint(test.authReq) {
+				t.Errorf("authentication request = %v, want %v", server.authReq, test.authReq)
+			}
+			if conf.ConfigFile.AccessToken != "fresh" || conf.ConfigFile.RefreshToken != test.refresh {
+				t.Errorf("access, refresh token = %q, %q, want fresh, %q", conf.ConfigFile.AccessToken, conf.ConfigFile.RefreshToken, test.refresh)
+			}
+			if test.refreshes > 0 && !conf.ConfigFile.AccessTokenExpiry.After(time.Now().Add(tokenRefreshMargin)) {
+				t.Errorf("new expiry %v is already within the refresh margin", conf.ConfigFile.AccessTokenExpiry)
+			}
+		})
+	}
+}
+
+func TestClientRetriesOnceOn401(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		call        func(client *Client) error
+		accept      string
+		cannotRenew bool
+		refreshes   int
+		apiBodies   []string
+		err         string
+	}{
+		{
+			name:      "get",
+			call:      func(client *Client) error { return client.Get("/odata/Robots", nil, nil) },
+			refreshes: 1,
+			apiBodies: []string{"", ""},
+		},
+		{
+			name: "post replays the body",
+			call: func(client *Client) error {
+				return client.Post("/odata/Robots", map[string]string{"Name": "Bot1"}, nil)
+			},
+			refreshes: 1,
+			apiBodies: []string{`{"Name":"Bot1"}`, `{"Name":"Bot1"}`},
+		},
+		{
+			name:      "refreshed token rejected too",
+			call:      func(client *Client) error { return client.Delete("/odata/Robots(1)") },
+			accept:    "other",
+			refreshes: 1,
+			apiBodies: []string{"", ""},
+			err:       "API Request Failed: 401 Unauthorized - You are not authenticated!",
+		},
+		{
+			name:        "nothing to refresh with",
+			call:        func(client *Client) error { return client.Get("/odata/Robots", nil, nil) },
+			cannotRenew: true,
+			apiBodies:   []string{""},
+			err:         "API Request Failed: 401 Unauthorized - You are not authenticated!",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := &authServer{}
+			client, conf := newAuthTestClient(t, server)
+			if test.accept != "" {
+				server.accept = test.accept
+			}
+			if test.cannotRenew {
+				conf.ENV.UIPOPassword = ""
+			}
+
+			err := test.call(client)
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if server.refreshes != test.refreshes {
+				t.Errorf("refreshes = %d, want %d", server.refreshes, test.refreshes)
+			}
+			if strings.Join(server.apiBodies, "|") != strings.Join(test.apiBodies, "|") || len(server.apiBodies) != len(test.apiBodies) {
+				t.Errorf("API request bodies = %q, want %q", server.apiBodies, test.apiBodies)
+			}
+		})
+	}
+}
+
+func TestClientDoesNotReplayStreamedBody(t *testing.T) {
+
+	server := &authServer{}
+	client, _ := newAuthTestClient(t, server)
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		fmt.Fprint(writer, "package contents")
+		writer.Close()
+	}()
+
+	req, err := client.NewRequest("POST", "/odata/Processes/UiPath.Server.Configuration.OData.UploadPackage", nil, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatal("a streamed request body can be replayed")
+	}
+
+	err = client.Do(req, nil)
+	if !IsStatus(err, http.StatusUnauthorized) {
+		t.Errorf("err = %v, want the 401 returned as is", err)
+	}
+	if len(server.apiBodies) != 1 || server.apiBodies[0] != "package contents" || server.refreshes != 0 {
+		t.Errorf("API request bodies, refreshes = %q, %d, want one upload and no refresh", server.apiBodies, server.refreshes)
+	}
+}
+
+func TestClientSharesTokenLock(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+	}{
+		{"token about to expire", time.Minute},
+		{"token rejected", time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := &authServer{authDelay: 20 * time.Millisecond}
+			client, conf := newAuthTestClient(t, server)
+			conf.ConfigFile.AccessTokenExpiry = time.Now().Add(test.expiresIn)
+
+			var wg sync.WaitGroup
+			errs := make([]error, 8)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = client.InFolder(i+1).Get("/odata/Robots", nil, nil)
+				}(i)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if server.refreshes != 1 {
+				t.Errorf("refreshes = %d, want the folder clients to share one refresh", server.refreshes)
+			}
+		})
+	}
+}
+
+func TestRefreshStaleTokenSkipsReplacedToken(t *testing.T) {
+
+	server := &authServer{}
+	client, conf := newAuthTestClient(t, server)
+	conf.ConfigFile.AccessToken = "fresh"
+
+	if err := client.refreshStaleToken("stale"); err != nil {
+		t.Fatal(err)
+	}
+	if server.refreshes != 0 {
+		t.Errorf("refreshes = %d, want the replaced token left alone", server.refreshes)
+	}
+}
+
+func TestRefreshAccessTokenErrors(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		hosted     bool
+		setup      func(conf *config.Config)
+		authStatus int
+		authResp   string
+		err        string
+	}{
+		{
+			name:  "no authorization endpoint",
+			setup: func(conf *config.Config) { conf.ConfigFile.AuthorizationEndpoint = "" },
+			err:   ErrCannotRefresh.Error(),
+		},
+		{
+			name:  "on-premise without a password",
+			setup: func(conf *config.Config) { conf.ENV.UIPOPassword = "" },
+			err:   ErrCannotRefresh.Error(),
+		},
+		{
+			name:  "on-premise without a tenant",
+			setup: func(conf *config.Config) { conf.ConfigFile.TargetedTenant.Name = "" },
+			err:   ErrCannotRefresh.Error(),
+		},
+		{
+			name:   "hosted without a refresh token",
+			hosted: true,
+			setup:  func(conf *config.Config) { conf.ConfigFile.RefreshToken = "" },
+			err:    ErrCannotRefresh.Error(),
+		},
+		{
+			name: "client credentials without a secret",
+			setup: func(conf *config.Config) {
+				useClientCredentials(conf)
+				conf.ConfigFile.AppSecret = ""
+			},
+			err: ErrCannotRefresh.Error(),
+		},
+		{
+			name: "client credentials without an identity server",
+			setup: func(conf *config.Config) {
+				useClientCredentials(conf)
+				conf.ConfigFile.IdentityEndpoint = ""
+			},
+			err: ErrCannotRefresh.Error(),
+		},
+		{
+			name:       "on-premise credentials rejected",
+			authStatus: http.StatusBadRequest,
+			authResp:   `{"success":false,"error":{"message":"Invalid user name or password"}}`,
+			err:        `Authentication failed: {"message":"Invalid user name or password"}`,
+		},
+		{
+			name:       "hosted refresh token revoked",
+			hosted:     true,
+			authStatus: http.StatusBadRequest,
+			authResp:   `{"error":"invalid_grant","error_description":"Refresh token revoked"}`,
+			err:        "Authentication failed: invalid_grant Refresh token revoked",
+		},
+		{
+			name:       "client secret rejected",
+			setup:      useClientCredentials,
+			authStatus: http.StatusBadRequest,
+			authResp:   `{"error":"invalid_client","error_description":"Invalid client secret"}`,
+			err:        "Authentication failed: invalid_client Invalid client secret",
+		},
+		{
+			name:       "authentication server failing",
+			authStatus: http.StatusServiceUnavailable,
+			authResp:   `Service Unavailable`,
+			err:        "API Request Failed: 503 Service Unavailable",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := &authServer{}
+			client, conf := newAuthTestClient(t, server)
+			server.authStatus, server.authResp = test.authStatus, test.authResp
+			if test.hosted {
+				useHostedRefresh(conf)
+			}
+			if test.setup != nil {
+				test.setup(conf)
+			}
+
+			err := client.RefreshAccessToken()
+			if err == nil || err.Error() != test.err {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if conf.ConfigFile.AccessToken != "stale" {
+				t.Errorf("access token = %q, want the stale token kept", conf.ConfigFile.AccessToken)
+			}
+		})
+	}
+}
+
+func TestClientRefreshesWithClientCredentials(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		envSecret string
+		secret    string
+		apiCalls  int
+	}{
+		{"token about to expire", time.Minute, "", "cached-secret", 1},
+		{"token rejected", time.Hour, "", "cached-secret", 2},
+		{"secret from UIPO_APP_SECRET", time.Minute, "env-secret", "env-secret", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := &authServer{}
+			client, conf := newAuthTestClient(t, server)
+			useClientCredentials(conf)
+			conf.ENV.UIPOAppSecret = test.envSecret
+			conf.ConfigFile.AccessTokenExpiry = time.Now().Add(test.expiresIn)
+
+			if err := client.Get("/odata/Robots", nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "app-1",
+				"client_secret": test.secret,
+				"scope":         "OR.Jobs OR.Queues",
+			}
+			if fmt.Sprint(server.authReq) != fmt.Sprint(want) {
+				t.Errorf("token request = %v, want %v", server.authReq, want)
+			}
+			if server.refreshes != 1 || len(server.apiBodies) != test.apiCalls {
+				t.Errorf("refreshes, API requests = %d, %d, want 1, %d", server.refreshes, len(server.apiBodies), test.apiCalls)
+			}
+			if conf.ConfigFile.AccessToken != "fresh" || conf.ConfigFile.RefreshToken != "" {
+				t.Errorf("access, refresh token = %q, %q, want fresh and none", conf.ConfigFile.AccessToken, conf.ConfigFile.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+
+	server := &authServer{issue: "app-token"}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	token, err := ClientCredentialsGrant(http.DefaultClient, TokenEndpoint(httpServer.URL+"/identity_/"), "app-1", "s3cret", "OR.Jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"grant_type": "client_credentials", "client_id": "app-1", "client_secret": "s3cret", "scope": "OR.Jobs"}
+	if fmt.Sprint(server.authReq) != fmt.Sprint(want) {
+		t.Errorf("token request = %v, want %v", server.authReq, want)
+	}
+	if token.AccessToken != "app-token" || token.Scope != "OR.Jobs OR.Queues" || token.RefreshToken != "" {
+		t.Errorf("token = %+v", token)
+	}
+	if expiresIn := time.Until(token.Expiry); expiresIn < 59*time.Minute || expiresIn > time.Hour {
+		t.Errorf("token expires in %v, want an hour", expiresIn)
+	}
+}
@@ -0,0 +1,346 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcsimms/uipo/config"
+	"github.com/bcsimms/uipo/util"
+)
+
+// Config is the subset of the UIPO configuration needed to talk to an Orchestrator
+// commands.Config and *config.Config both satisfy it, so the client can be used from
+// the CLI or imported as a library by other tools
+type Config interface {
+	GetAPIEndpoint() string
+	GetEndpointType() string
+	GetAccountLogicalName() string
+	GetServiceLogicalName() string
+	GetAccessToken() string
+	SetAccessToken(string)
+	GetAccessTokenExpiry() time.Time
+	SetAccessTokenExpiry(time.Time)
+	GetFolderID() int
+	SetAPIVersion(string)
+
+	// Used to re-authenticate when the access token expires
+	GetAuthType() string
+	GetAuthorizationEndpoint() string
+	GetIdentityEndpoint() string
+	GetAppID() string
+	GetAppSecret() string
+	GetScopes() string
+	GetClientID() string
+	GetRefreshToken() string
+	SetRefreshToken(string)
+	GetTenantName() string
+	GetUIPOUsername() string
+	GetUIPOPassword() string
+}
+
+// Client sends requests to a single Orchestrator instance
+// It owns base URL construction, the UiPath request headers, JSON decoding and
+// turning failed responses into *APIError values
+type Client struct {
+	HTTPClient *http.Client
+
+	config   Config
+	baseURL  string
+	folderID int
+
+	// tokenLock is shared with the copies made by InFolder so that concurrent
+	// requests refresh the access token once
+	tokenLock *sync.Mutex
+}
+
+// secretUnlocker is implemented by configs, such as *config.Config, that keep the
+// tokens in a secret store which is only opened when they are needed
+type secretUnlocker interface {
+	UnlockSecrets() error
+}
+
+// NewClient creates a client for the Orchestrator described by the given config
+// Requests are scoped to the config's default folder; use InFolder to target another
+func NewClient(conf Config) (*Client, error) {
+
+	baseURL, err := BaseURL(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if unlocker, ok := conf.(secretUnlocker); ok {
+		if err = unlocker.UnlockSecrets(); err != nil {
+			return nil, err
+		}
+	}
+
+	client := Client{
+		HTTPClient: &http.Client{},
+		config:     conf,
+		baseURL:    baseURL,
+		folderID:   conf.GetFolderID(),
+		tokenLock:  &sync.Mutex{},
+	}
+
+	return &client, nil
+}
+
+// BaseURL returns the root URL that API paths such as /odata/Robots are appended to
+// Hosted (UiPath Platform) Orchestrators are addressed by account and service logical
+// name; on-premise Orchestrators use the API endpoint as-is
+func BaseURL(conf Config) (string, error) {
+
+	endpoint := strings.TrimRight(conf.GetAPIEndpoint(), "/")
+	if endpoint == "" {
+		return "", ErrMissingEndpoint
+	}
+
+	switch conf.GetEndpointType() {
+	case config.EndpointTypeHosted:
+		return endpoint + "/" + conf.GetAccountLogicalName() + "/" + conf.GetServiceLogicalName(), nil
+	case config.EndpointTypeOnPremise:
+		return endpoint, nil
+	}
+
+	return "", ErrInvalidEndpointType
+}
+
+// InFolder returns a copy of the client that sends requests to the given folder
+// A folder ID of 0 omits the folder header, which targets tenant level resources
+func (c *Client) InFolder(folderID int) *Client {
+	folderClient := *c
+	folderClient.folderID = folderID
+	return &folderClient
+}
+
+// FolderID returns the folder the client's requests are scoped to
+func (c *Client) FolderID() int {
+	return c.folderID
+}
+
+// NewRequest builds a request for the given API path (e.g. /odata/Robots) with the
+// tenant and folder headers already applied.  Authorization is added by Do
+// Absolute URLs, such as an @odata.nextLink, are used as-is
+func (c *Client) NewRequest(method string, uri string, query url.Values, body io.Reader) (*http.Request, error) {
+
+	endpoint := c.baseURL + uri
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		endpoint = uri
+	}
+	if len(query) > 0 {
+		endpoint = endpoint + "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-UIPATH-TenantName", c.config.GetServiceLogicalName())
+	if c.folderID != 0 {
+		req.Header.Add("X-UIPATH-OrganizationUnitId", strconv.Itoa(c.folderID))
+	}
+	req.Header.Add("Accept", "application/json")
+
+	return req, nil
+}
+
+// Do sends the request and decodes a successful JSON response into v
+// v may be nil when the response body is not needed.  Non 2xx responses are
+// returned as *APIError
+// Tokens close to expiry are refreshed before sending, and a 401 response triggers
+// a single refresh and retry when the request body can be replayed
+func (c *Client) Do(req *http.Request, v interface{}) error {
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	util.LogTrace("Response was: \n" + string(body))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp, body)
+	}
+
+	if v == nil || len(body) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// Download sends the request and copies a successful response body to w as it
+// arrives, for files too large to hold in memory.  Returns the bytes written
+func (c *Client) Download(req *http.Request, w io.Writer) (int64, error) {
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		return 0, newAPIError(resp, body)
+	}
+
+	return io.Copy(w, resp.Body)
+}
+
+// do sends the request, refreshing the access token as needed, and returns the
+// response for the caller to read and close
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+
+	c.tokenLock.Lock()
+	if c.tokenExpiring() && c.canRefresh() {
+		err := c.RefreshAccessToken()
+		if err != nil {
+			c.tokenLock.Unlock()
+			return nil, err
+		}
+	}
+	c.tokenLock.Unlock()
+
+	resp, token, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.canRefresh() && (req.Body == nil || req.GetBody != nil) {
+		resp.Body.Close()
+		util.LogDebug("Received 401, retrying with a refreshed token")
+
+		err = c.refreshStaleToken(token)
+		if err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		resp, _, err = c.send(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if apiVersion := resp.Header.Get("Api-Supported-Versions"); apiVersion != "" {
+		c.tokenLock.Lock()
+		c.config.SetAPIVersion(apiVersion)
+		c.tokenLock.Unlock()
+	}
+
+	return resp, nil
+}
+
+// send adds the current access token to the request and sends it, returning the
+// token that was used
+func (c *Client) send(req *http.Request) (*http.Response, string, error) {
+
+	c.tokenLock.Lock()
+	token := c.config.GetAccessToken()
+	c.tokenLock.Unlock()
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	util.LogDebug("Sending " + req.Method + " " + req.URL.String())
+	resp, err := c.HTTPClient.Do(req)
+
+	return resp, token, err
+}
+
+// refreshStaleToken refreshes the access token after a 401, unless another
+// request has already replaced the token that was rejected
+func (c *Client) refreshStaleToken(rejected string) error {
+
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if c.config.GetAccessToken() != rejected {
+		return nil
+	}
+
+	return c.RefreshAccessToken()
+}
+
+// Get sends a GET request and decodes the JSON response into v
+func (c *Client) Get(uri string, query url.Values, v interface{}) error {
+
+	req, err := c.NewRequest("GET", uri, query, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.Do(req, v)
+}
+
+// Post sends reqBody as JSON and decodes the JSON response into v
+func (c *Client) Post(uri string, reqBody interface{}, v interface{}) error {
+	return c.sendJSON("POST", uri, reqBody, v)
+}
+
+// Put sends reqBody as JSON and decodes the JSON response into v
+func (c *Client) Put(uri string, reqBody interface{}, v interface{}) error {
+	return c.sendJSON("PUT", uri, reqBody, v)
+}
+
+// Delete sends a DELETE request for the given API path
+func (c *Client) Delete(uri string) error {
+
+	req, err := c.NewRequest("DELETE", uri, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.Do(req, nil)
+}
+
+func (c *Client) sendJSON(method string, uri string, reqBody interface{}, v interface{}) error {
+
+	var body io.Reader
+	if reqBody != nil {
+		rawBody, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(rawBody)
+	}
+
+	req, err := c.NewRequest(method, uri, nil, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.Do(req, v)
+}
+
+// ODataResponse is the envelope Orchestrator wraps OData collections in
+type ODataResponse struct {
+	ODataContext string          `json:"@odata.context"`
+	ODataCount   int             `json:"@odata.count"`
+	NextLink     string          `json:"@odata.nextLink"`
+	Value        json.RawMessage `json:"value"`
+}
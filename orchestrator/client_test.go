@@ -0,0 +1,241 @@
+package orchestrator
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bcsimms/uipo/config"
+)
+
+func TestBaseURL(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		endpoint     string
+		endpointType string
+		want         string
+		err          error
+	}{
+		{"hosted", "https://cloud.uipath.com/", config.EndpointTypeHosted, "https://cloud.uipath.com/acc/ten", nil},
+		{"on-premise", "https://orch.example.com/", config.EndpointTypeOnPremise, "https://orch.example.com", nil},
+		{"no endpoint", "", config.EndpointTypeHosted, "", ErrMissingEndpoint},
+		{"unknown endpoint type", "https://orch.example.com", "", "", ErrInvalidEndpointType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := &config.Config{}
+			conf.ConfigFile.APIEndpoint = test.endpoint
+			conf.ConfigFile.EndpointType = test.endpointType
+			conf.ConfigFile.AccountLogicalName = "acc"
+			conf.ConfigFile.ServiceLogicalName = "ten"
+
+			got, err := BaseURL(conf)
+			if got != test.want || err != test.err {
+				t.Errorf("BaseURL = %q, %v, want %q, %v", got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+
+	var headers http.Header
+	client, conf := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Header().Set("Api-Supported-Versions", "16.0")
+		w.Write([]byte(`{}`))
+	})
+
+	tests := []struct {
+		name   string
+		client *Client
+		folder string
+	}{
+		{"default folder", client, "1"},
+		{"another folder", client.InFolder(42), "42"},
+		{"tenant level", client.InFolder(0), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.client.Get("/odata/Robots", nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"Authorization":               "Bearer token",
+				"X-Uipath-Tenantname":         "DefaultTenant",
+				"X-Uipath-Organizationunitid": test.folder,
+				"Accept":                      "application/json",
+			}
+			for key, value := range want {
+				if got := headers.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+
+	if client.FolderID() != 1 || conf.ConfigFile.APIVersion != "16.0" {
+		t.Errorf("FolderID, APIVersion = %d, %q, want 1, 16.0", client.FolderID(), conf.ConfigFile.APIVersion)
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+
+	type robot struct {
+		ID   int    `json:"Id"`
+		Name string `json:"Name"`
+	}
+
+	var method, uri, contentType, body string
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		method, uri, contentType = r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type")
+		rawBody := make([]byte, r.ContentLength)
+		r.Body.Read(rawBody)
+		body = string(rawBody)
+
+		switch r.URL.Path {
+		case "/odata/Robots(404)":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Robot does not exist.","errorCode":1002}`))
+		case "/odata/Gateway":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>Bad gateway</html>`))
+		case "/odata/Robots(1)":
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Write([]byte(`{"Id":1,"Name":"Bot1"}`))
+		default:
+			w.Write([]byte(`{"Id":2,"Name":"Bot2"}`))
+		}
+	})
+
+	tests := []struct {
+		name        string
+		call        func() (robot, error)
+		method      string
+		uri         string
+		contentType string
+		body        string
+		want        robot
+		err         string
+	}{
+		{
+			name: "get decodes the response",
+			call: func() (robot, error) {
+				var got robot
+				err := client.Get("/odata/Robots(1)", map[string][]string{"$select": {"Id,Name"}}, &got)
+				return got, err
+			},
+			method: "GET",
+			uri:    "/odata/Robots(1)?%24select=Id%2CName",
+			want:   robot{ID: 1, Name: "Bot1"},
+		},
+		{
+			name: "post sends JSON",
+			call: func() (robot, error) {
+				var got robot
+				err := client.Post("/odata/Robots", robot{Name: "Bot2"}, &got)
+				return got, err
+			},
+			method:      "POST",
+			uri:         "/odata/Robots",
+			contentType: "application/json",
+			body:        `{"Id":0,"Name":"Bot2"}`,
+			want:        robot{ID: 2, Name: "Bot2"},
+		},
+		{
+			name: "put sends JSON",
+			call: func() (robot, error) {
+				return robot{}, client.Put("/odata/Robots(2)", robot{ID: 2, Name: "Renamed"}, nil)
+			},
+			method:      "PUT",
+			uri:         "/odata/Robots(2)",
+			contentType: "application/json",
+			body:        `{"Id":2,"Name":"Renamed"}`,
+		},
+		{
+			name:   "delete with no content",
+			call:   func() (robot, error) { return robot{}, client.Delete("/odata/Robots(1)") },
+			method: "DELETE",
+			uri:    "/odata/Robots(1)",
+		},
+		{
+			name:   "errors carry the Orchestrator message",
+			call:   func() (robot, error) { return robot{}, client.Get("/odata/Robots(404)", nil, nil) },
+			method: "GET",
+			uri:    "/odata/Robots(404)",
+			err:    "API Request Failed: 404 Not Found - Robot does not exist. (Error Code: 1002)",
+		},
+		{
+			name:   "errors without a JSON body",
+			call:   func() (robot, error) { return robot{}, client.Get("/odata/Gateway", nil, nil) },
+			method: "GET",
+			uri:    "/odata/Gateway",
+			err:    "API Request Failed: 502 Bad Gateway",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.call()
+			if got != test.want {
+				t.Errorf("response = %+v, want %+v", got, test.want)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("err = %v, want %q", err, test.err)
+			}
+			if method != test.method || uri != test.uri || contentType != test.contentType || body != test.body {
+				t.Errorf("request = %s %s (%q) %s, want %s %s (%q) %s", method, uri, contentType, body, test.method, test.uri, test.contentType, test.body)
+			}
+		})
+	}
+}
+
+func TestIsStatus(t *testing.T) {
+
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	err := client.Get("/odata/Robots", nil, nil)
+	if !IsStatus(err, http.StatusConflict) || IsStatus(err, http.StatusNotFound) || IsStatus(nil, http.StatusConflict) {
+		t.Errorf("IsStatus did not match %v", err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTemporary(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"service unavailable", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"throttled", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"gateway timeout", &APIError{StatusCode: http.StatusGatewayTimeout}, true},
+		{"not found", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"network error", &url.Error{Op: "Get", URL: "https://orch.example.com", Err: timeoutError{}}, true},
+		{"other error", errors.New("bad response"), false},
+		{"no error", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsTemporary(test.err); got != test.want {
+				t.Errorf("IsTemporary(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ErrMissingEndpoint is returned when no API endpoint has been configured
+var ErrMissingEndpoint = errors.New("An API end point is required and a value was not found in the cached config")
+
+// ErrInvalidEndpointType is returned when the cached endpoint type is neither hosted nor on-premise
+var ErrInvalidEndpointType = errors.New("Invalid Endpoint Type in cached config.  Reauthenticate to reset")
+
+// ErrCannotRefresh is returned when the cached config does not hold the details needed to re-authenticate
+var ErrCannotRefresh = errors.New("Access token has expired and cannot be refreshed automatically.  Run the auth command to re-authenticate")
+
+// APIError is returned for any response outside of the 2xx range
+// Message and ErrorCode are populated from the Orchestrator error body when one is present
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	ErrorCode  int
+	Body       []byte
+}
+
+type errorResp struct {
+	Message   string `json:"message"`
+	ErrorCode int    `json:"errorCode"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+
+	apiErr := APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+	}
+
+	// Not every failure carries a JSON body (e.g. gateway errors), so a decode
+	// failure just leaves Message empty
+	errResp := errorResp{}
+	if json.Unmarshal(body, &errResp) == nil {
+		apiErr.Message = errResp.Message
+		apiErr.ErrorCode = errResp.ErrorCode
+	}
+
+	return &apiErr
+}
+
+func (e *APIError) Error() string {
+
+	errStr := "API Request Failed: " + e.Status
+	if e.Message != "" {
+		errStr = errStr + " - " + e.Message
+	}
+	if e.ErrorCode != 0 {
+		errStr = errStr + " (Error Code: " + strconv.Itoa(e.ErrorCode) + ")"
+	}
+
+	return errStr
+}
+
+// IsStatus reports whether err is an *APIError with the given HTTP status code
+func IsStatus(err error, statusCode int) bool {
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == statusCode
+	}
+
+	return false
+}
+
+// IsTemporary reports whether a request failed in a way that may succeed when it
+// is sent again: a network error, throttling, or an unavailable server or gateway
+func IsTemporary(err error) bool {
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
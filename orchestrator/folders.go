@@ -0,0 +1,91 @@
+package orchestrator
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FoldersURI is the OData collection of folders in the current tenant
+const FoldersURI string = "/odata/Folders"
+
+const (
+	foldersForUserURI    string = "/api/FoldersNavigation/GetFoldersForCurrentUser"
+	allFoldersForUserURI string = "/api/FoldersNavigation/GetAllFoldersForCurrentUser"
+)
+
+// Folder is the representation of a Folder returned by the Folders and FoldersNavigation APIs
+type Folder struct {
+	IsSelectable       bool   `json:"IsSelectable"`
+	HasChildren        bool   `json:"HasChildren"`
+	Level              int    `json:"Level"`
+	DisplayName        string `json:"DisplayName"`
+	FullyQualifiedName string `json:"FullyQualifiedName"`
+	Description        string `json:"Description"`
+	ProvisionType      string `json:"ProvisionType"`
+	PermissionModel    string `json:"PermissionModel"`
+	ParentID           int    `json:"ParentId"`
+	ID                 int    `json:"Id"`
+}
+
+type foldersPage struct {
+	PageItems []Folder `json:"PageItems"`
+	Count     int      `json:"Count"`
+}
+
+// SearchFolders returns a page of the current user's folders matching searchText
+func (c *Client) SearchFolders(searchText string, skip int, take int) ([]Folder, error) {
+
+	query := url.Values{}
+	query.Add("searchText", searchText)
+	query.Add("skip", strconv.Itoa(skip))
+	query.Add("take", strconv.Itoa(take))
+
+	page := foldersPage{}
+	err := c.InFolder(0).Get(foldersForUserURI, query, &page)
+
+	return page.PageItems, err
+}
+
+// ListAllFolders returns every folder the current user has access to
+func (c *Client) ListAllFolders() ([]Folder, error) {
+
+	var folders []Folder
+	err := c.InFolder(0).Get(allFoldersForUserURI, nil, &folders)
+
+	return folders, err
+}
+
+// NewFoldersPager returns a pager over the folders in the tenant that match the query
+func (c *Client) NewFoldersPager(query ODataQuery, paging Paging) *ODataPager {
+	return c.InFolder(0).NewODataPager(FoldersURI, query, paging)
+}
+
+// ListFolders returns the folders in the tenant that match the query
+func (c *Client) ListFolders(query ODataQuery, paging Paging) ([]Folder, error) {
+
+	var folders []Folder
+	err := c.NewFoldersPager(query, paging).ReadAll(&folders)
+
+	return folders, err
+}
+
+// FindFolder returns the folder with the given fully qualified name (e.g.
+// "Finance/Invoices") or ID from the folders the current user has access to
+func (c *Client) FindFolder(nameOrID string) (*Folder, error) {
+
+	folders, err := c.ListAllFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	folderID, idErr := strconv.Atoi(nameOrID)
+	for _, folder := range folders {
+		if (idErr == nil && folder.ID == folderID) || strings.EqualFold(folder.FullyQualifiedName, nameOrID) {
+			return &folder, nil
+		}
+	}
+
+	return nil, errors.New("Folder '" + nameOrID + "' was not found")
+}
@@ -0,0 +1,263 @@
+package orchestrator
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcsimms/uipo/util"
+)
+
+const (
+	// JobsURI is the OData collection of jobs in the client's folder
+	JobsURI string = "/odata/Jobs"
+
+	// StartJobsURI is the action used to start one or more jobs for a process
+	StartJobsURI string = "/odata/Jobs/UiPath.Server.Configuration.OData.StartJobs"
+
+	// StopJobsURI is the action used to stop or kill a set of jobs
+	StopJobsURI string = "/odata/Jobs/UiPath.Server.Configuration.OData.StopJobs"
+)
+
+// Job states reported by Orchestrator
+const (
+	JobStatePending     string = "Pending"
+	JobStateRunning     string = "Running"
+	JobStateStopping    string = "Stopping"
+	JobStateTerminating string = "Terminating"
+	JobStateFaulted     string = "Faulted"
+	JobStateSuccessful  string = "Successful"
+	JobStateStopped     string = "Stopped"
+	JobStateSuspended   string = "Suspended"
+	JobStateResumed     string = "Resumed"
+)
+
+// Strategies used to decide which robots run the jobs being started
+const (
+	StartStrategyAll             string = "All"
+	StartStrategySpecific        string = "Specific"
+	StartStrategyRobotCount      string = "RobotCount"
+	StartStrategyJobsCount       string = "JobsCount"
+	StartStrategyModernJobsCount string = "ModernJobsCount"
+)
+
+// Strategies used to stop a running job
+const (
+	// StopStrategySoftStop asks the process to stop at its next stop checkpoint
+	StopStrategySoftStop string = "SoftStop"
+	// StopStrategyKill terminates the process immediately
+	StopStrategyKill string = "Kill"
+)
+
+// Intervals used when polling jobs.  The interval grows by half after every poll
+var (
+	JobPollInterval    = 2 * time.Second
+	JobPollMaxInterval = 30 * time.Second
+)
+
+// jobLookupSize is the number of jobs polled by ID in each request.  Each ID adds
+// an "or" condition to the filter, and Orchestrator rejects filters with more
+// than about 100 OData nodes
+const jobLookupSize int = 20
+
+// jobPollRetries is the number of polls in a row that may fail with a temporary
+// error before WaitForJobs gives up
+const jobPollRetries int = 5
+
+// ErrWaitTimeout is returned by WaitForJobs when jobs are still running at the timeout
+var ErrWaitTimeout = errors.New("Timed out waiting for jobs to finish")
+
+// JobFinished reports whether a job in the given state has stopped running
+func JobFinished(state string) bool {
+	return state == JobStateSuccessful || state == JobStateFaulted || state == JobStateStopped
+}
+
+// Job is the representation of a Job in UiPath Orchestrator
+type Job struct {
+	ID                 int    `json:"Id"`
+	Key                string `json:"Key"`
+	State              string `json:"State"`
+	ReleaseName        string `json:"ReleaseName"`
+	HostMachineName    string `json:"HostMachineName"`
+	Source             string `json:"Source"`
+	SourceType         string `json:"SourceType"`
+	Type               string `json:"Type"`
+	CreationTime       string `json:"CreationTime"`
+	StartTime          string `json:"StartTime"`
+	EndTime            string `json:"EndTime"`
+	Info               string `json:"Info"`
+	InputArguments     string `json:"InputArguments"`
+	OutputArguments    string `json:"OutputArguments"`
+	BatchExecutionKey  string `json:"BatchExecutionKey"`
+	StopStrategy       string `json:"StopStrategy"`
+	OrganizationUnitID int    `json:"OrganizationUnitId"`
+	Robot              *Robot `json:"Robot,omitempty"`
+}
+
+// StartInfo describes the jobs to start
+// InputArguments is a JSON object, serialized as a string, holding the values of
+// the process's input arguments
+type StartInfo struct {
+	ReleaseKey     string `json:"ReleaseKey"`
+	Strategy       string `json:"Strategy"`
+	RobotIds       []int  `json:"RobotIds,omitempty"`
+	JobsCount      int    `json:"JobsCount,omitempty"`
+	InputArguments string `json:"InputArguments,omitempty"`
+}
+
+type startJobsReq struct {
+	StartInfo StartInfo `json:"startInfo"`
+}
+
+type stopJobsReq struct {
+	Strategy string `json:"strategy"`
+	JobIds   []int  `json:"jobIds"`
+}
+
+// ListJobs returns the jobs in the client's folder that match the query
+func (c *Client) ListJobs(query ODataQuery, paging Paging) ([]Job, error) {
+
+	var jobs []Job
+	err := c.NewODataPager(JobsURI, query, paging).ReadAll(&jobs)
+
+	return jobs, err
+}
+
+// GetJob returns a single job, including its input and output arguments and the
+// robot that ran it
+func (c *Client) GetJob(jobID int) (*Job, error) {
+
+	job := Job{}
+	err := c.Get(JobsURI+"("+strconv.Itoa(jobID)+")", ODataQuery{Expand: "Robot"}.Values(), &job)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// StartJobs starts jobs for the process release described by startInfo and
+// returns the jobs that were created
+func (c *Client) StartJobs(startInfo StartInfo) ([]Job, error) {
+
+	odataResp := ODataResponse{}
+	err := c.Post(StartJobsURI, startJobsReq{StartInfo: startInfo}, &odataResp)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job
+	err = decodeODataValue(odataResp, &jobs)
+
+	return jobs, err
+}
+
+// StopJobs stops the given jobs using StopStrategySoftStop or StopStrategyKill
+func (c *Client) StopJobs(jobIDs []int, strategy string) error {
+	return c.Post(StopJobsURI, stopJobsReq{Strategy: strategy, JobIds: jobIDs}, nil)
+}
+
+// WaitForJobs polls the given jobs until every one of them has finished or the
+// timeout passes, calling onChange (when not nil) each time a job changes state
+// Polls that fail with a temporary error are retried at the next interval.  The
+// latest known state of every job is returned, along with ErrWaitTimeout if any
+// job was still running
+func (c *Client) WaitForJobs(jobIDs []int, timeout time.Duration, onChange func(Job)) ([]Job, error) {
+
+	var jobs []Job
+	states := map[int]string{}
+	failures := 0
+	deadline := time.Now().Add(timeout)
+	interval := JobPollInterval
+	for {
+		polled, err := c.pollJobs(jobIDs)
+		if err != nil {
+			failures++
+			if !IsTemporary(err) || failures > jobPollRetries {
+				return nil, err
+			}
+			util.LogDebug("Polling jobs failed, retrying: " + err.Error())
+		} else {
+			failures = 0
+			if len(states) == 0 && len(polled) < len(jobIDs) {
+				return nil, errors.New("Some jobs were not found in the current folder: " + missingJobs(jobIDs, polled))
+			}
+			jobs = polled
+
+			finished := len(jobs) == len(jobIDs)
+			for _, job := range jobs {
+				if states[job.ID] != job.State {
+					states[job.ID] = job.State
+					if onChange != nil {
+						onChange(job)
+					}
+				}
+				if !JobFinished(job.State) {
+					finished = false
+				}
+			}
+			if finished {
+				return jobs, nil
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if jobs == nil {
+				return nil, err
+			}
+			return jobs, ErrWaitTimeout
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		time.Sleep(interval)
+
+		interval = interval + interval/2
+		if interval > JobPollMaxInterval {
+			interval = JobPollMaxInterval
+		}
+	}
+}
+
+// pollJobs returns the current state of the jobs, looked up jobLookupSize at a time
+func (c *Client) pollJobs(jobIDs []int) ([]Job, error) {
+
+	var jobs []Job
+	for start := 0; start < len(jobIDs); start += jobLookupSize {
+		end := start + jobLookupSize
+		if end > len(jobIDs) {
+			end = len(jobIDs)
+		}
+
+		conditions := make([]string, end-start)
+		for i, jobID := range jobIDs[start:end] {
+			conditions[i] = "Id eq " + strconv.Itoa(jobID)
+		}
+		batch, err := c.ListJobs(ODataQuery{Filter: strings.Join(conditions, " or ")}, Paging{All: true, PageSize: jobLookupSize})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, batch...)
+	}
+
+	return jobs, nil
+}
+
+func missingJobs(jobIDs []int, jobs []Job) string {
+
+	found := map[int]bool{}
+	for _, job := range jobs {
+		found[job.ID] = true
+	}
+
+	var missing []string
+	for _, jobID := range jobIDs {
+		if !found[jobID] {
+			missing = append(missing, strconv.Itoa(jobID))
+		}
+	}
+
+	return strings.Join(missing, ", ")
+}
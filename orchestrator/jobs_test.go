@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jobServer serves jobs looked up with an "Id eq" filter.  Every job is Running
+//  on the first poll and Successful after that, and failures holds the statuses
+//  returned, in order, before polls succeed
+type jobServer struct {
+	t        *testing.T
+	mu       sync.Mutex
+	polls    map[int]int
+	failures []int
+	requests int
+}
+
+func (s *jobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if len(s.failures) > 0 {
+		w.WriteHeader(s.failures[0])
+		s.failures = s.failures[1:]
+		return
+	}
+	if r.URL.Query().Get("$skip") != "" {
+		w.Write([]byte(`{"value":[]}`))
+		return
+	}
+
+	conditions := strings.Split(r.URL.Query().Get("$filter"), " or ")
+	if len(conditions) > jobLookupSize {
+		s.t.Errorf("filter has %d conditions, want at most %d", len(conditions), jobLookupSize)
+	}
+
+	var jobs []Job
+	for _, condition := range conditions {
+		jobID, _ := strconv.Atoi(strings.TrimPrefix(condition, "Id eq "))
+		state := JobStateRunning
+		if s.polls[jobID] > 0 {
+			state = JobStateSuccessful
+		}
+		s.polls[jobID]++
+		jobs = append(jobs, Job{ID: jobID, State: state})
+	}
+	json.NewEncoder(w).Encode(map[string][]Job{"value": jobs})
+}
+
+func useFastPolling(t *testing.T) {
+
+	interval, maxInterval := JobPollInterval, JobPollMaxInterval
+	JobPollInterval, JobPollMaxInterval = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { JobPollInterval, JobPollMaxInterval = interval, maxInterval })
+}
+
+func TestWaitForJobs(t *testing.T) {
+
+	useFastPolling(t)
+
+	jobIDs := make([]int, 45)
+	for i := range jobIDs {
+		jobIDs[i] = i + 1
+	}
+
+	tests := []struct {
+		name     string
+		failures []int
+		err      string
+	}{
+		{"looked up in batches", nil, ""},
+		{"temporary errors are retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway}, ""},
+		{"other errors are not", []int{http.StatusForbidden}, "API Request Failed: 403 Forbidden"},
+		{"too many temporary errors", []int{503, 503, 503, 503, 503, 503}, "API Request Failed: 503 Service Unavailable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &jobServer{t: t, polls: map[int]int{}, failures: test.failures}
+			client, _ := newTestClient(t, server.ServeHTTP)
+
+			changes := 0
+			jobs, err := client.WaitForJobs(jobIDs, time.Minute, func(Job) { changes++ })
+			if (err == nil) != (test.err == "") || (err != nil && err.Error() != test.err) {
+				t.Fatalf("err = %v, want %q", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(jobs) != len(jobIDs) || changes != 2*len(jobIDs) {
+				t.Errorf("got %d jobs and %d state changes, want %d and %d", len(jobs), changes, len(jobIDs), 2*len(jobIDs))
+			}
+			for _, job := range jobs {
+				if job.State != JobStateSuccessful {
+					t.Errorf("job %d is %s", job.ID, job.State)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForJobsTimeout(t *testing.T) {
+
+	useFastPolling(t)
+
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value":[{"Id":1,"State":"Running"}]}`))
+	})
+
+	jobs, err := client.WaitForJobs([]int{1}, 20*time.Millisecond, nil)
+	if err != ErrWaitTimeout || len(jobs) != 1 || jobs[0].State != JobStateRunning {
+		t.Errorf("WaitForJobs = %+v, %v, want the running job and ErrWaitTimeout", jobs, err)
+	}
+
+	_, err = client.WaitForJobs([]int{1, 2}, time.Minute, nil)
+	if err == nil || err.Error() != "Some jobs were not found in the current folder: 2" {
+		t.Errorf("err = %v, want the missing job", err)
+	}
+}
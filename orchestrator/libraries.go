@@ -0,0 +1,47 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+)
+
+// LibrariesURI is the OData collection of packages in the tenant library feed
+const LibrariesURI string = "/odata/Libraries"
+
+// LibraryUploadURI is the library feed upload action
+const LibraryUploadURI string = "/odata/Libraries/UiPath.Server.Configuration.OData.UploadPackage"
+
+// UploadLibrary uploads a NuGet package file to the tenant library feed.  It
+// behaves like UploadPackage
+func (c *Client) UploadLibrary(ctx context.Context, packagePath string, onProgress func(sent int64)) (*UploadResult, int64, error) {
+	return c.uploadToFeed(ctx, LibraryUploadURI, packagePath, onProgress)
+}
+
+// NewLibrariesPager returns a pager over the packages in the library feed.  Each
+// entry holds the latest version of a library
+func (c *Client) NewLibrariesPager(query ODataQuery, paging Paging) *ODataPager {
+	return c.InFolder(0).NewODataPager(LibrariesURI, query, paging)
+}
+
+// LibraryVersions returns every version of a package in the tenant library feed
+// An empty result means the feed does not hold the package
+func (c *Client) LibraryVersions(packageID string) ([]Package, error) {
+
+	var versions []Package
+	uri := LibrariesURI + "/UiPath.Server.Configuration.OData.GetVersions(packageId=" + ODataString(packageID) + ")"
+	err := c.InFolder(0).NewODataPager(uri, ODataQuery{}, Paging{All: true}).ReadAll(&versions)
+
+	return versions, err
+}
+
+// DownloadLibrary writes a version of a library to w and returns the number of
+// bytes written
+func (c *Client) DownloadLibrary(packageID string, version string, w io.Writer) (int64, error) {
+	return c.downloadFromFeed(LibrariesURI, Feed{}, packageID, version, w)
+}
+
+// DeleteLibrary removes one version of a library from the feed, or every version
+// when version is empty
+func (c *Client) DeleteLibrary(packageID string, version string) error {
+	return c.deleteFromFeed(LibrariesURI, Feed{}, packageID, version)
+}
@@ -0,0 +1,190 @@
+package orchestrator
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcsimms/uipo/util"
+)
+
+// DefaultRedirectPort is the loopback port used for the interactive login redirect
+// The redirect URL (http://127.0.0.1:12700) must be registered on the external application
+const DefaultRedirectPort = 12700
+
+// DefaultLoginTimeout is how long Login waits for the user to finish signing in
+const DefaultLoginTimeout = 5 * time.Minute
+
+// InteractiveLogin performs the OAuth authorization code flow with PKCE for a
+// non-confidential external application, receiving the code on a loopback listener
+type InteractiveLogin struct {
+	// IdentityURL is the base URL of the identity server (e.g. https://cloud.uipath.com/identity_)
+	IdentityURL string
+	// AppID is the client ID of the non-confidential external application
+	AppID string
+	// Scope is the space separated list of scopes to request
+	Scope string
+	// Port is the loopback port to listen on, usually DefaultRedirectPort.  0 listens
+	// on a free port chosen by the system
+	Port int
+	// Timeout is how long to wait for the redirect.  0 uses DefaultLoginTimeout
+	Timeout time.Duration
+	// OpenBrowser is called with the authorize URL.  When nil the URL is only printed
+	OpenBrowser func(string) error
+	// Out receives the authorize URL and progress messages
+	Out io.Writer
+
+	HTTPClient *http.Client
+}
+
+type loginResult struct {
+	code string
+	err  error
+}
+
+// Login runs the interactive flow and returns the tokens issued by the identity server
+func (l *InteractiveLogin) Login() (*Token, error) {
+
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = DefaultLoginTimeout
+	}
+	httpClient := l.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	verifier, err := randomURLString(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomURLString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(l.Port))
+	if err != nil {
+		return nil, err
+	}
+	// Built from the bound address, since localhost may resolve to ::1 instead
+	redirectURL := "http://" + listener.Addr().String()
+
+	results := make(chan loginResult, 1)
+	server := &http.Server{Handler: callbackHandler(state, results)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authorizeURL := l.authorizeURL(redirectURL, state, codeChallenge(verifier))
+
+	fmt.Fprintln(l.Out, "Sign in to UiPath using the following URL:")
+	fmt.Fprintln(l.Out, "  "+authorizeURL)
+	if l.OpenBrowser != nil {
+		if openErr := l.OpenBrowser(authorizeURL); openErr != nil {
+			util.LogDebug("Unable to open browser: " + openErr.Error())
+		}
+	}
+
+	var result loginResult
+	select {
+	case result = <-results:
+	case <-time.After(timeout):
+		return nil, errors.New("Timed out waiting for the interactive login to complete")
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	util.LogDebug("Authorization code received, exchanging for tokens")
+
+	form := url.Values{}
+	form.Add("grant_type", "authorization_code")
+	form.Add("code", result.code)
+	form.Add("redirect_uri", redirectURL)
+	form.Add("client_id", l.AppID)
+	form.Add("code_verifier", verifier)
+
+	return requestOAuthToken(httpClient, TokenEndpoint(l.IdentityURL), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func (l *InteractiveLogin) authorizeURL(redirectURL string, state string, challenge string) string {
+
+	query := url.Values{}
+	query.Add("response_type", "code")
+	query.Add("client_id", l.AppID)
+	query.Add("redirect_uri", redirectURL)
+	query.Add("scope", l.Scope)
+	query.Add("state", state)
+	query.Add("code_challenge", challenge)
+	query.Add("code_challenge_method", "S256")
+
+	return strings.TrimRight(l.IdentityURL, "/") + "/connect/authorize?" + query.Encode()
+}
+
+// callbackHandler receives the identity server redirect and hands the code back to Login
+func callbackHandler(state string, results chan<- loginResult) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Invalid login state", http.StatusBadRequest)
+			return
+		}
+
+		result := loginResult{code: query.Get("code")}
+		if errCode := query.Get("error"); errCode != "" {
+			result.err = errors.New("Interactive login failed: " + errCode + " " + query.Get("error_description"))
+		} else if result.code == "" {
+			result.err = errors.New("Interactive login failed: no authorization code was returned")
+		}
+
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Authentication complete.  You can close this window and return to uipo.")
+		}
+
+		// Only the first redirect counts; browsers may retry or request a favicon
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// codeChallenge derives the S256 PKCE challenge for a verifier
+func codeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func randomURLString(size int) (string, error) {
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// IdentityRefreshTokenGrant exchanges a refresh token issued by the identity server
+// (e.g. from an interactive login) for a new access token
+func IdentityRefreshTokenGrant(httpClient *http.Client, tokenEndpoint string, appID string, refreshToken string) (*Token, error) {
+
+	form := url.Values{}
+	form.Add("grant_type", "refresh_token")
+	form.Add("client_id", appID)
+	form.Add("refresh_token", refreshToken)
+
+	return requestOAuthToken(httpClient, tokenEndpoint, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
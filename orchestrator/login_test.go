@@ -0,0 +1,208 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIdentity is an identity server that issues tokens for one authorization
+// code, checking the PKCE verifier against the challenge sent to /connect/authorize
+type fakeIdentity struct {
+	t           *testing.T
+	challenge   string
+	redirectURI string
+	tokenError  string
+	exchanges   int
+}
+
+func (f *fakeIdentity) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/identity_/connect/token" {
+		http.NotFound(w, r)
+		return
+	}
+	f.exchanges++
+
+	r.ParseForm()
+	checks := map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          "the-code",
+		"client_id":     "app-1",
+		"redirect_uri":  f.redirectURI,
+		"code_verifier": r.PostForm.Get("code_verifier"),
+	}
+	for key, want := range checks {
+		if got := r.PostForm.Get(key); got != want {
+			f.t.Errorf("token request %s = %q, want %q", key, got, want)
+		}
+	}
+	if codeChallenge(r.PostForm.Get("code_verifier")) != f.challenge {
+		f.t.Errorf("code_verifier does not match the code_challenge")
+	}
+
+	if f.tokenError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": f.tokenError, "error_description": "Bad code"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  "access",
+		"refresh_token": "refresh",
+		"scope":         "OR.Jobs offline_access",
+		"expires_in":    3600,
+	})
+}
+
+func TestInteractiveLogin(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		tokenError string
+		// redirect plays the browser returning to uipo after the user signs in
+		redirect  func(t *testing.T, redirectURI string, state string)
+		err       string
+		exchanges int
+	}{
+		{
+			name: "code is exchanged for tokens",
+			redirect: func(t *testing.T, redirectURI string, state string) {
+				expectStatus(t, redirectURI+"?code=the-code&state="+state, http.StatusOK)
+			},
+			exchanges: 1,
+		},
+		{
+			name: "redirects with the wrong state are ignored",
+			redirect: func(t *testing.T, redirectURI string, state string) {
+				expectStatus(t, redirectURI+"?code=stolen&state=other", http.StatusBadRequest)
+				expectStatus(t, redirectURI+"?code=the-code&state="+state, http.StatusOK)
+			},
+			exchanges: 1,
+		},
+		{
+			name: "sign in errors are returned",
+			redirect: func(t *testing.T, redirectURI string, state string) {
+				expectStatus(t, redirectURI+"?error=access_denied&error_description=Denied&state="+state, http.StatusBadRequest)
+			},
+			err: "Interactive login failed: access_denied Denied",
+		},
+		{
+			name: "a redirect without a code is an error",
+			redirect: func(t *testing.T, redirectURI string, state string) {
+				expectStatus(t, redirectURI+"?state="+state, http.StatusBadRequest)
+			},
+			err: "Interactive login failed: no authorization code was returned",
+		},
+		{
+			name:       "token errors are returned",
+			tokenError: "invalid_grant",
+			redirect: func(t *testing.T, redirectURI string, state string) {
+				expectStatus(t, redirectURI+"?code=the-code&state="+state, http.StatusOK)
+			},
+			err:       "Authentication failed: invalid_grant Bad code",
+			exchanges: 1,
+		},
+		{
+			name:     "login times out without a redirect",
+			redirect: func(t *testing.T, redirectURI string, state string) {},
+			err:      "Timed out waiting for the interactive login to complete",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			identity := &fakeIdentity{t: t, tokenError: test.tokenError}
+			server := httptest.NewServer(identity)
+			defer server.Close()
+
+			login := InteractiveLogin{
+				IdentityURL: server.URL + "/identity_/",
+				AppID:       "app-1",
+				Scope:       "OR.Jobs offline_access",
+				Timeout:     time.Second,
+				Out:         ioutil.Discard,
+				HTTPClient:  server.Client(),
+				OpenBrowser: func(authorizeURL string) error {
+					authorize, err := url.Parse(authorizeURL)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if authorize.Path != "/identity_/connect/authorize" {
+						t.Errorf("authorize path = %q", authorize.Path)
+					}
+					query := authorize.Query()
+					for key, want := range map[string]string{
+						"response_type":         "code",
+						"client_id":             "app-1",
+						"scope":                 "OR.Jobs offline_access",
+						"code_challenge_method": "S256",
+					} {
+						if got := query.Get(key); got != want {
+							t.Errorf("authorize %s = %q, want %q", key, got, want)
+						}
+					}
+					if query.Get("state") == "" || query.Get("code_challenge") == "" {
+						t.Errorf("authorize URL has no state or code_challenge: %s", authorizeURL)
+					}
+					// Port 0 listens on a free port, addressed by IP rather than localhost
+					identity.redirectURI = query.Get("redirect_uri")
+					if !strings.HasPrefix(identity.redirectURI, "http://127.0.0.1:") || strings.HasSuffix(identity.redirectURI, ":0") {
+						t.Errorf("redirect_uri = %q, want a free port on 127.0.0.1", identity.redirectURI)
+					}
+					identity.challenge = query.Get("code_challenge")
+
+					test.redirect(t, identity.redirectURI, query.Get("state"))
+					return nil
+				},
+			}
+			if test.err == "Timed out waiting for the interactive login to complete" {
+				login.Timeout = 50 * time.Millisecond
+			}
+
+			token, err := login.Login()
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("err = %v, want %q", err, test.err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.Expiry.Before(time.Now().Add(59*time.Minute)) {
+					t.Errorf("token = %+v", token)
+				}
+			}
+			if identity.exchanges != test.exchanges {
+				t.Errorf("token requests = %d, want %d", identity.exchanges, test.exchanges)
+			}
+		})
+	}
+}
+
+func expectStatus(t *testing.T, target string, status int) {
+
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Errorf("GET %s returned %d, want %d", target, resp.StatusCode, status)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+
+	// Example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got := codeChallenge(verifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("codeChallenge = %q", got)
+	}
+}
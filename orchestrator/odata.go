@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ODataQuery holds the OData system query options sent with a collection request
+// The zero value requests the collection with the server's defaults
+// Params holds any other query parameters the request needs, such as a feedId
+type ODataQuery struct {
+	Filter  string
+	Select  string
+	OrderBy string
+	Expand  string
+	Top     int
+	Skip    int
+	Params  url.Values
+}
+
+// And adds a condition to the query's $filter, combining it with any existing
+// filter so that commands can add their own conditions to a user supplied one
+func (q *ODataQuery) And(condition string) {
+
+	if condition == "" {
+		return
+	}
+	if q.Filter == "" {
+		q.Filter = condition
+		return
+	}
+
+	q.Filter = "(" + q.Filter + ") and (" + condition + ")"
+}
+
+// Values returns the query options as URL query parameters.  Options that are
+// not set are left out
+func (q ODataQuery) Values() url.Values {
+
+	query := url.Values{}
+	for key, values := range q.Params {
+		query[key] = append([]string(nil), values...)
+	}
+	if q.Filter != "" {
+		query.Set("$filter", q.Filter)
+	}
+	if q.Select != "" {
+		query.Set("$select", q.Select)
+	}
+	if q.OrderBy != "" {
+		query.Set("$orderby", q.OrderBy)
+	}
+	if q.Expand != "" {
+		query.Set("$expand", q.Expand)
+	}
+	if q.Top > 0 {
+		query.Set("$top", strconv.Itoa(q.Top))
+	}
+	if q.Skip > 0 {
+		query.Set("$skip", strconv.Itoa(q.Skip))
+	}
+
+	return query
+}
+
+// ODataString quotes a value for use as a string literal in a $filter expression
+func ODataString(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// GetOData requests an OData collection and decodes the entries in its value
+// array into v, which should be a pointer to a slice
+func (c *Client) GetOData(uri string, query ODataQuery, v interface{}) error {
+
+	odataResp := ODataResponse{}
+	err := c.Get(uri, query.Values(), &odataResp)
+	if err != nil {
+		return err
+	}
+
+	return decodeODataValue(odataResp, v)
+}
+
+// decodeODataValue decodes the entries of an OData response into v.  A response
+// without a value array leaves v unchanged
+func decodeODataValue(odataResp ODataResponse, v interface{}) error {
+
+	if len(odataResp.Value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(odataResp.Value, v)
+}
@@ -0,0 +1,270 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ProcessUploadURI is the process feed upload action
+const ProcessUploadURI string = "/odata/Processes/UiPath.Server.Configuration.OData.UploadPackage"
+
+// ProcessesURI is the OData collection of packages in the process feed
+const ProcessesURI string = "/odata/Processes"
+
+// FolderFeedURI returns the ID of a folder's own package feed
+const FolderFeedURI string = "/api/PackageFeeds/GetFolderFeed"
+
+// Feed identifies a package feed.  The zero value is the tenant feed, while
+// folders can be set up with a feed of their own
+type Feed struct {
+	ID       string
+	FolderID int
+}
+
+// Package is the representation of a package in the Orchestrator process feed
+// Listing the feed returns one entry per package, holding its latest version
+type Package struct {
+	ID              string `json:"Id"`
+	Key             string `json:"Key"`
+	Version         string `json:"Version"`
+	Title           string `json:"Title"`
+	Description     string `json:"Description"`
+	Authors         string `json:"Authors"`
+	IsActive        bool   `json:"IsActive"`
+	IsLatestVersion bool   `json:"IsLatestVersion"`
+	Published       string `json:"Published"`
+}
+
+type uploadRespWrapper struct {
+	ODataContext string         `json:"@odata.context"`
+	UploadResp   []UploadResult `json:"value"`
+}
+
+// UploadResult is the per-file result returned by an UploadPackage call
+type UploadResult struct {
+	Key    string `json:"Key"`
+	Status string `json:"Status"`
+	Body   string `json:"Body"`
+}
+
+// UploadPackage uploads a NuGet package file to the tenant process feed
+// The multipart body is streamed from the file rather than held in memory
+// onProgress, when not nil, is called with the number of bytes of the file sent so
+// far.  Cancelling ctx aborts the upload
+// Returns the upload result along with the size of the package
+func (c *Client) UploadPackage(ctx context.Context, packagePath string, onProgress func(sent int64)) (*UploadResult, int64, error) {
+	return c.uploadToFeed(ctx, ProcessUploadURI, packagePath, onProgress)
+}
+
+// uploadToFeed sends a package file to the upload action of a feed
+func (c *Client) uploadToFeed(ctx context.Context, uploadURI string, packagePath string, onProgress func(sent int64)) (*UploadResult, int64, error) {
+
+	info, err := os.Stat(packagePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	fileSize := info.Size()
+
+	// Write the multipart framing once to learn its length, so the request is sent
+	//  with a Content-Length rather than chunked
+	framing := &bytes.Buffer{}
+	writer := multipart.NewWriter(framing)
+	_, err = writer.CreateFormFile("file", filepath.Base(packagePath))
+	if err != nil {
+		return nil, 0, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	newBody := func() (io.ReadCloser, error) {
+		return streamPackage(packagePath, writer.Boundary(), onProgress)
+	}
+	body, err := newBody()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := c.InFolder(0).NewRequest("POST", uploadURI, nil, body)
+	if err != nil {
+		body.Close()
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(framing.Len()) + fileSize
+	req.GetBody = newBody
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	apiResp := uploadRespWrapper{}
+	err = c.Do(req, &apiResp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(apiResp.UploadResp) == 0 {
+		return nil, fileSize, errors.New("Upload response did not include a result for " + filepath.Base(packagePath))
+	}
+
+	return &apiResp.UploadResp[0], fileSize, nil
+}
+
+// streamPackage returns the multipart body for a package upload.  The body is
+// written through a pipe as the request reads it, so only a small part of the
+// file is in memory at any time
+func streamPackage(packagePath string, boundary string, onProgress func(sent int64)) (io.ReadCloser, error) {
+
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, pipeWriter := io.Pipe()
+	go func() {
+		defer file.Close()
+
+		writer := multipart.NewWriter(pipeWriter)
+		err := writer.SetBoundary(boundary)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", filepath.Base(packagePath))
+			if err == nil {
+				_, err = io.Copy(part, &progressReader{reader: file, onProgress: onProgress})
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+
+		// A nil error closes the pipe normally, ending the body
+		pipeWriter.CloseWithError(err)
+	}()
+
+	return reader, nil
+}
+
+// progressReader reports the running total of bytes read
+type progressReader struct {
+	reader     io.Reader
+	onProgress func(sent int64)
+	sent       int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.sent += int64(n)
+	if r.onProgress != nil && n > 0 {
+		r.onProgress(r.sent)
+	}
+	return n, err
+}
+
+// LatestPackage returns the newest version of a package in the feed
+func (c *Client) LatestPackage(feed Feed, packageID string) (*Package, error) {
+
+	var packages []Package
+	query := ODataQuery{Filter: "Id eq " + ODataString(packageID), Params: feed.params()}
+	err := c.InFolder(feed.FolderID).GetOData(ProcessesURI, query, &packages)
+	if err != nil {
+		return nil, err
+	}
+	if len(packages) == 0 {
+		return nil, errors.New("Package '" + packageID + "' was not found in the package feed")
+	}
+
+	return &packages[0], nil
+}
+
+// FolderFeed returns the package feed of a folder.  An error is returned when the
+// folder uses the tenant feed
+func (c *Client) FolderFeed(folderID int) (Feed, error) {
+
+	var feedID *string
+	query := url.Values{"folderId": []string{strconv.Itoa(folderID)}}
+	err := c.InFolder(folderID).Get(FolderFeedURI, query, &feedID)
+	if err != nil {
+		return Feed{}, err
+	}
+	if feedID == nil || *feedID == "" {
+		return Feed{}, errors.New("The folder does not have its own package feed.  It uses the tenant feed")
+	}
+
+	return Feed{ID: *feedID, FolderID: folderID}, nil
+}
+
+// NewPackagesPager returns a pager over the packages in a feed.  Each entry holds
+// the latest version of a package
+func (c *Client) NewPackagesPager(feed Feed, query ODataQuery, paging Paging) *ODataPager {
+
+	query.Params = feed.params()
+	return c.InFolder(feed.FolderID).NewODataPager(ProcessesURI, query, paging)
+}
+
+// PackageVersions returns every version of a package in the feed
+func (c *Client) PackageVersions(feed Feed, packageID string) ([]Package, error) {
+
+	var versions []Package
+	uri := ProcessesURI + "/UiPath.Server.Configuration.OData.GetProcessVersions(processId=" + ODataString(packageID) + ")"
+	err := c.InFolder(feed.FolderID).NewODataPager(uri, ODataQuery{Params: feed.params()}, Paging{All: true}).ReadAll(&versions)
+
+	return versions, err
+}
+
+// DownloadPackage writes a version of a package from the feed to w and returns
+// the number of bytes written
+func (c *Client) DownloadPackage(feed Feed, packageID string, version string, w io.Writer) (int64, error) {
+	return c.downloadFromFeed(ProcessesURI, feed, packageID, version, w)
+}
+
+// downloadFromFeed writes a package version from the feed collection at uri to w
+func (c *Client) downloadFromFeed(uri string, feed Feed, packageID string, version string, w io.Writer) (int64, error) {
+
+	uri = uri + "/UiPath.Server.Configuration.OData.DownloadPackage(key=" + ODataString(packageID+":"+version) + ")"
+	req, err := c.InFolder(feed.FolderID).NewRequest("GET", uri, feed.params(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	return c.Download(req, w)
+}
+
+// DeletePackage removes one version of a package from the feed, or every version
+// when version is empty
+func (c *Client) DeletePackage(feed Feed, packageID string, version string) error {
+	return c.deleteFromFeed(ProcessesURI, feed, packageID, version)
+}
+
+// deleteFromFeed removes a package, or one version of it, from the feed collection at uri
+func (c *Client) deleteFromFeed(uri string, feed Feed, packageID string, version string) error {
+
+	key := packageID
+	if version != "" {
+		key = packageID + ":" + version
+	}
+
+	req, err := c.InFolder(feed.FolderID).NewRequest("DELETE", uri+"("+ODataString(key)+")", feed.params(), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.Do(req, nil)
+}
+
+// params returns the query parameters that select the feed.  The tenant feed
+// needs none
+func (f Feed) params() url.Values {
+
+	if f.ID == "" {
+		return nil
+	}
+
+	return url.Values{"feedId": []string{f.ID}}
+}
@@ -0,0 +1,178 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+)
+
+// DefaultPageSize is the number of entries requested per page when paging
+const DefaultPageSize int = 100
+
+// MaxPageSize is the largest $top Orchestrator accepts for a single request
+const MaxPageSize int = 1000
+
+// Paging controls how much of an OData collection an ODataPager reads
+// The zero value reads a single page using the query's own $top and $skip
+type Paging struct {
+	// All follows @odata.nextLink (or $skip paging) until the collection is exhausted
+	All bool
+	// PageSize is the $top sent with each request when All is set.  DefaultPageSize when 0
+	PageSize int
+	// Limit stops paging once this many entries have been read.  0 reads every page
+	Limit int
+}
+
+// ODataPager reads an OData collection one page at a time so that large
+// collections can be processed without holding every entry in memory
+type ODataPager struct {
+	// Count is the @odata.count reported with the first page
+	Count int
+
+	client   *Client
+	uri      string
+	query    ODataQuery
+	paging   Paging
+	nextLink string
+	fetched  int
+	done     bool
+}
+
+// NewODataPager creates a pager for the collection at uri (e.g. RobotsURI)
+// No request is sent until the first call to Next
+func (c *Client) NewODataPager(uri string, query ODataQuery, paging Paging) *ODataPager {
+
+	if paging.PageSize <= 0 {
+		paging.PageSize = DefaultPageSize
+	}
+
+	return &ODataPager{
+		client: c,
+		uri:    uri,
+		query:  query,
+		paging: paging,
+	}
+}
+
+// Next decodes the next page of entries into v, a pointer to a slice, and
+// returns false once there are no more entries to read
+func (p *ODataPager) Next(v interface{}) (bool, error) {
+
+	if p.done {
+		return false, nil
+	}
+
+	pageSize := p.paging.PageSize
+	if p.paging.Limit > 0 && p.paging.Limit-p.fetched < pageSize {
+		pageSize = p.paging.Limit - p.fetched
+	}
+
+	odataResp := ODataResponse{}
+	var err error
+	if p.nextLink != "" {
+		err = p.client.Get(p.nextLink, nil, &odataResp)
+	} else {
+		query := p.query
+		if p.paging.All {
+			query.Top = pageSize
+			query.Skip = p.query.Skip + p.fetched
+		}
+		values := query.Values()
+		values.Set("$count", "true")
+		err = p.client.Get(p.uri, values, &odataResp)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if p.fetched == 0 && p.nextLink == "" {
+		p.Count = odataResp.ODataCount
+	}
+
+	var entries []json.RawMessage
+	if len(odataResp.Value) > 0 {
+		err = json.Unmarshal(odataResp.Value, &entries)
+		if err != nil {
+			return false, err
+		}
+	}
+	if p.paging.Limit > 0 && p.fetched+len(entries) > p.paging.Limit {
+		entries = entries[:p.paging.Limit-p.fetched]
+	}
+	p.fetched += len(entries)
+
+	p.nextLink, err = p.resolveNextLink(odataResp.NextLink)
+	if err != nil {
+		return false, err
+	}
+
+	// Without a next link, a short page is the last one
+	p.done = !p.paging.All || len(entries) == 0 ||
+		(p.paging.Limit > 0 && p.fetched >= p.paging.Limit) ||
+		(p.nextLink == "" && len(entries) < pageSize)
+
+	if len(entries) == 0 {
+		return false, nil
+	}
+
+	rawPage, err := json.Marshal(entries)
+	if err != nil {
+		return false, err
+	}
+
+	// Decode into an empty slice.  Unmarshal reuses the elements of a slice passed
+	//  back in, so fields that are null or missing would keep the previous page's
+	//  values and entries kept from that page would be overwritten
+	target := reflect.ValueOf(v).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	return true, json.Unmarshal(rawPage, v)
+}
+
+// ReadAll reads every remaining page and appends the entries to v, a pointer to a slice
+func (p *ODataPager) ReadAll(v interface{}) error {
+
+	entries := reflect.ValueOf(v).Elem()
+	page := reflect.New(entries.Type())
+	for {
+		more, err := p.Next(page.Interface())
+		if err != nil || !more {
+			return err
+		}
+		entries.Set(reflect.AppendSlice(entries, page.Elem()))
+	}
+}
+
+// Query returns the query sent with each request
+func (p *ODataPager) Query() ODataQuery {
+	return p.query
+}
+
+// Fetched returns the number of entries read so far
+func (p *ODataPager) Fetched() int {
+	return p.fetched
+}
+
+// Truncated reports whether @odata.count shows entries beyond those read
+func (p *ODataPager) Truncated() bool {
+	return p.Count > p.query.Skip+p.fetched
+}
+
+// resolveNextLink turns a relative @odata.nextLink into an absolute URL
+func (p *ODataPager) resolveNextLink(nextLink string) (string, error) {
+
+	if nextLink == "" {
+		return "", nil
+	}
+
+	base, err := url.Parse(p.client.baseURL + p.uri)
+	if err != nil {
+		return "", err
+	}
+	link, err := url.Parse(nextLink)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(link).String(), nil
+}
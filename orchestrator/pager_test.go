@@ -0,0 +1,145 @@
+package orchestrator
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bcsimms/uipo/config"
+)
+
+// newTestClient returns a client for folder 1 of an on-premise Orchestrator
+//  served by handler, along with its config
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *config.Config) {
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conf := &config.Config{}
+	conf.ConfigFile.APIEndpoint = server.URL
+	conf.ConfigFile.EndpointType = config.EndpointTypeOnPremise
+	conf.ConfigFile.ServiceLogicalName = "DefaultTenant"
+	conf.ConfigFile.AccessToken = "token"
+	conf.ConfigFile.AccessTokenExpiry = time.Now().Add(time.Hour)
+	conf.ConfigFile.TargetedFolder.ID = 1
+
+	client, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, conf
+}
+
+type pagedRobot struct {
+	Name string `json:"Name"`
+}
+
+// pagedEntry has the nullable and nested fields that pages must not share
+type pagedEntry struct {
+	ID        int         `json:"Id"`
+	Exception *string     `json:"Exception"`
+	Reason    string      `json:"Reason"`
+	Tags      []string    `json:"Tags"`
+	Robot     *pagedRobot `json:"Robot"`
+}
+
+// pagedServer serves two pages of entries, following @odata.nextLink from the first.
+//  Page 1 sets every field and page 2 leaves them null or out.  Requests paged with
+//  $skip past page 1 get no entries
+func pagedServer(w http.ResponseWriter, r *http.Request) {
+
+	if skip := r.URL.Query().Get("$skip"); skip != "" && skip != "0" {
+		fmt.Fprint(w, `{"value":[]}`)
+		return
+	}
+	if r.URL.Query().Get("page") == "2" {
+		fmt.Fprint(w, `{"value":[{"Id":3,"Exception":null,"Tags":null,"Robot":null},{"Id":4}]}`)
+		return
+	}
+	fmt.Fprint(w, `{"@odata.count":4,"@odata.nextLink":"Entries?page=2","value":[`+
+		`{"Id":1,"Exception":"first","Reason":"r1","Tags":["a","b"],"Robot":{"Name":"Bot1"}},`+
+		`{"Id":2,"Exception":"second","Reason":"r2","Tags":["c"],"Robot":{"Name":"Bot2"}}]}`)
+}
+
+func pagedEntries() []pagedEntry {
+
+	first, second := "first", "second"
+	return []pagedEntry{
+		{ID: 1, Exception: &first, Reason: "r1", Tags: []string{"a", "b"}, Robot: &pagedRobot{Name: "Bot1"}},
+		{ID: 2, Exception: &second, Reason: "r2", Tags: []string{"c"}, Robot: &pagedRobot{Name: "Bot2"}},
+		{ID: 3},
+		{ID: 4},
+	}
+}
+
+func TestODataPagerNextDecodesEachPageAfresh(t *testing.T) {
+
+	client, _ := newTestClient(t, pagedServer)
+	pager := client.NewODataPager("/odata/Entries", ODataQuery{}, Paging{All: true, PageSize: 2})
+
+	var pages [][]pagedEntry
+	page := []pagedEntry{}
+	for {
+		more, err := pager.Next(&page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !more {
+			break
+		}
+		pages = append(pages, page)
+	}
+
+	want := pagedEntries()
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	if !reflect.DeepEqual(pages[0], want[:2]) {
+		t.Errorf("page 1 = %+v, want %+v", pages[0], want[:2])
+	}
+	if !reflect.DeepEqual(pages[1], want[2:]) {
+		t.Errorf("page 2 = %+v, want the null fields of page 2 left empty", pages[1])
+	}
+	if pager.Count != 4 || pager.Fetched() != 4 {
+		t.Errorf("Count, Fetched = %d, %d, want 4, 4", pager.Count, pager.Fetched())
+	}
+}
+
+func TestODataPagerReadAll(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		paging Paging
+		want   []pagedEntry
+	}{
+		{"every page", Paging{All: true, PageSize: 2}, pagedEntries()},
+		{"limit", Paging{All: true, PageSize: 2, Limit: 3}, pagedEntries()[:3]},
+		{"one page", Paging{}, pagedEntries()[:2]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			client, _ := newTestClient(t, pagedServer)
+			pager := client.NewODataPager("/odata/Entries", ODataQuery{}, test.paging)
+
+			var entries []pagedEntry
+			if err := pager.ReadAll(&entries); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(entries, test.want) {
+				var got []string
+				for _, entry := range entries {
+					got = append(got, strconv.Itoa(entry.ID)+":"+fmt.Sprint(entry.Exception != nil && *entry.Exception != "", entry.Tags, entry.Robot))
+				}
+				t.Errorf("entries = %v, want %+v", got, test.want)
+			}
+		})
+	}
+}
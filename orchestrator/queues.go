@@ -0,0 +1,310 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// AddQueueItemURI is the action used to add a single item to a queue
+const AddQueueItemURI string = "/odata/Queues/UiPathODataSvc.AddQueueItem"
+
+// BulkAddQueueItemsURI is the action used to add many items to a queue in one request
+const BulkAddQueueItemsURI string = "/odata/Queues/UiPathODataSvc.BulkAddQueueItems"
+
+// Commit types for adding items in bulk
+const (
+	// CommitAllOrNothing adds none of the items if any of them fails
+	CommitAllOrNothing string = "AllOrNothing"
+	// CommitStopOnFirstFailure adds the items before the first failure
+	CommitStopOnFirstFailure string = "StopOnFirstFailure"
+	// CommitProcessAllIndependently adds every item that does not fail
+	CommitProcessAllIndependently string = "ProcessAllIndependently"
+)
+
+// QueueItemData is the payload used when adding an item to a queue
+type QueueItemData struct {
+	Name            string          `json:"Name"`
+	Priority        string          `json:"Priority"`
+	SpecificContent json.RawMessage `json:"SpecificContent,omitempty"`
+	DeferDate       string          `json:"DeferDate,omitempty"`
+	DueDate         string          `json:"DueDate,omitempty"`
+	Reference       string          `json:"Reference"`
+}
+
+// QueueItem is the representation of a queue item in UiPath Orchestrator
+// SpecificContent and Output are JSON objects.  Each retry of a failed item is a
+// new item whose AncestorId is the item it retries
+type QueueItem struct {
+	ID                      int                  `json:"Id"`
+	Key                     string               `json:"Key,omitempty"`
+	QueueDefinitionID       int                  `json:"QueueDefinitionId"`
+	Status                  string               `json:"Status"`
+	ReviewStatus            string               `json:"ReviewStatus,omitempty"`
+	Reference               string               `json:"Reference"`
+	Priority                string               `json:"Priority,omitempty"`
+	SpecificContent         json.RawMessage      `json:"SpecificContent,omitempty"`
+	Output                  json.RawMessage      `json:"Output,omitempty"`
+	Progress                string               `json:"Progress,omitempty"`
+	ProcessingExceptionType string               `json:"ProcessingExceptionType,omitempty"`
+	ProcessingException     *ProcessingException `json:"ProcessingException,omitempty"`
+	CreationTime            string               `json:"CreationTime"`
+	StartProcessing         string               `json:"StartProcessing,omitempty"`
+	EndProcessing           string               `json:"EndProcessing,omitempty"`
+	DueDate                 string               `json:"DueDate,omitempty"`
+	DeferDate               string               `json:"DeferDate,omitempty"`
+	RetryNumber             int                  `json:"RetryNumber"`
+	AncestorID              int                  `json:"AncestorId,omitempty"`
+	RowVersion              string               `json:"RowVersion,omitempty"`
+	Robot                   *Robot               `json:"Robot,omitempty"`
+}
+
+// ProcessingException describes why processing a queue item failed
+type ProcessingException struct {
+	Reason  string `json:"Reason"`
+	Details string `json:"Details"`
+	Type    string `json:"Type"`
+}
+
+// FailedQueueItem is an item that Orchestrator did not add in a bulk request
+type FailedQueueItem struct {
+	Reference    string `json:"Reference"`
+	Type         string `json:"Type"`
+	ErrorMessage string `json:"ErrorMessage"`
+}
+
+type addQueueItemReq struct {
+	ItemData QueueItemData `json:"itemData"`
+}
+
+type bulkAddQueueItemsReq struct {
+	QueueName  string          `json:"queueName"`
+	CommitType string          `json:"commitType"`
+	QueueItems []QueueItemData `json:"queueItems"`
+}
+
+// AddQueueItem adds an item to the queue named in itemData
+func (c *Client) AddQueueItem(itemData QueueItemData) (*QueueItem, error) {
+
+	queueItem := QueueItem{}
+	err := c.Post(AddQueueItemURI, addQueueItemReq{ItemData: itemData}, &queueItem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queueItem, nil
+}
+
+// BulkAddQueueItems adds items to a queue in one request and returns the items
+// that were not added
+func (c *Client) BulkAddQueueItems(queueName string, commitType string, items []QueueItemData) ([]FailedQueueItem, error) {
+
+	var failed []FailedQueueItem
+	odataResp := ODataResponse{}
+	err := c.Post(BulkAddQueueItemsURI, bulkAddQueueItemsReq{QueueName: queueName, CommitType: commitType, QueueItems: items}, &odataResp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decodeODataValue(odataResp, &failed)
+
+	return failed, err
+}
+
+// QueueDefinitionsURI is the OData collection of queues in the client's folder
+const QueueDefinitionsURI string = "/odata/QueueDefinitions"
+
+// QueueStatusURI returns the processing status of each queue in the client's folder
+const QueueStatusURI string = "/odata/QueueProcessingRecords/UiPathODataSvc.RetrieveQueuesProcessingStatus"
+
+// QueueDefinition is the representation of a queue in UiPath Orchestrator.  The
+// JSON schemas, when set, are checked by Orchestrator as items are added and completed
+type QueueDefinition struct {
+	ID                                 int    `json:"Id"`
+	Key                                string `json:"Key,omitempty"`
+	Name                               string `json:"Name"`
+	Description                        string `json:"Description"`
+	MaxNumberOfRetries                 int    `json:"MaxNumberOfRetries"`
+	AcceptAutomaticallyRetry           bool   `json:"AcceptAutomaticallyRetry"`
+	EnforceUniqueReference             bool   `json:"EnforceUniqueReference"`
+	SlaInMinutes                       int    `json:"SlaInMinutes"`
+	RiskSlaInMinutes                   int    `json:"RiskSlaInMinutes"`
+	SpecificDataJSONSchema             string `json:"SpecificDataJsonSchema,omitempty"`
+	OutputDataJSONSchema               string `json:"OutputDataJsonSchema,omitempty"`
+	CreationTime                       string `json:"CreationTime,omitempty"`
+	OrganizationUnitFullyQualifiedName string `json:"OrganizationUnitFullyQualifiedName,omitempty"`
+}
+
+// QueueStatus holds the item counts and processing times of a queue.  Times are
+// in seconds
+type QueueStatus struct {
+	QueueDefinitionID         int     `json:"QueueDefinitionId"`
+	QueueDefinitionName       string  `json:"QueueDefinitionName"`
+	ItemsToProcess            int     `json:"ItemsToProcess"`
+	ItemsInProgress           int     `json:"ItemsInProgress"`
+	SuccessfulTransactionsNo  int     `json:"SuccessfulTransactionsNo"`
+	ApplicationExceptionsNo   int     `json:"ApplicationExceptionsNo"`
+	BusinessExceptionsNo      int     `json:"BusinessExceptionsNo"`
+	TotalNumberOfTransactions int     `json:"TotalNumberOfTransactions"`
+	ProcessingMeanTime        float64 `json:"ProcessingMeanTime"`
+	LastProcessed             string  `json:"LastProcessed"`
+}
+
+// queueDefinitionURI returns the path of a single queue
+func queueDefinitionURI(queueID int) string {
+	return QueueDefinitionsURI + "(" + strconv.Itoa(queueID) + ")"
+}
+
+// FindQueue returns the queue with the given name in the client's folder
+func (c *Client) FindQueue(name string) (*QueueDefinition, error) {
+
+	var queues []QueueDefinition
+	err := c.GetOData(QueueDefinitionsURI, ODataQuery{Filter: "Name eq " + ODataString(name)}, &queues)
+	if err != nil {
+		return nil, err
+	}
+	if len(queues) == 0 {
+		return nil, errors.New("No queue named '" + name + "' was found in the current folder")
+	}
+
+	return &queues[0], nil
+}
+
+// GetQueue returns a single queue
+func (c *Client) GetQueue(queueID int) (*QueueDefinition, error) {
+
+	queue := QueueDefinition{}
+	err := c.Get(queueDefinitionURI(queueID), nil, &queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue, nil
+}
+
+// CreateQueue creates a queue in the client's folder
+func (c *Client) CreateQueue(newQueue QueueDefinition) (*QueueDefinition, error) {
+
+	queue := QueueDefinition{}
+	err := c.Post(QueueDefinitionsURI, newQueue, &queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue, nil
+}
+
+// UpdateQueue replaces a queue's settings
+func (c *Client) UpdateQueue(queue QueueDefinition) error {
+	return c.Put(queueDefinitionURI(queue.ID), queue, nil)
+}
+
+// DeleteQueue removes a queue and its items from the client's folder
+func (c *Client) DeleteQueue(queueID int) error {
+	return c.Delete(queueDefinitionURI(queueID))
+}
+
+// QueueStatuses returns the processing status of the queues that match the query
+func (c *Client) QueueStatuses(query ODataQuery) ([]QueueStatus, error) {
+
+	var statuses []QueueStatus
+	err := c.NewODataPager(QueueStatusURI, query, Paging{All: true}).ReadAll(&statuses)
+
+	return statuses, err
+}
+
+// QueueItemsURI is the OData collection of queue items in the client's folder
+const QueueItemsURI string = "/odata/QueueItems"
+
+// Queue item statuses
+const (
+	QueueItemNew        string = "New"
+	QueueItemInProgress string = "InProgress"
+	QueueItemFailed     string = "Failed"
+	QueueItemSuccessful string = "Successful"
+	QueueItemAbandoned  string = "Abandoned"
+	QueueItemRetried    string = "Retried"
+	QueueItemDeleted    string = "Deleted"
+)
+
+// Queue item processing exception types
+const (
+	ApplicationException string = "ApplicationException"
+	BusinessException    string = "BusinessException"
+)
+
+// SetItemReviewStatusURI is the action used to change the review status of items.
+// Setting it to QueueItemRetried retries failed and abandoned items
+const SetItemReviewStatusURI string = QueueItemsURI + "/UiPathODataSvc.SetItemReviewStatus"
+
+// DeleteQueueItemsURI is the action used to delete several New items
+const DeleteQueueItemsURI string = QueueItemsURI + "/UiPathODataSvc.DeleteBulk"
+
+type queueItemsReq struct {
+	QueueItems []queueItemRef `json:"queueItems"`
+	Status     string         `json:"status,omitempty"`
+}
+
+// queueItemRef identifies a version of an item so that changes made since it
+// was read are not overwritten
+type queueItemRef struct {
+	ID         int    `json:"Id"`
+	RowVersion string `json:"RowVersion"`
+}
+
+// queueItemURI returns the path of a single queue item
+func queueItemURI(itemID int) string {
+	return QueueItemsURI + "(" + strconv.Itoa(itemID) + ")"
+}
+
+// ListQueueItems returns the queue items in the client's folder that match the query
+func (c *Client) ListQueueItems(query ODataQuery, paging Paging) ([]QueueItem, error) {
+
+	var items []QueueItem
+	err := c.NewODataPager(QueueItemsURI, query, paging).ReadAll(&items)
+
+	return items, err
+}
+
+// GetQueueItem returns a single queue item, including its content and output
+func (c *Client) GetQueueItem(itemID int) (*QueueItem, error) {
+
+	item := QueueItem{}
+	err := c.Get(queueItemURI(itemID), ODataQuery{Expand: "Robot"}.Values(), &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// QueueItemHistory returns every processing attempt of a queue item and its
+// retries, with the progress, output and exception of each
+func (c *Client) QueueItemHistory(itemID int) ([]QueueItem, error) {
+
+	var history []QueueItem
+	uri := QueueItemsURI + "/UiPathODataSvc.GetItemProcessingHistory(queueItemId=" + strconv.Itoa(itemID) + ")"
+	err := c.NewODataPager(uri, ODataQuery{Expand: "Robot"}, Paging{All: true}).ReadAll(&history)
+
+	return history, err
+}
+
+// RetryQueueItems retries failed and abandoned items by marking them Retried
+func (c *Client) RetryQueueItems(items []QueueItem) error {
+	return c.Post(SetItemReviewStatusURI, queueItemsReq{QueueItems: queueItemRefs(items), Status: QueueItemRetried}, nil)
+}
+
+// DeleteQueueItems deletes items that have not been processed
+func (c *Client) DeleteQueueItems(items []QueueItem) error {
+	return c.Post(DeleteQueueItemsURI, queueItemsReq{QueueItems: queueItemRefs(items)}, nil)
+}
+
+func queueItemRefs(items []QueueItem) []queueItemRef {
+
+	refs := make([]queueItemRef, len(items))
+	for i, item := range items {
+		refs[i] = queueItemRef{ID: item.ID, RowVersion: item.RowVersion}
+	}
+
+	return refs
+}
@@ -0,0 +1,112 @@
+package orchestrator
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ReleasesURI is the OData collection of processes (releases) in the client's folder
+const ReleasesURI string = "/odata/Releases"
+
+// Actions available on a single release
+const (
+	updatePackageVersionAction string = "/UiPath.Server.Configuration.OData.UpdateToSpecificPackageVersion"
+	rollbackAction             string = "/UiPath.Server.Configuration.OData.RollbackToPreviousReleaseVersion"
+)
+
+// Release is the representation of a Process in UiPath Orchestrator.  The API
+// calls the deployment of a package version to a folder a Release
+type Release struct {
+	ID                                 int    `json:"Id"`
+	Key                                string `json:"Key"`
+	Name                               string `json:"Name"`
+	ProcessKey                         string `json:"ProcessKey"`
+	ProcessVersion                     string `json:"ProcessVersion"`
+	IsLatestVersion                    bool   `json:"IsLatestVersion"`
+	Description                        string `json:"Description"`
+	EnvironmentName                    string `json:"EnvironmentName"`
+	InputArguments                     string `json:"InputArguments"`
+	OrganizationUnitID                 int    `json:"OrganizationUnitId"`
+	OrganizationUnitFullyQualifiedName string `json:"OrganizationUnitFullyQualifiedName"`
+}
+
+// NewRelease describes a process to create from a package in the process feed
+type NewRelease struct {
+	Name           string `json:"Name"`
+	ProcessKey     string `json:"ProcessKey"`
+	ProcessVersion string `json:"ProcessVersion"`
+	Description    string `json:"Description,omitempty"`
+	InputArguments string `json:"InputArguments,omitempty"`
+}
+
+type packageVersionReq struct {
+	PackageVersion string `json:"packageVersion"`
+}
+
+// releaseURI returns the path of a single release
+func releaseURI(releaseID int) string {
+	return ReleasesURI + "(" + strconv.Itoa(releaseID) + ")"
+}
+
+// ListReleases returns the processes in the client's folder that match the query
+func (c *Client) ListReleases(query ODataQuery, paging Paging) ([]Release, error) {
+
+	var releases []Release
+	err := c.NewODataPager(ReleasesURI, query, paging).ReadAll(&releases)
+
+	return releases, err
+}
+
+// GetRelease returns a single process
+func (c *Client) GetRelease(releaseID int) (*Release, error) {
+
+	release := Release{}
+	err := c.Get(releaseURI(releaseID), nil, &release)
+	if err != nil {
+		return nil, err
+	}
+
+	return &release, nil
+}
+
+// CreateRelease creates a process in the client's folder
+func (c *Client) CreateRelease(newRelease NewRelease) (*Release, error) {
+
+	release := Release{}
+	err := c.Post(ReleasesURI, newRelease, &release)
+	if err != nil {
+		return nil, err
+	}
+
+	return &release, nil
+}
+
+// UpdateReleaseVersion changes the package version a process runs
+func (c *Client) UpdateReleaseVersion(releaseID int, packageVersion string) error {
+	return c.Post(releaseURI(releaseID)+updatePackageVersionAction, packageVersionReq{PackageVersion: packageVersion}, nil)
+}
+
+// RollbackRelease returns a process to the package version it ran before its last update
+func (c *Client) RollbackRelease(releaseID int) error {
+	return c.Post(releaseURI(releaseID)+rollbackAction, nil, nil)
+}
+
+// DeleteRelease removes a process from the client's folder.  The package stays in the feed
+func (c *Client) DeleteRelease(releaseID int) error {
+	return c.Delete(releaseURI(releaseID))
+}
+
+// FindRelease returns the process with the given name in the client's folder
+func (c *Client) FindRelease(name string) (*Release, error) {
+
+	var releases []Release
+	err := c.GetOData(ReleasesURI, ODataQuery{Filter: "Name eq " + ODataString(name)}, &releases)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, errors.New("No process named '" + name + "' was found in the current folder")
+	}
+
+	return &releases[0], nil
+}
@@ -0,0 +1,40 @@
+package orchestrator
+
+import "errors"
+
+// RobotsURI is the OData collection of robots in the current tenant
+const RobotsURI string = "/odata/Robots"
+
+// Robot is the representation of a Robot in UiPath Orchestrator
+type Robot struct {
+	ID          int    `json:"Id"`
+	LicenseKey  string `json:"LicenseKey"`
+	MachineName string `json:"MachineName"`
+	MachineID   int    `json:"MachineId"`
+	Name        string `json:"Name"`
+	Version     string `json:"Version"`
+}
+
+// ListRobots returns the robots visible in the client's folder that match the query
+func (c *Client) ListRobots(query ODataQuery, paging Paging) ([]Robot, error) {
+
+	var robots []Robot
+	err := c.NewODataPager(RobotsURI, query, paging).ReadAll(&robots)
+
+	return robots, err
+}
+
+// FindRobot returns the robot with the given name in the client's folder
+func (c *Client) FindRobot(name string) (*Robot, error) {
+
+	var robots []Robot
+	err := c.GetOData(RobotsURI, ODataQuery{Filter: "Name eq " + ODataString(name)}, &robots)
+	if err != nil {
+		return nil, err
+	}
+	if len(robots) == 0 {
+		return nil, errors.New("No robot named '" + name + "' was found in the current folder")
+	}
+
+	return &robots[0], nil
+}
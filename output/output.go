@@ -0,0 +1,409 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Supported output formats
+const (
+	FormatTable string = "table"
+	FormatWide  string = "wide"
+	FormatJSON  string = "json"
+	FormatYAML  string = "yaml"
+	FormatCSV   string = "csv"
+)
+
+// Column describes a field shown in table, wide and csv output
+type Column struct {
+	// Header is the column heading
+	Header string
+	// Field is the path of the value in the item's JSON form (e.g. "Name" or "Robot.Id")
+	Field string
+	// Wide columns are only shown with the wide format
+	Wide bool
+}
+
+// Renderer writes command results in the format selected with --output
+// Items are written as they arrive so that large result sets do not need to be
+// held in memory.  Call Start, then Item for each result, then Finish
+type Renderer struct {
+	out     io.Writer
+	format  string
+	query   []string
+	columns []Column
+	count   int
+
+	table     *tabwriter.Writer
+	csvWriter *csv.Writer
+}
+
+// New creates a renderer for the given format and optional field query
+// The query is a comma separated list of JSONPath-style field paths
+// (e.g. "Name,Robot.Id" or "$.Items[0].Name") that replaces the default columns
+func New(out io.Writer, format string, query string) (*Renderer, error) {
+
+	if format == "" {
+		format = FormatTable
+	}
+	switch format {
+	case FormatTable, FormatWide, FormatJSON, FormatYAML, FormatCSV:
+	default:
+		return nil, errors.New("Unsupported output format '" + format + "'.  Use table, wide, json, yaml or csv")
+	}
+
+	renderer := Renderer{
+		out:    out,
+		format: format,
+	}
+
+	for _, field := range strings.Split(query, ",") {
+		field = normalizePath(field)
+		if field != "" {
+			renderer.query = append(renderer.query, field)
+		}
+	}
+
+	return &renderer, nil
+}
+
+// Format returns the selected output format
+func (r *Renderer) Format() string {
+	return r.format
+}
+
+// IsHuman reports whether the output is meant to be read rather than parsed
+// Commands only print status messages alongside human readable output
+func (r *Renderer) IsHuman() bool {
+	return r.format == FormatTable || r.format == FormatWide
+}
+
+// Count returns the number of items rendered so far
+func (r *Renderer) Count() int {
+	return r.count
+}
+
+// Start begins the output using the given default columns
+func (r *Renderer) Start(columns []Column) error {
+
+	r.count = 0
+	r.selectColumns(columns)
+
+	switch r.format {
+	case FormatTable, FormatWide:
+		r.table = tabwriter.NewWriter(r.out, 0, 4, 2, ' ', 0)
+		return r.writeRow(r.table, headers(r.columns))
+	case FormatCSV:
+		r.csvWriter = csv.NewWriter(r.out)
+		return r.csvWriter.Write(headers(r.columns))
+	}
+
+	return nil
+}
+
+// Item writes a single result
+func (r *Renderer) Item(v interface{}) error {
+
+	r.count++
+
+	switch r.format {
+	case FormatTable, FormatWide:
+		return r.writeRow(r.table, r.cells(v))
+	case FormatCSV:
+		return r.csvWriter.Write(r.cells(v))
+	case FormatJSON:
+		return r.writeJSON(v)
+	case FormatYAML:
+		return r.writeYAML(v)
+	}
+
+	return nil
+}
+
+// Flush writes out any buffered table rows.  Column widths are recalculated
+// for the rows that follow
+func (r *Renderer) Flush() error {
+
+	switch r.format {
+	case FormatTable, FormatWide:
+		return r.table.Flush()
+	case FormatCSV:
+		r.csvWriter.Flush()
+		return r.csvWriter.Error()
+	}
+
+	return nil
+}
+
+// Finish completes the output
+func (r *Renderer) Finish() error {
+
+	switch r.format {
+	case FormatJSON:
+		if r.count == 0 {
+			_, err := io.WriteString(r.out, "[]\n")
+			return err
+		}
+		_, err := io.WriteString(r.out, "\n]\n")
+		return err
+	case FormatYAML:
+		if r.count == 0 {
+			_, err := io.WriteString(r.out, "[]\n")
+			return err
+		}
+		return nil
+	}
+
+	return r.Flush()
+}
+
+// Render writes a complete slice of results
+func (r *Renderer) Render(columns []Column, items interface{}) error {
+
+	err := r.Start(columns)
+	if err != nil {
+		return err
+	}
+
+	err = r.Items(items)
+	if err != nil {
+		return err
+	}
+
+	return r.Finish()
+}
+
+// Items writes each element of a slice of results, e.g. a page of an OData collection
+func (r *Renderer) Items(items interface{}) error {
+
+	list := reflect.ValueOf(items)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return errors.New("Items expects a slice of items")
+	}
+	for i := 0; i < list.Len(); i++ {
+		err := r.Item(list.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderOne writes a single result, e.g. the item created by a command
+func (r *Renderer) RenderOne(columns []Column, item interface{}) error {
+
+	err := r.Start(columns)
+	if err != nil {
+		return err
+	}
+	err = r.Item(item)
+	if err != nil {
+		return err
+	}
+
+	return r.Finish()
+}
+
+// RenderDetails writes a single result as "Label: value" lines in table and wide
+// formats, which suits long values such as job arguments.  Other formats behave
+// like RenderOne
+func (r *Renderer) RenderDetails(columns []Column, item interface{}) error {
+
+	if !r.IsHuman() {
+		return r.RenderOne(columns, item)
+	}
+
+	r.count = 1
+	r.selectColumns(columns)
+	r.table = tabwriter.NewWriter(r.out, 0, 4, 1, ' ', 0)
+
+	for i, value := range r.cells(item) {
+		_, err := fmt.Fprintln(r.table, r.columns[i].Header+":\t"+value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.table.Flush()
+}
+
+// selectColumns picks the query fields, or the default columns for the format
+func (r *Renderer) selectColumns(columns []Column) {
+
+	r.columns = nil
+	if len(r.query) > 0 {
+		for _, field := range r.query {
+			r.columns = append(r.columns, Column{Header: field, Field: field})
+		}
+		return
+	}
+
+	for _, column := range columns {
+		if !column.Wide || r.format != FormatTable {
+			r.columns = append(r.columns, column)
+		}
+	}
+}
+
+func (r *Renderer) writeRow(w io.Writer, cells []string) error {
+	_, err := fmt.Fprintln(w, strings.Join(cells, "\t"))
+	return err
+}
+
+func (r *Renderer) writeJSON(v interface{}) error {
+
+	separator := ",\n"
+	if r.count == 1 {
+		separator = "[\n"
+	}
+
+	var item interface{} = v
+	if len(r.query) > 0 {
+		item = r.project(v)
+	}
+
+	rawItem, err := json.MarshalIndent(item, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(r.out, separator+"  "+string(rawItem))
+	return err
+}
+
+func (r *Renderer) writeYAML(v interface{}) error {
+
+	// Round trip through JSON so that YAML keys match the JSON field names
+	item := r.project(v)
+
+	rawItem, err := yaml.Marshal([]interface{}{item})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.out.Write(rawItem)
+	return err
+}
+
+// project returns the fields selected by the query, or the whole item when there
+// is no query, in a form that keeps the field order for JSON and YAML
+func (r *Renderer) project(v interface{}) interface{} {
+
+	generic := toGeneric(v)
+	if len(r.query) == 0 {
+		return generic
+	}
+
+	fields := orderedMap{}
+	for _, field := range r.query {
+		fields = append(fields, yaml.MapItem{Key: field, Value: lookup(generic, field)})
+	}
+
+	return fields
+}
+
+func (r *Renderer) cells(v interface{}) []string {
+
+	generic := toGeneric(v)
+
+	cells := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		cells[i] = formatValue(lookup(generic, column.Field))
+	}
+
+	return cells
+}
+
+func headers(columns []Column) []string {
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+	}
+
+	return headers
+}
+
+// formatValue renders a single value for table and csv output
+func formatValue(value interface{}) string {
+
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case bool:
+		if typed {
+			return "true"
+		}
+		return "false"
+	}
+
+	rawValue, err := json.Marshal(toJSON(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(rawValue)
+}
+
+// orderedMap is a JSON object that keeps its keys in insertion order
+type orderedMap yaml.MapSlice
+
+// MarshalJSON writes the map as a JSON object in key order
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, item := range m {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(fmt.Sprint(item.Key))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(toJSON(item.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML writes the map as a YAML mapping in key order
+func (m orderedMap) MarshalYAML() (interface{}, error) {
+	return yaml.MapSlice(m), nil
+}
+
+// toJSON converts nested yaml.MapSlice values back into orderedMap so they marshal as JSON objects
+func toJSON(value interface{}) interface{} {
+
+	switch typed := value.(type) {
+	case yaml.MapSlice:
+		return orderedMap(typed)
+	case []interface{}:
+		converted := make([]interface{}, len(typed))
+		for i, element := range typed {
+			converted[i] = toJSON(element)
+		}
+		return converted
+	}
+
+	return value
+}
@@ -0,0 +1,238 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testRobot struct {
+	ID   int    `json:"Id"`
+	Name string `json:"Name"`
+}
+
+type testJob struct {
+	ID      int        `json:"Id"`
+	State   string     `json:"State"`
+	Robot   *testRobot `json:"Robot"`
+	Tags    []string   `json:"Tags"`
+	Done    bool       `json:"Done"`
+	Percent float64    `json:"Percent,omitempty"`
+}
+
+var testColumns = []Column{
+	{Header: "ID", Field: "Id"},
+	{Header: "STATE", Field: "State"},
+	{Header: "ROBOT", Field: "Robot.Name"},
+	{Header: "TAGS", Field: "Tags", Wide: true},
+}
+
+var testJobs = []testJob{
+	{ID: 1, State: "Running", Robot: &testRobot{ID: 7, Name: "Bot1"}, Tags: []string{"a", "b"}},
+	{ID: 22, State: "Faulted, retried", Done: true, Percent: 12.5},
+}
+
+func TestRender(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		format string
+		query  string
+		items  []testJob
+		want   string
+	}{
+		{
+			name:   "table hides wide columns and missing values",
+			format: FormatTable,
+			items:  testJobs,
+			want: "ID  STATE             ROBOT\n" +
+				"1   Running           Bot1\n" +
+				"22  Faulted, retried  \n",
+		},
+		{
+			name:   "empty format is a table",
+			format: "",
+			items:  testJobs[:1],
+			want: "ID  STATE    ROBOT\n" +
+				"1   Running  Bot1\n",
+		},
+		{
+			name:   "wide shows every column",
+			format: FormatWide,
+			items:  testJobs,
+			want: "ID  STATE             ROBOT  TAGS\n" +
+				"1   Running           Bot1   [\"a\",\"b\"]\n" +
+				"22  Faulted, retried         \n",
+		},
+		{
+			name:   "csv quotes values and keeps wide columns",
+			format: FormatCSV,
+			items:  testJobs,
+			want: "ID,STATE,ROBOT,TAGS\n" +
+				"1,Running,Bot1,\"[\"\"a\"\",\"\"b\"\"]\"\n" +
+				"22,\"Faulted, retried\",,\n",
+		},
+		{
+			name:   "json writes whole items",
+			format: FormatJSON,
+			items:  testJobs[1:],
+			want: "[\n" +
+				"  {\n" +
+				"    \"Id\": 22,\n" +
+				"    \"State\": \"Faulted, retried\",\n" +
+				"    \"Robot\": null,\n" +
+				"    \"Tags\": null,\n" +
+				"    \"Done\": true,\n" +
+				"    \"Percent\": 12.5\n" +
+				"  }\n" +
+				"]\n",
+		},
+		{
+			name:   "json with no items is an empty list",
+			format: FormatJSON,
+			want:   "[]\n",
+		},
+		{
+			name:   "yaml keeps the JSON field names and order",
+			format: FormatYAML,
+			items:  testJobs[:1],
+			want: "- Id: 1\n" +
+				"  State: Running\n" +
+				"  Robot:\n" +
+				"    Id: 7\n" +
+				"    Name: Bot1\n" +
+				"  Tags:\n" +
+				"  - a\n" +
+				"  - b\n" +
+				"  Done: false\n",
+		},
+		{
+			name:   "yaml with no items is an empty list",
+			format: FormatYAML,
+			want:   "[]\n",
+		},
+		{
+			name:   "query replaces the columns",
+			format: FormatTable,
+			query:  "$.Id, .Robot.Id,Tags[1],Missing.Field",
+			items:  testJobs,
+			want: "Id  Robot.Id  Tags[1]  Missing.Field\n" +
+				"1   7         b        \n" +
+				"22                     \n",
+		},
+		{
+			name:   "query selects json fields in order",
+			format: FormatJSON,
+			query:  "Robot.Name,Tags,Tags[5],Id",
+			items:  testJobs[:1],
+			want: "[\n" +
+				"  {\n" +
+				"    \"Robot.Name\": \"Bot1\",\n" +
+				"    \"Tags\": [\n" +
+				"      \"a\",\n" +
+				"      \"b\"\n" +
+				"    ],\n" +
+				"    \"Tags[5]\": null,\n" +
+				"    \"Id\": 1\n" +
+				"  }\n" +
+				"]\n",
+		},
+		{
+			name:   "query selects nested yaml values",
+			format: FormatYAML,
+			query:  "Robot,Percent",
+			items:  testJobs,
+			want: "- Robot:\n" +
+				"    Id: 7\n" +
+				"    Name: Bot1\n" +
+				"  Percent: null\n" +
+				"- Robot: null\n" +
+				"  Percent: 12.5\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var out bytes.Buffer
+			renderer, err := New(&out, test.format, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = renderer.Render(testColumns, test.items); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != test.want {
+				t.Errorf("output =\n%s\nwant\n%s", out.String(), test.want)
+			}
+			if renderer.Count() != len(test.items) {
+				t.Errorf("Count = %d, want %d", renderer.Count(), len(test.items))
+			}
+		})
+	}
+}
+
+func TestRenderPages(t *testing.T) {
+
+	var out bytes.Buffer
+	renderer, _ := New(&out, FormatJSON, "Id")
+
+	if err := renderer.Start(testColumns); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range [][]testJob{testJobs[:1], testJobs[1:]} {
+		if err := renderer.Items(page); err != nil {
+			t.Fatal(err)
+		}
+		if err := renderer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := renderer.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[\n  {\n    \"Id\": 1\n  },\n  {\n    \"Id\": 22\n  }\n]\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRenderDetails(t *testing.T) {
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatTable, "ID:    1\nSTATE: Running\nROBOT: Bot1\n"},
+		{FormatWide, "ID:    1\nSTATE: Running\nROBOT: Bot1\nTAGS:  [\"a\",\"b\"]\n"},
+		{FormatCSV, "ID,STATE,ROBOT,TAGS\n1,Running,Bot1,\"[\"\"a\"\",\"\"b\"\"]\"\n"},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		renderer, _ := New(&out, test.format, "")
+		if err := renderer.RenderDetails(testColumns, testJobs[0]); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: output = %q, want %q", test.format, out.String(), test.want)
+		}
+	}
+}
+
+func TestNewUnsupportedFormat(t *testing.T) {
+
+	_, err := New(&bytes.Buffer{}, "xml", "")
+	if err == nil || err.Error() != "Unsupported output format 'xml'.  Use table, wide, json, yaml or csv" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestItemsRejectsNonSlices(t *testing.T) {
+
+	renderer, _ := New(&bytes.Buffer{}, FormatJSON, "")
+	if err := renderer.Items(testJobs[0]); err == nil {
+		t.Error("Items accepted a single item")
+	}
+}
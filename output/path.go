@@ -0,0 +1,137 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// normalizePath trims JSONPath-style prefixes so "$.Name", ".Name" and "Name"
+// all select the same field
+func normalizePath(path string) string {
+
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	path = strings.TrimPrefix(path, ".")
+
+	return path
+}
+
+// lookup returns the value at a dotted path (e.g. "Robot.Name" or "Tags[0]")
+// within a value produced by toGeneric.  Missing fields return nil
+func lookup(value interface{}, path string) interface{} {
+
+	if path == "" {
+		return value
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+
+		name := segment
+		var indexes []int
+		if open := strings.Index(segment, "["); open >= 0 {
+			name = segment[:open]
+			for _, rawIndex := range strings.Split(strings.TrimSuffix(segment[open+1:], "]"), "][") {
+				index, err := strconv.Atoi(rawIndex)
+				if err != nil {
+					return nil
+				}
+				indexes = append(indexes, index)
+			}
+		}
+
+		if name != "" {
+			fields, ok := value.(yaml.MapSlice)
+			if !ok {
+				return nil
+			}
+			value = nil
+			for _, field := range fields {
+				if field.Key == name {
+					value = field.Value
+					break
+				}
+			}
+		}
+
+		for _, index := range indexes {
+			list, ok := value.([]interface{})
+			if !ok || index < 0 || index >= len(list) {
+				return nil
+			}
+			value = list[index]
+		}
+	}
+
+	return value
+}
+
+// toGeneric converts a result into maps, slices and scalars using its JSON form,
+// keeping object fields in their original order
+func toGeneric(v interface{}) interface{} {
+
+	rawValue, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(rawValue))
+	decoder.UseNumber()
+
+	generic, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil
+	}
+
+	return generic
+}
+
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typed := token.(type) {
+	case json.Delim:
+		if typed == '{' {
+			fields := yaml.MapSlice{}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(decoder)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, yaml.MapItem{Key: keyToken.(string), Value: value})
+			}
+			_, err = decoder.Token()
+			return fields, err
+		}
+
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, err
+
+	case json.Number:
+		if intValue, err := typed.Int64(); err == nil {
+			return intValue, nil
+		}
+		return typed.Float64()
+	}
+
+	return token, nil
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestNormalizePath(t *testing.T) {
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"Name", "Name"},
+		{" $.Robot.Name ", "Robot.Name"},
+		{".Tags[0]", "Tags[0]"},
+		{"$", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := normalizePath(test.path); got != test.want {
+			t.Errorf("normalizePath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+
+	generic := toGeneric(map[string]interface{}{
+		"Name":  "Job",
+		"Count": 3,
+		"Ratio": 0.5,
+		"Robot": map[string]interface{}{"Name": "Bot1", "Tags": []string{"x", "y"}},
+		"Items": []interface{}{map[string]interface{}{"Id": 1}, []int{10, 20}},
+		"Empty": nil,
+	})
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"Name", "Job"},
+		{"Count", int64(3)},
+		{"Ratio", 0.5},
+		{"Robot.Name", "Bot1"},
+		{"Robot.Tags[1]", "y"},
+		{"Robot.Tags", []interface{}{"x", "y"}},
+		{"Items[0].Id", int64(1)},
+		{"Items[1][0]", int64(10)},
+		{"Robot", yaml.MapSlice{{Key: "Name", Value: "Bot1"}, {Key: "Tags", Value: []interface{}{"x", "y"}}}},
+		{"Empty", nil},
+		{"Empty.Name", nil},
+		{"Missing", nil},
+		{"Robot.Missing.Name", nil},
+		{"Name.Length", nil},
+		{"Robot.Tags[2]", nil},
+		{"Robot.Tags[-1]", nil},
+		{"Robot.Tags[x]", nil},
+		{"Name[0]", nil},
+		{"", generic},
+	}
+
+	for _, test := range tests {
+		if got := lookup(generic, test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("lookup(%q) = %#v, want %#v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestToGenericKeepsFieldOrder(t *testing.T) {
+
+	type ordered struct {
+		Zebra string `json:"Zebra"`
+		Apple int    `json:"Apple"`
+	}
+
+	got := toGeneric(ordered{Zebra: "z", Apple: 1})
+	want := yaml.MapSlice{{Key: "Zebra", Value: "z"}, {Key: "Apple", Value: int64(1)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toGeneric = %#v, want %#v", got, want)
+	}
+}
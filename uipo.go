@@ -18,12 +18,23 @@ import (
 type CommandList struct {
 	Verbose       bool                      `short:"v" long:"verbose" hidden:"true" description:"Run in verbose mode.  Outputs debug level interaction details"`
 	Unsafe        bool                      `long:"unsafe" description:"Unsafe mode, disables endpoint certificate verification"`
+	Profile       string                    `long:"profile" description:"Named connection profile to use.  Overrides UIPO_PROFILE and the default profile"`
+	Output        string                    `short:"o" long:"output" default:"table" choice:"table" choice:"wide" choice:"json" choice:"yaml" choice:"csv" description:"Output format for command results"`
+	Query         string                    `long:"query" description:"Comma separated JSONPath-style fields to output (e.g. \"Name,MachineName\")"`
 	Authenticate  commands.CmdAuthenticate  `command:"auth" description:"Authenticate to UiPath Orchestrator"`
 	PlatformSetup commands.CmdPlatformSetup `command:"setup" description:"Used to configure and view UiPath Platform default values"`
 	Robots        commands.CmdRobots        `command:"robots" description:"List Robots in current tenant"`
 	Folders       commands.CmdGetFolders    `command:"folders" description:"List folders for current user"`
 	UploadPackage commands.CmdUploadPackage `command:"push" description:"Upload a new package to Orchestrator"`
 	AddQueueItem  commands.CmdAddQueueItem  `command:"addq" description:"Add an item to a queue"`
+	Profiles      commands.CmdProfile       `command:"profile" description:"Manage named connection profiles"`
+	Jobs          commands.CmdJobs          `command:"jobs" description:"Start and manage jobs in the current folder"`
+	Process       commands.CmdProcess       `command:"process" alias:"processes" description:"Manage processes (releases) in the current folder"`
+	Packages      commands.CmdPackages      `command:"packages" alias:"package" description:"Inspect package files and manage the package feeds"`
+	Libraries     commands.CmdLibraries     `command:"libraries" alias:"library" description:"Manage the shared libraries in the library feed"`
+	Assets        commands.CmdAssets        `command:"assets" alias:"asset" description:"Manage assets in the current folder"`
+	Queues        commands.CmdQueues        `command:"queues" alias:"queue" description:"Manage queues in the current folder and show their statistics"`
+	QueueItems    commands.CmdQueueItems    `command:"queueitems" alias:"queueitem" description:"List, inspect, retry, delete and export queue items in the current folder"`
 }
 
 var cmds CommandList
@@ -41,9 +52,19 @@ func main() {
 
 	cmdLineArgs := os.Args[1:]
 
-	parser.ParseArgs(cmdLineArgs)
+	_, err := parser.ParseArgs(cmdLineArgs)
+	if err != nil {
+		// go-flags has already printed the error
+		if exitErr, ok := err.(*commands.ExitError); ok {
+			os.Exit(exitErr.Code)
+		}
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			os.Exit(commands.ExitCodeSuccess)
+		}
+		os.Exit(commands.ExitCodeError)
+	}
 
-	os.Exit(0)
+	os.Exit(commands.ExitCodeSuccess)
 
 }
 
@@ -53,6 +74,15 @@ func executionWrapper(cmd flags.Commander, args []string) error {
 	if configErr != nil {
 		return configErr
 	}
+	profileErr := uipoConfig.ProfileError()
+	if cmds.Profile != "" {
+		profileErr = uipoConfig.SelectProfile(cmds.Profile)
+	}
+	// The profile commands manage the profiles themselves, so a missing profile
+	// only stops the commands that would connect with it
+	if _, ok := cmd.(commands.ProfileCommand); profileErr != nil && !ok {
+		return profileErr
+	}
 	util.LogInfo("Configuration Loaded")
 
 	defer func() {
@@ -68,6 +98,9 @@ func executionWrapper(cmd flags.Commander, args []string) error {
 		util.LogLevel = util.LogLevelDebug
 	}
 
+	uipoConfig.GlobalFlgs.OutputFormat = cmds.Output
+	uipoConfig.GlobalFlgs.OutputQuery = cmds.Query
+
 	// If running with --unsafe turn off certificate verification
 	// Can be used if the endpoint includes a cert with mis-matched host name and assignee
 	if cmds.Unsafe {
@@ -0,0 +1,10 @@
+// +build darwin
+
+package util
+
+import "os/exec"
+
+// OpenBrowser opens the URL in the user's default browser
+func OpenBrowser(url string) error {
+	return exec.Command("open", url).Start()
+}
@@ -0,0 +1,10 @@
+// +build windows
+
+package util
+
+import "os/exec"
+
+// OpenBrowser opens the URL in the user's default browser
+func OpenBrowser(url string) error {
+	return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+}
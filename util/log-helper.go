@@ -20,7 +20,7 @@ func LogInfo(logMsg string) {
 
 	if LogLevel >= LogLevelInfo {
 		log.SetPrefix("Info: ")
-		log.Println(logMsg)
+		log.Println(MaskSecrets(logMsg))
 	}
 
 }
@@ -28,13 +28,13 @@ func LogInfo(logMsg string) {
 func LogDebug(logMsg string) {
 	if LogLevel >= LogLevelDebug {
 		log.SetPrefix("Debug: ")
-		log.Println(logMsg)
+		log.Println(MaskSecrets(logMsg))
 	}
 }
 
 func LogTrace(logMsg string) {
 	if LogLevel >= LogLevelTrace {
 		log.SetPrefix("Trace: ")
-		log.Println(logMsg)
+		log.Println(MaskSecrets(logMsg))
 	}
 }
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"strings"
+	"sync"
+)
+
+// secrets holds values that must never appear in log output
+var secrets = struct {
+	sync.Mutex
+	values []string
+}{}
+
+// AddSecret registers a token or password so that it is masked in log output
+func AddSecret(secret string) {
+
+	// Very short values would mask unrelated text
+	if len(secret) < 6 {
+		return
+	}
+
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, value := range secrets.values {
+		if value == secret {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, secret)
+}
+
+// MaskSecret returns a display safe version of a secret, keeping only enough of
+// the start to tell values apart
+func MaskSecret(secret string) string {
+
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 12 {
+		return "********"
+	}
+
+	return secret[:4] + "********"
+}
+
+// MaskSecrets replaces every registered secret found in msg with its masked form
+func MaskSecrets(msg string) string {
+
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, value := range secrets.values {
+		msg = strings.Replace(msg, value, MaskSecret(value), -1)
+	}
+
+	return msg
+}
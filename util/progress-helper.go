@@ -0,0 +1,121 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressWidth is the number of characters in the bar itself
+const progressWidth = 30
+
+// Progress draws a single line progress bar showing bytes sent against the total
+// and the throughput.  The line is redrawn in place until Stop is called
+type Progress struct {
+	sent  int64 // updated atomically, kept first for 64 bit alignment
+	total int64
+	label string
+	out   io.Writer
+	start time.Time
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// IsTerminal reports whether the file is an interactive terminal rather than a
+// pipe or regular file
+func IsTerminal(file *os.File) bool {
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// NewProgress returns a progress bar for a transfer of total bytes, written to out
+func NewProgress(out io.Writer, label string, total int64) *Progress {
+	return &Progress{
+		total: total,
+		label: label,
+		out:   out,
+	}
+}
+
+// Start begins redrawing the bar several times a second
+func (p *Progress) Start() {
+
+	p.start = time.Now()
+	p.done = make(chan struct{})
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.draw()
+			}
+		}
+	}()
+}
+
+// Add records bytes sent.  A negative count takes back bytes, for example when a
+// request is retried from the start
+func (p *Progress) Add(count int64) {
+	atomic.AddInt64(&p.sent, count)
+}
+
+// Stop draws the bar a final time and ends the line
+func (p *Progress) Stop() {
+
+	close(p.done)
+	p.wg.Wait()
+
+	p.draw()
+	fmt.Fprintln(p.out, "")
+}
+
+func (p *Progress) draw() {
+
+	sent := atomic.LoadInt64(&p.sent)
+
+	filled := progressWidth
+	if p.total > 0 && sent < p.total {
+		filled = int(sent * progressWidth / p.total)
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	rate := int64(0)
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		rate = int64(float64(sent) / elapsed)
+	}
+
+	// Trailing spaces clear what is left of a longer previous line
+	fmt.Fprintf(p.out, "\r%s [%s] %s / %s  %s/s   ", p.label, bar, FormatBytes(sent), FormatBytes(p.total), FormatBytes(rate))
+}
+
+// FormatBytes formats a byte count using binary units, e.g. 1.5 MiB
+func FormatBytes(count int64) string {
+
+	const unit = 1024
+	if count < unit {
+		return fmt.Sprintf("%d B", count)
+	}
+
+	div, exp := int64(unit), 0
+	for n := count / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(count)/float64(div), "KMGTPE"[exp])
+}
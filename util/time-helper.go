@@ -0,0 +1,60 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTime parses an absolute time (RFC3339 or YYYY-MM-DD) or a time relative to
+// now such as "+2h" or "-30m"
+func ParseTime(value string, now time.Time) (time.Time, error) {
+
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		offset, err := ParseDuration(value[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if value[0] == '-' {
+			offset = -offset
+		}
+		return now.Add(offset), nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	if parsed, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return parsed, nil
+	}
+
+	return time.Time{}, errors.New("Invalid time '" + value + "'.  Use RFC3339 (2006-01-02T15:04:05Z), a date (2006-01-02) or a relative time such as +2h or -7d")
+}
+
+// ParseDuration is time.ParseDuration with added support for a leading day
+// count such as "7d" or "1d12h"
+func ParseDuration(value string) (time.Duration, error) {
+
+	var days time.Duration
+	if idx := strings.Index(value, "d"); idx > 0 {
+		count, err := strconv.Atoi(value[:idx])
+		if err != nil {
+			return 0, errors.New("Invalid duration '" + value + "'")
+		}
+		days = time.Duration(count) * 24 * time.Hour
+		value = value[idx+1:]
+		if value == "" {
+			return days, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("Invalid duration '" + value + "'")
+	}
+
+	return days + duration, nil
+}
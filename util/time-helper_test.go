@@ -0,0 +1,68 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   string
+	}{
+		{"90s", 90 * time.Second, ""},
+		{"2h30m", 150 * time.Minute, ""},
+		{"7d", 7 * 24 * time.Hour, ""},
+		{"1d12h", 36 * time.Hour, ""},
+		{"0d", 0, ""},
+		{"xd", 0, "Invalid duration 'xd'"},
+		{"1d2x", 0, "Invalid duration '2x'"},
+		{"d", 0, "Invalid duration 'd'"},
+		{"", 0, "Invalid duration ''"},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDuration(test.value)
+		if got != test.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", test.value, got, test.want)
+		}
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("ParseDuration(%q) err = %v, want %q", test.value, err, test.err)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	zone := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, zone)
+	invalid := "Use RFC3339 (2006-01-02T15:04:05Z), a date (2006-01-02) or a relative time such as +2h or -7d"
+
+	tests := []struct {
+		value string
+		want  time.Time
+		err   string
+	}{
+		{"+2h", now.Add(2 * time.Hour), ""},
+		{"-7d", now.AddDate(0, 0, -7), ""},
+		{" +1d12h ", now.Add(36 * time.Hour), ""},
+		{"2026-10-20T12:00:00Z", time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC), ""},
+		{"2026-10-20T12:00:00+02:00", time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC), ""},
+		{"2026-10-20", time.Date(2026, 10, 20, 0, 0, 0, 0, zone), ""},
+		{"+2x", time.Time{}, "Invalid duration '2x'"},
+		{"tomorrow", time.Time{}, "Invalid time 'tomorrow'.  " + invalid},
+		{"2026-13-01", time.Time{}, "Invalid time '2026-13-01'.  " + invalid},
+	}
+
+	for _, test := range tests {
+		got, err := ParseTime(test.value, now)
+		if !got.Equal(test.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", test.value, got, test.want)
+		}
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("ParseTime(%q) err = %v, want %q", test.value, err, test.err)
+		}
+	}
+}